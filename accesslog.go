@@ -0,0 +1,188 @@
+package hiweb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// AccessLogEntry is a served request of the access log, it is the data of the TemplateLogFormat templates
+type AccessLogEntry struct {
+	Time      time.Time
+	RequestID string
+	RemoteIP  string
+	User      string
+	Method    string
+	URI       string
+	Proto     string
+	Route     string
+	Status    int
+	Size      int64
+	Latency   time.Duration
+	Referer   string
+	UserAgent string
+}
+
+// AccessLogFormat writes an entry as a line of buf
+type AccessLogFormat func(buf *bytes.Buffer, entry *AccessLogEntry) error
+
+// CombinedLogFormat is the Apache combined log format:
+// 127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /a.gif HTTP/1.0" 200 2326 "http://example.com/" "Mozilla/4.08"
+func CombinedLogFormat(buf *bytes.Buffer, e *AccessLogEntry) error {
+	buf.WriteString(dashIfEmpty(e.RemoteIP))
+	buf.WriteString(" - ")
+	buf.WriteString(dashIfEmpty(e.User))
+	buf.WriteString(" [")
+	buf.WriteString(e.Time.Format("02/Jan/2006:15:04:05 -0700"))
+	buf.WriteString("] ")
+	buf.WriteString(strconv.Quote(e.Method + " " + e.URI + " " + e.Proto))
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.Itoa(e.Status))
+	buf.WriteByte(' ')
+	if e.Size == 0 {
+		buf.WriteByte('-')
+	} else {
+		buf.WriteString(strconv.FormatInt(e.Size, 10))
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.Quote(dashIfEmpty(e.Referer)))
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.Quote(dashIfEmpty(e.UserAgent)))
+	buf.WriteByte('\n')
+	return nil
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// jsonAccessLogEntry is the line of JSONLogFormat
+type jsonAccessLogEntry struct {
+	Time      string  `json:"time"`
+	RequestID string  `json:"request_id"`
+	RemoteIP  string  `json:"remote_ip"`
+	User      string  `json:"user,omitempty"`
+	Method    string  `json:"method"`
+	URI       string  `json:"uri"`
+	Proto     string  `json:"proto"`
+	Route     string  `json:"route,omitempty"`
+	Status    int     `json:"status"`
+	Size      int64   `json:"size"`
+	LatencyMS float64 `json:"latency_ms"`
+	Referer   string  `json:"referer,omitempty"`
+	UserAgent string  `json:"user_agent,omitempty"`
+}
+
+// JSONLogFormat writes the entries as JSON lines, the latency is in milliseconds
+func JSONLogFormat(buf *bytes.Buffer, e *AccessLogEntry) error {
+	content, err := json.Marshal(jsonAccessLogEntry{
+		Time:      e.Time.Format(time.RFC3339Nano),
+		RequestID: e.RequestID,
+		RemoteIP:  e.RemoteIP,
+		User:      e.User,
+		Method:    e.Method,
+		URI:       e.URI,
+		Proto:     e.Proto,
+		Route:     e.Route,
+		Status:    e.Status,
+		Size:      e.Size,
+		LatencyMS: float64(e.Latency) / float64(time.Millisecond),
+		Referer:   e.Referer,
+		UserAgent: e.UserAgent,
+	})
+	if err != nil {
+		return err
+	}
+	buf.Write(content)
+	buf.WriteByte('\n')
+	return nil
+}
+
+// TemplateLogFormat writes the entries by a text/template of AccessLogEntry,
+// e.g. {{.Method}} {{.URI}} {{.Status}} {{.Latency}}, a new line is added when the template has none
+func TemplateLogFormat(text string) (AccessLogFormat, error) {
+	tmpl, err := template.New("accesslog").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	newLine := !strings.HasSuffix(text, "\n")
+	return func(buf *bytes.Buffer, e *AccessLogEntry) error {
+		if err := tmpl.Execute(buf, e); err != nil {
+			return err
+		}
+		if newLine {
+			buf.WriteByte('\n')
+		}
+		return nil
+	}, nil
+}
+
+// AccessLog writes a line of format to w for every request after its response, CombinedLogFormat when format is nil.
+// Use it with App.Use so the controller, static, swagger and unmatched routes are all logged, e.g.
+//
+//	file := &hiweb.RotatingFile{Filename: "logs/access.log", MaxSize: 100 << 20, MaxAge: 24 * time.Hour}
+//	app.Use(hiweb.AccessLog(file, hiweb.JSONLogFormat))
+func AccessLog(w io.Writer, format AccessLogFormat) Middleware {
+	if format == nil {
+		format = CombinedLogFormat
+	}
+	var mu sync.Mutex
+	return func(next Next) Next {
+		return func(ctx *WebContext) error {
+			start := time.Now()
+			ctx.OnDone(func(ctx *WebContext) {
+				entry := newAccessLogEntry(ctx, start)
+				var buf bytes.Buffer
+				if err := format(&buf, entry); err != nil {
+					ctx.Logger().Error("access log err:%s", err)
+					return
+				}
+				mu.Lock()
+				_, err := w.Write(buf.Bytes())
+				mu.Unlock()
+				if err != nil {
+					ctx.Logger().Error("access log err:%s", err)
+				}
+			})
+			return next(ctx)
+		}
+	}
+}
+
+func newAccessLogEntry(ctx *WebContext, start time.Time) *AccessLogEntry {
+	req := ctx.Request
+	entry := &AccessLogEntry{
+		Time:      start,
+		RequestID: ctx.requestID,
+		RemoteIP:  ctx.RemoteIP(),
+		Method:    req.Method,
+		URI:       req.RequestURI,
+		Proto:     req.Proto,
+		Route:     ctx.route,
+		Status:    200,
+		Latency:   time.Since(start),
+		Referer:   req.Referer(),
+		UserAgent: req.UserAgent(),
+	}
+	if entry.URI == "" {
+		entry.URI = req.URL.RequestURI()
+	}
+	if recorder := ctx.Response(); recorder != nil {
+		entry.Status, entry.Size = recorder.Status(), recorder.Size()
+	}
+	if sub, ok := ctx.claims["sub"]; ok {
+		entry.User = fmt.Sprint(sub)
+	} else if user, _, ok := req.BasicAuth(); ok {
+		entry.User = user
+	}
+	return entry
+}
@@ -0,0 +1,112 @@
+package hiweb
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+type accessLogTestController struct {
+	Controller
+}
+
+func (c *accessLogTestController) Get(id int) (int, error) {
+	if id == 0 {
+		return 0, NewHTTPError(http.StatusNotFound, "order_not_found", "order is not found")
+	}
+	return id, nil
+}
+
+func TestAccessLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hiweb-accesslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "static"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "static", "a.txt"), []byte("hello"), 0644)
+
+	var combined, lines bytes.Buffer
+	custom, err := TemplateLogFormat("{{.Method}} {{.Route}} {{.Status}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := NewApp()
+	app.Use(AccessLog(&combined, nil), AccessLog(&lines, JSONLogFormat), AccessLog(&lines, custom))
+	app.Route("/orders/{id}", &accessLogTestController{}, "id", "get:Get", RouteOption{})
+	app.RouteFiles("/static/", dir)
+
+	for _, path := range []string{"/orders/7", "/orders/0", "/static/a.txt", "/missing"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "10.0.0.1:5000"
+		req.Header.Set("User-Agent", "test/1.0")
+		req.SetBasicAuth("frank", "secret")
+		app.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	combinedLines := strings.Split(strings.TrimSpace(combined.String()), "\n")
+	pattern := regexp.MustCompile(`^10\.0\.0\.1 - frank \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [-+]\d{4}\] "GET (\S+) HTTP/1\.1" (\d{3}) (\d+|-) "-" "test/1\.0"$`)
+	want := [][]string{{"/orders/7", "200", "1"}, {"/orders/0", "404", ""}, {"/static/a.txt", "200", "5"}, {"/missing", "404", ""}}
+	if len(combinedLines) != len(want) {
+		t.Fatalf("combined log got %s", combined.String())
+	}
+	for i, line := range combinedLines {
+		m := pattern.FindStringSubmatch(line)
+		if m == nil || m[1] != want[i][0] || m[2] != want[i][1] || (want[i][2] != "" && m[3] != want[i][2]) {
+			t.Errorf("combined line got %s", line)
+		}
+	}
+
+	// the json and template lines of a request follow each other, the inner middleware is done first
+	other := strings.Split(strings.TrimSpace(lines.String()), "\n")
+	if len(other) != 8 || other[0] != "GET /orders/{id} 200" || other[6] != "GET  404" {
+		t.Fatalf("json and template lines got %q", other)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(other[3]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["uri"] != "/orders/0" || entry["status"] != float64(404) || entry["route"] != "/orders/{id}" || entry["user"] != "frank" || entry["request_id"] == "" {
+		t.Errorf("json line got %v", entry)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hiweb-rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "logs", "access.log")
+	f := &RotatingFile{Filename: name, MaxSize: 10, MaxBackups: 2}
+	for _, line := range []string{"aaaa\n", "bbbb\n", "cccc\n", "dddd\n", "eeee\n", "ffff\n", "gggg\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	content, _ := ioutil.ReadFile(name)
+	backups, _ := filepath.Glob(name + ".*")
+	if string(content) != "gggg\n" || len(backups) != 2 {
+		t.Fatalf("file %q backups %v", content, backups)
+	}
+	if last, _ := ioutil.ReadFile(backups[1]); string(last) != "eeee\nffff\n" {
+		t.Errorf("last backup got %q", last)
+	}
+
+	// the age rotation starts a new file for the next write
+	f.MaxSize, f.MaxAge = 0, 20*time.Millisecond
+	time.Sleep(30 * time.Millisecond)
+	f.Write([]byte("hhhh\n"))
+	f.Close()
+	if content, _ := ioutil.ReadFile(name); string(content) != "hhhh\n" {
+		t.Errorf("file after max age got %q", content)
+	}
+}
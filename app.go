@@ -0,0 +1,158 @@
+package hiweb
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// App is an isolated web application with its own routes, Config and logger.
+// An App is an http.Handler, so it can be served by any http.Server or httptest.Server.
+type App struct {
+	Config *Config
+
+	router *router
+
+	middlewares           []Middleware
+	middlewareMu          sync.RWMutex
+	namedMiddlewares      map[string]Middleware
+	controllerMiddlewares map[reflect.Type][]Middleware
+	authSchemes           map[string]AuthScheme
+	ipAccess              map[string]*IPAccess
+	filterIPOnce          sync.Once
+	filterIPs             *IPList
+
+	// serveDefaultMux registers the app on http.DefaultServeMux with the first route
+	serveDefaultMux sync.Once
+	isDefault       bool
+
+	swaggerMu sync.RWMutex
+	swag      Swagger
+
+	serverMu      sync.Mutex
+	server        *http.Server
+	stopped       chan struct{}
+	ready         bool
+	startHooks    []Hook
+	shutdownHooks []Hook
+	healthOnce    sync.Once
+
+	sessionMu sync.Mutex
+
+	metricsOnce sync.Once
+	metrics     *MetricsRegistry
+	httpMetrics *httpMetrics
+
+	rateLimitMu sync.Mutex
+	rateLimits  *MemoryRateLimitStore
+}
+
+// defaultApp backs the package level functions (Route, Map, RouteFiles ...).
+// It is served by http.DefaultServeMux and uses WebConfig.
+var defaultApp = &App{
+	Config:    &WebConfig,
+	router:    newRouter(),
+	isDefault: true,
+}
+
+func init() {
+	defaultApp.middlewares = defaultMiddlewares()
+}
+
+// NewApp creates an App with a default Config, configFns can modify the config.
+func NewApp(configFns ...func(*Config)) *App {
+	config := NewConfig()
+	for _, configFn := range configFns {
+		configFn(config)
+	}
+	return &App{
+		Config:      config,
+		router:      newRouter(),
+		middlewares: defaultMiddlewares(),
+	}
+}
+
+func defaultMiddlewares() []Middleware {
+	return []Middleware{Recover(), CORS()}
+}
+
+// Default returns the App used by the package level functions.
+func Default() *App {
+	return defaultApp
+}
+
+// Logger returns the logger of the app config
+func (app *App) Logger() Logger {
+	return app.Config.Logger
+}
+
+// handle registers handler for method and the route path pattern, it panics when the route is invalid or registered twice.
+func (app *App) handle(method, pattern string, handler Next) {
+	if app.isDefault {
+		app.serveDefaultMux.Do(func() {
+			http.DefaultServeMux.Handle("/", app)
+		})
+	}
+	if err := app.router.add(method, pattern, handler); err != nil {
+		panic(err)
+	}
+}
+
+// Handle registers the handler for the given pattern.
+// Like http.ServeMux a pattern ending in '/' matches all the paths under it.
+func (app *App) Handle(pattern string, handler http.Handler) {
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "{*path}"
+	}
+	app.handle(anyMethod, pattern, func(ctx *WebContext) error {
+		handler.ServeHTTP(ctx.ResponseWriter, ctx.Request)
+		return nil
+	})
+}
+
+// HandleFunc registers the handler function for the given pattern.
+func (app *App) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	app.Handle(pattern, http.HandlerFunc(handler))
+}
+
+// ServeHTTP dispatches the request to the route matching the request method and path.
+// The requests without a route pass the app middlewares too, so they are logged and get the CORS headers.
+func (app *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	recorder := &ResponseRecorder{ResponseWriter: w}
+	ctx := newWebContext(app, recorder, r)
+	ctx.recorder = recorder
+	ctx.requestID = requestID(r)
+	recorder.Header().Set(RequestIDHeader, ctx.requestID)
+	app.serverMetrics().inFlight.Add(1)
+	defer app.finishRequest(ctx, start)
+	rt, params, allowed := app.router.find(r.Method, r.URL.Path)
+	if rt == nil && len(allowed) > 0 && r.Method == http.MethodOptions {
+		// the options of the path, preflight requests are answered by the CORS middleware
+		pattern := ""
+		if matched, _, _ := app.router.find(allowed[0], r.URL.Path); matched != nil {
+			pattern = matched.pattern
+		}
+		rt = &route{method: r.Method, pattern: pattern, handler: func(ctx *WebContext) error {
+			ctx.ResponseWriter.Header().Set("Allow", strings.Join(append(allowed, http.MethodOptions), ", "))
+			ctx.ResponseWriter.WriteHeader(http.StatusNoContent)
+			return nil
+		}}
+	}
+	if rt == nil {
+		rt = &route{method: r.Method, handler: func(ctx *WebContext) error {
+			if len(allowed) > 0 {
+				ctx.ResponseWriter.Header().Set("Allow", strings.Join(allowed, ", "))
+				return NewHTTPError(http.StatusMethodNotAllowed, "method_not_allowed", r.Method+" not allowed")
+			}
+			return NewHTTPError(http.StatusNotFound, "not_found", "not found route")
+		}}
+	}
+	ctx.route = rt.pattern
+	ctx.params = params
+	if err := Chain(rt.handler, app.middlewares...)(ctx); err != nil {
+		app.handleError(ctx, err)
+	}
+}
@@ -0,0 +1,53 @@
+package hiweb
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type appTestController struct {
+	Controller
+}
+
+func (c *appTestController) Hello(name string) {
+	_ = c.ServeBody(http.StatusOK, []byte(c.Ctx.Config().SecretKey+":"+name))
+}
+
+func TestAppIsolation(t *testing.T) {
+	public := NewApp(func(c *Config) { c.SecretKey = "public" })
+	admin := NewApp(func(c *Config) { c.SecretKey = "admin" })
+	public.Route("/hello", &appTestController{}, "name", "get:Hello", RouteOption{})
+	admin.Route("/admin/hello", &appTestController{}, "name", "get:Hello", RouteOption{})
+
+	publicServer := httptest.NewServer(public)
+	defer publicServer.Close()
+	adminServer := httptest.NewServer(admin)
+	defer adminServer.Close()
+
+	body, status := appTestGet(t, publicServer.URL+"/hello?name=a")
+	if status != http.StatusOK || body != "public:a" {
+		t.Errorf("public hello got %d %q", status, body)
+	}
+	body, status = appTestGet(t, adminServer.URL+"/admin/hello?name=b")
+	if status != http.StatusOK || body != "admin:b" {
+		t.Errorf("admin hello got %d %q", status, body)
+	}
+	if _, status = appTestGet(t, publicServer.URL+"/admin/hello"); status != http.StatusNotFound {
+		t.Errorf("admin route is served by public app, status %d", status)
+	}
+}
+
+func appTestGet(t *testing.T, url string) (string, int) {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body), resp.StatusCode
+}
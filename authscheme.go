@@ -0,0 +1,220 @@
+package hiweb
+
+import (
+	"crypto/subtle"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/dgrijalva/jwt-go/request"
+)
+
+// ErrNoCredentials is returned by an AuthScheme when the request has no credentials of the scheme,
+// the next scheme of the route is tried then
+var ErrNoCredentials = errors.New("no credentials")
+
+// AuthScheme authenticates a request and returns the claims checked by the Authorizer.
+// Routes select the schemes by RouteOption.AuthSchemes, e.g. @Auth apikey|bearer.
+type AuthScheme interface {
+	Authenticate(ctx *WebContext) (jwt.MapClaims, error)
+}
+
+// AuthSchemeFunc is an AuthScheme function
+type AuthSchemeFunc func(ctx *WebContext) (jwt.MapClaims, error)
+
+func (f AuthSchemeFunc) Authenticate(ctx *WebContext) (jwt.MapClaims, error) {
+	return f(ctx)
+}
+
+// Names of the built-in schemes, bearer, session and mtls are registered on every App,
+// apikey and basic need a verifier and are registered by App.RegisterAuthScheme
+const (
+	AuthSchemeBearer  = "bearer"
+	AuthSchemeSession = "session"
+	AuthSchemeMTLS    = "mtls"
+	AuthSchemeAPIKey  = "apikey"
+	AuthSchemeBasic   = "basic"
+)
+
+// RegisterAuthScheme names an AuthScheme, it replaces a scheme of the same name
+func (app *App) RegisterAuthScheme(name string, scheme AuthScheme) {
+	app.middlewareMu.Lock()
+	defer app.middlewareMu.Unlock()
+	if app.authSchemes == nil {
+		app.authSchemes = make(map[string]AuthScheme)
+	}
+	app.authSchemes[name] = scheme
+}
+
+// authScheme returns the registered scheme of name, or the built-in bearer, session or mtls scheme
+func (app *App) authScheme(name string) (AuthScheme, bool) {
+	app.middlewareMu.RLock()
+	scheme, has := app.authSchemes[name]
+	app.middlewareMu.RUnlock()
+	if has {
+		return scheme, true
+	}
+	switch name {
+	case AuthSchemeBearer:
+		return BearerAuth{}, true
+	case AuthSchemeSession:
+		return SessionAuth{}, true
+	case AuthSchemeMTLS:
+		return MTLSAuth{}, true
+	}
+	return nil, false
+}
+
+// authenticate tries the schemes in order, the first scheme finding its credentials decides
+func (app *App) authenticate(ctx *WebContext, names []string) error {
+	for _, name := range names {
+		scheme, has := app.authScheme(name)
+		if !has {
+			return fmt.Errorf("auth scheme %s is not registered", name)
+		}
+		claims, err := scheme.Authenticate(ctx)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		if err != nil {
+			var he *HTTPError
+			if errors.As(err, &he) {
+				return err
+			}
+			return WrapHTTPError(http.StatusUnauthorized, "unauthorized", err)
+		}
+		if claims == nil {
+			claims = jwt.MapClaims{}
+		}
+		ctx.SetClaims(claims)
+		return nil
+	}
+	return NewHTTPError(http.StatusUnauthorized, "unauthorized", "credentials of "+strings.Join(names, " or ")+" are required")
+}
+
+// BearerAuth validates the jwt of the header Authorization: Bearer <token> by App.JwtClaims
+type BearerAuth struct{}
+
+func (BearerAuth) Authenticate(ctx *WebContext) (jwt.MapClaims, error) {
+	tokenString, err := request.AuthorizationHeaderExtractor.ExtractToken(ctx.Request)
+	if err != nil {
+		return nil, ErrNoCredentials
+	}
+	return ctx.App().JwtClaims(tokenString)
+}
+
+// APIKeyAuth reads an api key from the Header, or the Query parameter when Header is empty
+type APIKeyAuth struct {
+	// Header is the header of the key, default X-API-Key when Query is empty too
+	Header string
+	Query  string
+	// Verify returns the claims of key, an error rejects the request
+	Verify func(ctx *WebContext, key string) (jwt.MapClaims, error)
+}
+
+// APIKeys verifies the keys of a map from the key to its claims
+func APIKeys(keys map[string]jwt.MapClaims) func(ctx *WebContext, key string) (jwt.MapClaims, error) {
+	return func(ctx *WebContext, key string) (jwt.MapClaims, error) {
+		for k, claims := range keys {
+			if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+				return claims, nil
+			}
+		}
+		return nil, errors.New("api key is not valid")
+	}
+}
+
+func (a APIKeyAuth) Authenticate(ctx *WebContext) (jwt.MapClaims, error) {
+	var key string
+	if a.Query != "" {
+		key = ctx.Request.URL.Query().Get(a.Query)
+	}
+	if header := a.Header; header != "" || a.Query == "" {
+		if header == "" {
+			header = "X-API-Key"
+		}
+		if v := ctx.GetHeader(header); v != "" {
+			key = v
+		}
+	}
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+	if a.Verify == nil {
+		return nil, errors.New("api key verifier is not set")
+	}
+	return a.Verify(ctx, key)
+}
+
+// BasicAuth checks the HTTP Basic credentials, the rejected requests get the WWW-Authenticate challenge
+type BasicAuth struct {
+	// Realm of the challenge, default hiweb
+	Realm string
+	// Verify returns the claims of the user, an error rejects the request
+	Verify func(ctx *WebContext, user, password string) (jwt.MapClaims, error)
+}
+
+func (a BasicAuth) Authenticate(ctx *WebContext) (jwt.MapClaims, error) {
+	user, password, ok := ctx.Request.BasicAuth()
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+	if a.Verify == nil {
+		return nil, errors.New("basic auth verifier is not set")
+	}
+	claims, err := a.Verify(ctx, user, password)
+	if err != nil {
+		realm := a.Realm
+		if realm == "" {
+			realm = "hiweb"
+		}
+		ctx.ResponseWriter.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", realm))
+		return nil, err
+	}
+	if claims == nil {
+		claims = jwt.MapClaims{"sub": user}
+	}
+	return claims, nil
+}
+
+// SessionAuth accepts the requests whose session (WebContext.Session) has the Key, the session values are the claims
+type SessionAuth struct {
+	// Key is the session value of a logged in user, default user
+	Key string
+}
+
+func (a SessionAuth) Authenticate(ctx *WebContext) (jwt.MapClaims, error) {
+	session, err := ctx.Session()
+	if err != nil {
+		return nil, err
+	}
+	key := a.Key
+	if key == "" {
+		key = "user"
+	}
+	if session.IsNew() || session.Get(key) == nil {
+		return nil, ErrNoCredentials
+	}
+	return jwt.MapClaims(session.Values), nil
+}
+
+// MTLSAuth accepts the client certificates verified by the tls.Config of the server (ClientCAs),
+// the claims are sub with the common name of the certificate unless Verify returns others
+type MTLSAuth struct {
+	Verify func(ctx *WebContext, cert *x509.Certificate) (jwt.MapClaims, error)
+}
+
+func (a MTLSAuth) Authenticate(ctx *WebContext) (jwt.MapClaims, error) {
+	state := ctx.Request.TLS
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil, ErrNoCredentials
+	}
+	cert := state.VerifiedChains[0][0]
+	if a.Verify != nil {
+		return a.Verify(ctx, cert)
+	}
+	return jwt.MapClaims{"sub": cert.Subject.CommonName}, nil
+}
@@ -0,0 +1,90 @@
+package hiweb
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+type authSchemeTestController struct {
+	Controller
+}
+
+func (c *authSchemeTestController) Me() interface{} {
+	return c.GetClaim("sub")
+}
+
+func (c *authSchemeTestController) Login() error {
+	session, err := c.Session()
+	if err != nil {
+		return err
+	}
+	session.Set("user", "u4")
+	session.Set("sub", "u4")
+	return session.Save()
+}
+
+func TestAuthSchemes(t *testing.T) {
+	app := NewApp(func(c *Config) { c.SecretKey = "auth scheme test secret" })
+	app.RegisterAuthScheme(AuthSchemeAPIKey, APIKeyAuth{Header: "X-API-Key", Verify: APIKeys(map[string]jwt.MapClaims{"k1": {"sub": "u1"}})})
+	app.RegisterAuthScheme(AuthSchemeBasic, BasicAuth{Verify: func(ctx *WebContext, user, password string) (jwt.MapClaims, error) {
+		if user != "u2" || password != "p2" {
+			return nil, errors.New("wrong password")
+		}
+		return nil, nil
+	}})
+	app.Route("/me", &authSchemeTestController{}, "", "get:Me", RouteOption{IsAuth: true, AuthSchemes: []string{"apikey", "basic", "bearer", "session", "mtls"}})
+	app.Route("/key", &authSchemeTestController{}, "", "get:Me", RouteOption{IsAuth: true, AuthSchemes: []string{"apikey"}})
+	app.Route("/login", &authSchemeTestController{}, "", "post:Login", RouteOption{})
+	app.Route("/missing", &authSchemeTestController{}, "", "get:Me", RouteOption{IsAuth: true, AuthSchemes: []string{"ldap"}})
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w
+	}
+	bearer, _ := app.JwtToken(map[string]interface{}{"sub": "u3"})
+	w := serve(httptest.NewRequest(http.MethodPost, "/login", nil))
+	cookie := w.Result().Cookies()[0]
+
+	cases := []struct {
+		name   string
+		path   string
+		setup  func(req *http.Request)
+		status int
+		body   string
+	}{
+		{"apikey", "/me", func(req *http.Request) { req.Header.Set("X-API-Key", "k1") }, http.StatusOK, `"u1"`},
+		{"wrong apikey", "/me", func(req *http.Request) { req.Header.Set("X-API-Key", "k2") }, http.StatusUnauthorized, ""},
+		{"basic", "/me", func(req *http.Request) { req.SetBasicAuth("u2", "p2") }, http.StatusOK, `"u2"`},
+		{"bearer", "/me", func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+bearer) }, http.StatusOK, `"u3"`},
+		{"session", "/me", func(req *http.Request) { req.AddCookie(cookie) }, http.StatusOK, `"u4"`},
+		{"mtls", "/me", func(req *http.Request) {
+			cert := &x509.Certificate{Subject: pkix.Name{CommonName: "u5"}}
+			req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		}, http.StatusOK, `"u5"`},
+		{"none", "/me", func(req *http.Request) {}, http.StatusUnauthorized, ""},
+		{"bearer on apikey route", "/key", func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+bearer) }, http.StatusUnauthorized, ""},
+		{"unregistered scheme", "/missing", func(req *http.Request) {}, http.StatusInternalServerError, ""},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, c.path, nil)
+		c.setup(req)
+		w := serve(req)
+		if w.Code != c.status || (c.body != "" && w.Body.String() != c.body) {
+			t.Errorf("%s got %d %s", c.name, w.Code, w.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.SetBasicAuth("u2", "wrong")
+	if w := serve(req); w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") != `Basic realm="hiweb"` {
+		t.Errorf("wrong basic password got %d %v", w.Code, w.Header())
+	}
+}
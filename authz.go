@@ -0,0 +1,117 @@
+package hiweb
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Authorizer decides whether the claims of a request may call a route requiring roles and scopes,
+// a returned error is sent as 403 Forbidden, an HTTPError is sent as it is.
+type Authorizer interface {
+	Authorize(ctx *WebContext, claims jwt.MapClaims, roles []string, scopes []string) error
+}
+
+// AuthorizerFunc is an Authorizer function
+type AuthorizerFunc func(ctx *WebContext, claims jwt.MapClaims, roles []string, scopes []string) error
+
+func (f AuthorizerFunc) Authorize(ctx *WebContext, claims jwt.MapClaims, roles []string, scopes []string) error {
+	return f(ctx, claims, roles, scopes)
+}
+
+// ClaimsAuthorizer is the default Authorizer, the claims need one of the roles and all of the scopes
+type ClaimsAuthorizer struct {
+	// RolesClaim is the claim of the roles, an array or a comma separated string, default roles
+	RolesClaim string
+	// ScopesClaim is the claim of the scopes, a space separated string or an array, default scope, then scp
+	ScopesClaim string
+}
+
+func (a ClaimsAuthorizer) Authorize(ctx *WebContext, claims jwt.MapClaims, roles []string, scopes []string) error {
+	if len(roles) > 0 {
+		rolesClaim := a.RolesClaim
+		if rolesClaim == "" {
+			rolesClaim = "roles"
+		}
+		if !containsAny(claimValues(claims[rolesClaim], ","), roles) {
+			return fmt.Errorf("one of the roles %s is required", strings.Join(roles, ","))
+		}
+	}
+	if len(scopes) > 0 {
+		granted := claimValues(claims[a.ScopesClaim], " ")
+		if a.ScopesClaim == "" {
+			granted = append(claimValues(claims["scope"], " "), claimValues(claims["scp"], " ")...)
+		}
+		for _, scope := range scopes {
+			if !containsAny(granted, []string{scope}) {
+				return fmt.Errorf("the scope %s is required", scope)
+			}
+		}
+	}
+	return nil
+}
+
+// claimValues reads a claim of an array or a string separated by sep
+func claimValues(claim interface{}, sep string) []string {
+	var values []string
+	switch v := claim.(type) {
+	case string:
+		for _, s := range strings.Split(v, sep) {
+			if s = strings.TrimSpace(s); s != "" {
+				values = append(values, s)
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	case []string:
+		values = v
+	}
+	return values
+}
+
+func containsAny(values []string, wanted []string) bool {
+	for _, v := range values {
+		for _, w := range wanted {
+			if v == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Claims returns the jwt claims of the request set by CheckAuth or SetClaims
+func (c *WebContext) Claims() jwt.MapClaims {
+	return c.claims
+}
+
+// SetClaims sets the claims checked by the Authorizer, a Config.AuthHandler sets the claims of its token here
+func (c *WebContext) SetClaims(claims jwt.MapClaims) {
+	c.claims = claims
+}
+
+// routeAuthorize checks RouteOption.Roles and Scopes by Config.Authorizer
+func routeAuthorize(context *WebContext, option RouteOption) error {
+	if len(option.Roles) == 0 && len(option.Scopes) == 0 {
+		return nil
+	}
+	authorizer := context.Config().Authorizer
+	if authorizer == nil {
+		authorizer = ClaimsAuthorizer{}
+	}
+	if err := authorizer.Authorize(context, context.claims, option.Roles, option.Scopes); err != nil {
+		var he *HTTPError
+		if errors.As(err, &he) {
+			return err
+		}
+		return WrapHTTPError(http.StatusForbidden, "forbidden", err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package hiweb
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+type authzTestController struct {
+	Controller
+}
+
+func (c *authzTestController) Cancel() string {
+	return c.GetClaim("sub").(string)
+}
+
+func TestRouteAuthorize(t *testing.T) {
+	app := NewApp(func(c *Config) { c.SecretKey = "authz test secret" })
+	app.Route("/cancel", &authzTestController{}, "", "post:Cancel", RouteOption{IsAuth: true, Roles: []string{"admin", "ops"}, Scopes: []string{"orders:write"}})
+
+	cases := []struct {
+		claims map[string]interface{}
+		status int
+	}{
+		{map[string]interface{}{"sub": "u1", "roles": []string{"ops"}, "scope": "orders:read orders:write"}, http.StatusOK},
+		{map[string]interface{}{"sub": "u1", "roles": "user,admin", "scp": []string{"orders:write"}}, http.StatusOK},
+		{map[string]interface{}{"sub": "u1", "roles": []string{"user"}, "scope": "orders:write"}, http.StatusForbidden},
+		{map[string]interface{}{"sub": "u1", "roles": []string{"admin"}, "scope": "orders:read"}, http.StatusForbidden},
+		{map[string]interface{}{"sub": "u1"}, http.StatusForbidden},
+	}
+	for _, c := range cases {
+		tokenString, err := app.JwtToken(c.claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/cancel", nil)
+		req.Header.Set("Authorization", "Bearer "+tokenString)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Code != c.status {
+			t.Errorf("claims %v got %d %s", c.claims, w.Code, w.Body.String())
+		}
+	}
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/cancel", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("no token got %d", w.Code)
+	}
+
+	// a custom Authorizer with the claims set by an AuthHandler
+	app.Config.AuthHandler = func(ctx *WebContext) error {
+		ctx.SetClaims(jwt.MapClaims{"sub": "u2", "tenant": ctx.GetHeader("X-Tenant")})
+		return nil
+	}
+	app.Config.Authorizer = AuthorizerFunc(func(ctx *WebContext, claims jwt.MapClaims, roles, scopes []string) error {
+		if claims["tenant"] != "t1" {
+			return NewHTTPError(http.StatusNotFound, "no_tenant", "unknown tenant")
+		}
+		return nil
+	})
+	for tenant, status := range map[string]int{"t1": http.StatusOK, "t2": http.StatusNotFound} {
+		req := httptest.NewRequest(http.MethodPost, "/cancel", nil)
+		req.Header.Set("X-Tenant", tenant)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Code != status {
+			t.Errorf("tenant %s got %d %s", tenant, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestClaimsAuthorizer(t *testing.T) {
+	authorizer := ClaimsAuthorizer{RolesClaim: "groups", ScopesClaim: "permissions"}
+	claims := jwt.MapClaims{"groups": []interface{}{"ops"}, "permissions": []interface{}{"a", "b"}}
+	if err := authorizer.Authorize(nil, claims, []string{"admin", "ops"}, []string{"a", "b"}); err != nil {
+		t.Errorf("authorize err:%s", err)
+	}
+	if err := authorizer.Authorize(nil, claims, nil, []string{"c"}); err == nil || errors.Is(err, ErrTokenInvalid) {
+		t.Errorf("missing scope got %v", err)
+	}
+}
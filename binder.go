@@ -0,0 +1,512 @@
+package hiweb
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTimeLayouts are the layouts tried in order to bind time.Time arguments
+var DefaultTimeLayouts = []string{
+	time.RFC3339Nano,
+	formatDateTimeT,
+	formatDateTime,
+	formatDate,
+	formatTime,
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// isBodyType reports whether an argument of type t is decoded from the whole request body,
+// these are structs and maps which are not scalars like time.Time or encoding.TextUnmarshaler.
+func isBodyType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if isScalarType(t) {
+		return false
+	}
+	return t.Kind() == reflect.Struct || t.Kind() == reflect.Map
+}
+
+func isScalarType(t reflect.Type) bool {
+	if t == timeType || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	return t.Kind() != reflect.Struct && t.Kind() != reflect.Map
+}
+
+// requestValues returns the values of the argument name from the source of RouteOption.Params,
+// or for SourceAuto from the path params, the repeated query or form keys, or the raw value of a json body.
+// A missing json key is an error unless the argument is optional, a missing required argument is always an error.
+func requestValues(ctx *WebContext, name string, optional bool) ([]string, interface{}, error) {
+	var spec ParamSpec
+	if ctx.option != nil {
+		spec = ctx.option.Params[name]
+	}
+	values, raw, err := sourceValues(ctx, name, spec.In, optional && !spec.Required)
+	if err == nil && spec.Required && len(values) == 0 && raw == nil {
+		in := spec.In
+		if in == SourceAuto {
+			in = "request"
+		}
+		err = &HTTPError{Status: http.StatusBadRequest, Code: "missing_param", Message: fmt.Sprintf("%s %s is required", in, name)}
+	}
+	return values, raw, err
+}
+
+func sourceValues(ctx *WebContext, name string, in ParamSource, optional bool) ([]string, interface{}, error) {
+	req := ctx.Request
+	switch in {
+	case SourcePath:
+		if v, has := ctx.params[name]; has {
+			return []string{v}, nil, nil
+		}
+		return nil, nil, nil
+	case SourceQuery:
+		return req.URL.Query()[name], nil, nil
+	case SourceHeader:
+		return req.Header[textproto.CanonicalMIMEHeaderKey(name)], nil, nil
+	case SourceCookie:
+		if cookie, err := req.Cookie(name); err == nil {
+			return []string{cookie.Value}, nil, nil
+		}
+		return nil, nil, nil
+	case SourceForm:
+		if req.PostForm == nil {
+			var err error
+			if strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/form-data") {
+				err = ctx.parseMultipartForm()
+			} else {
+				err = req.ParseForm()
+			}
+			if err != nil {
+				return nil, nil, fmt.Errorf("parse form err:%w", err)
+			}
+		}
+		return req.PostForm[name], nil, nil
+	case SourceBody:
+		_, codec := ctx.codecs().Lookup(req.Header.Get("Content-Type"))
+		if codec == nil {
+			return sourceValues(ctx, name, SourceForm, optional)
+		}
+		if ctx.bodyFields == nil {
+			body, err := ctx.GetBody()
+			if err != nil {
+				return nil, nil, err
+			}
+			obj := make(map[string]interface{})
+			if len(bytes.TrimSpace(body)) != 0 {
+				if err := codec.Unmarshal(body, &obj); err != nil {
+					return nil, nil, fmt.Errorf("parse body err:%w", err)
+				}
+			}
+			ctx.bodyFields = obj
+		}
+		switch v := ctx.bodyFields[name].(type) {
+		case nil:
+			return nil, nil, nil
+		case string:
+			return []string{v}, nil, nil
+		default:
+			return nil, v, nil
+		}
+	default:
+		if v, has := ctx.params[name]; has {
+			return []string{v}, nil, nil
+		}
+	}
+	paramVal, err := ctx.controller.Query(name)
+	if err != nil {
+		if optional {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("query err:%w", err)
+	}
+	if vs := req.Form[name]; len(vs) > 1 {
+		return vs, nil, nil
+	}
+	if s, ok := paramVal.(string); ok {
+		if s == "" {
+			return nil, nil, nil
+		}
+		return []string{s}, nil, nil
+	}
+	return nil, paramVal, nil
+}
+
+// bindArg converts the request values of an argument to typ.
+// raw is a value decoded from a json body, values are the strings of path, query and form.
+// A missing value binds the zero value, a nil pointer for pointer types.
+func bindArg(typ reflect.Type, values []string, raw interface{}, layouts []string) (reflect.Value, error) {
+	if raw != nil {
+		if s, ok := raw.(string); ok {
+			values = []string{s}
+		} else {
+			return bindJSON(typ, raw)
+		}
+	}
+	if len(values) == 0 {
+		return reflect.Zero(typ), nil
+	}
+	v := reflect.New(typ).Elem()
+	if err := bindValues(v, values, layouts); err != nil {
+		return v, err
+	}
+	return v, nil
+}
+
+func bindJSON(typ reflect.Type, raw interface{}) (reflect.Value, error) {
+	content, err := json.Marshal(raw)
+	if err != nil {
+		return reflect.Zero(typ), err
+	}
+	v := reflect.New(typ)
+	if err := json.Unmarshal(content, v.Interface()); err != nil {
+		return reflect.Zero(typ), err
+	}
+	return v.Elem(), nil
+}
+
+func bindValues(v reflect.Value, values []string, layouts []string) error {
+	t := v.Type()
+	if t.Kind() == reflect.Ptr {
+		elem := reflect.New(t.Elem())
+		if err := bindValues(elem.Elem(), values, layouts); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 && !reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		values = splitValues(values)
+		slice := reflect.MakeSlice(t, len(values), len(values))
+		for i, value := range values {
+			if err := bindValue(slice.Index(i), value, layouts); err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+	return bindValue(v, values[0], layouts)
+}
+
+// bindValue converts value to the kind of v
+func bindValue(v reflect.Value, value string, layouts []string) error {
+	t := v.Type()
+	if t.Kind() == reflect.Ptr {
+		elem := reflect.New(t.Elem())
+		if err := bindValue(elem.Elem(), value, layouts); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	switch {
+	case t == timeType:
+		tm, err := parseTime(value, layouts)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(tm))
+		return nil
+	case t == durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	case reflect.PtrTo(t).Implements(textUnmarshalerType):
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	case reflect.PtrTo(t).Implements(jsonUnmarshalerType):
+		u := v.Addr().Interface().(json.Unmarshaler)
+		if err := u.UnmarshalJSON([]byte(value)); err != nil {
+			// the value is not a json document, try it as a json string
+			return u.UnmarshalJSON([]byte(strconv.Quote(value)))
+		}
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := parseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x, err := strconv.ParseInt(value, 10, t.Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(x)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		x, err := strconv.ParseUint(value, 10, t.Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(x)
+	case reflect.Float32, reflect.Float64:
+		x, err := strconv.ParseFloat(value, t.Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(x)
+	case reflect.Slice:
+		// []byte
+		v.SetBytes([]byte(value))
+	case reflect.Interface:
+		if t.NumMethod() != 0 {
+			return fmt.Errorf("unsupport type %s", t)
+		}
+		v.Set(reflect.ValueOf(value))
+	default:
+		return fmt.Errorf("unsupport type %s", t)
+	}
+	return nil
+}
+
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "on", "yes":
+		return true, nil
+	case "off", "no":
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+func parseTime(value string, layouts []string) (time.Time, error) {
+	if len(layouts) == 0 {
+		layouts = DefaultTimeLayouts
+	}
+	var err error
+	for _, layout := range layouts {
+		var t time.Time
+		if t, err = time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// Args binds the arguments of a controller method without reflection for the common types,
+// it is used by the route invokers generated by webcmd. The first error stops the binding and is returned by Err.
+type Args struct {
+	ctx *WebContext
+	err error
+	// the values found by Has, so Bind of the same argument does not look them up again
+	name   string
+	values []string
+	raw    interface{}
+}
+
+// NewArgs creates the Args of the route request ctx
+func NewArgs(ctx *WebContext) *Args {
+	return &Args{ctx: ctx}
+}
+
+// Has reports whether the request has a value for the argument name, a lookup error is returned by Err
+func (a *Args) Has(name string) bool {
+	values, raw, err := requestValues(a.ctx, name, true)
+	if err != nil {
+		// a required value missing is an error as in the reflective binding
+		if a.err == nil {
+			a.err = err
+		}
+		return false
+	}
+	if len(values) == 0 && raw == nil {
+		return false
+	}
+	a.name, a.values, a.raw = name, values, raw
+	return true
+}
+
+// Bind converts the request value of name into dst, a missing value leaves dst unchanged.
+// Types other than the basic kinds, time.Time, time.Duration, []string, []int, []int64
+// and encoding.TextUnmarshaler are bound by reflection.
+func (a *Args) Bind(name string, dst interface{}) {
+	if a.err != nil {
+		return
+	}
+	var values []string
+	var raw interface{}
+	var err error
+	if a.name == name {
+		values, raw = a.values, a.raw
+		a.name, a.values, a.raw = "", nil, nil
+	} else {
+		values, raw, err = requestValues(a.ctx, name, false)
+	}
+	if err == nil {
+		if s, ok := raw.(string); ok {
+			values, raw = []string{s}, nil
+		}
+		if raw != nil {
+			var handled bool
+			if handled, err = bindRaw(dst, raw); !handled {
+				var v reflect.Value
+				if v, err = bindJSON(reflect.TypeOf(dst).Elem(), raw); err == nil {
+					reflect.ValueOf(dst).Elem().Set(v)
+				}
+			}
+		} else if len(values) > 0 {
+			err = bindTo(dst, values, a.ctx.Config().TimeLayouts)
+		}
+	}
+	if err != nil {
+		a.err = fmt.Errorf("argument %s convert failed: %w", name, err)
+	}
+}
+
+// bindRaw binds the numbers, booleans and arrays decoded from a body to the common types without reflection,
+// handled is false for the other values and types
+func bindRaw(dst interface{}, raw interface{}) (handled bool, err error) {
+	switch v := raw.(type) {
+	case float64:
+		switch dst.(type) {
+		case *int, *int32, *int64, *uint, *uint32, *uint64, *float32, *float64:
+			return true, bindTo(dst, []string{strconv.FormatFloat(v, 'f', -1, 64)}, nil)
+		}
+	case bool:
+		if d, ok := dst.(*bool); ok {
+			*d = v
+			return true, nil
+		}
+	case []interface{}:
+		switch d := dst.(type) {
+		case *[]string:
+			items := make([]string, len(v))
+			for i, item := range v {
+				s, ok := item.(string)
+				if !ok {
+					return false, nil
+				}
+				items[i] = s
+			}
+			*d = items
+			return true, nil
+		case *[]int, *[]int64:
+			items := make([]string, len(v))
+			for i, item := range v {
+				f, ok := item.(float64)
+				if !ok {
+					return false, nil
+				}
+				items[i] = strconv.FormatFloat(f, 'f', -1, 64)
+			}
+			return true, bindTo(dst, items, nil)
+		}
+	}
+	return false, nil
+}
+
+// Body parses the request body into dst by the ParseValid of the controller
+func (a *Args) Body(dst interface{}) {
+	if a.err != nil {
+		return
+	}
+	if err := parseBody(a.ctx, dst); err != nil {
+		a.err = fmt.Errorf("parse err:%w", err)
+	}
+}
+
+// Err returns the first binding error as a 400 HTTPError, nil when all arguments are bound
+func (a *Args) Err() error {
+	if a.err == nil {
+		return nil
+	}
+	return bindError(a.err)
+}
+
+func bindTo(dst interface{}, values []string, layouts []string) error {
+	value := values[0]
+	var err error
+	switch d := dst.(type) {
+	case *string:
+		*d = value
+	case *bool:
+		*d, err = parseBool(value)
+	case *int:
+		var x int64
+		x, err = strconv.ParseInt(value, 10, 0)
+		*d = int(x)
+	case *int32:
+		var x int64
+		x, err = strconv.ParseInt(value, 10, 32)
+		*d = int32(x)
+	case *int64:
+		*d, err = strconv.ParseInt(value, 10, 64)
+	case *uint:
+		var x uint64
+		x, err = strconv.ParseUint(value, 10, 0)
+		*d = uint(x)
+	case *uint32:
+		var x uint64
+		x, err = strconv.ParseUint(value, 10, 32)
+		*d = uint32(x)
+	case *uint64:
+		*d, err = strconv.ParseUint(value, 10, 64)
+	case *float32:
+		var x float64
+		x, err = strconv.ParseFloat(value, 32)
+		*d = float32(x)
+	case *float64:
+		*d, err = strconv.ParseFloat(value, 64)
+	case *time.Time:
+		*d, err = parseTime(value, layouts)
+	case *time.Duration:
+		*d, err = time.ParseDuration(value)
+	case *[]string:
+		*d = splitValues(values)
+	case *[]int:
+		items := splitValues(values)
+		*d = make([]int, len(items))
+		for i, item := range items {
+			if (*d)[i], err = strconv.Atoi(item); err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+		}
+	case *[]int64:
+		items := splitValues(values)
+		*d = make([]int64, len(items))
+		for i, item := range items {
+			if (*d)[i], err = strconv.ParseInt(item, 10, 64); err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+		}
+	case encoding.TextUnmarshaler:
+		err = d.UnmarshalText([]byte(value))
+	default:
+		return bindValues(reflect.ValueOf(dst).Elem(), values, layouts)
+	}
+	return err
+}
+
+// splitValues returns the repeated values, or the items of a single comma separated value
+func splitValues(values []string) []string {
+	if len(values) == 1 && strings.Contains(values[0], ",") {
+		values = strings.Split(values[0], ",")
+	}
+	items := make([]string, len(values))
+	for i, value := range values {
+		items[i] = strings.TrimSpace(value)
+	}
+	return items
+}
@@ -0,0 +1,90 @@
+package hiweb
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type binderTestController struct {
+	Controller
+}
+
+func (c *binderTestController) Search(active bool, id int64, page uint, score float64, since time.Time, ids []int, tags []string, ip net.IP, limit *int, timeout time.Duration) string {
+	l := "nil"
+	if limit != nil {
+		l = fmt.Sprint(*limit)
+	}
+	return fmt.Sprintf("%v %d %d %g %s %v %v %s %s %s", active, id, page, score, since.Format(formatDate), ids, tags, ip, l, timeout)
+}
+
+func TestBindParameters(t *testing.T) {
+	app := NewApp()
+	app.Route("/search", &binderTestController{}, "active;id;page;score;since;ids;tags;ip;limit;timeout", "get:Search", RouteOption{})
+	app.Route("/search", &binderTestController{}, "active;id;page;score;since;ids;tags;ip;limit;timeout", "post:Search", RouteOption{})
+
+	tests := []struct {
+		method, target, contentType, body string
+		status                            int
+		want                              string
+	}{
+		{http.MethodGet, "/search?active=true&id=9000000000&page=2&score=1.5&since=2020-01-02&ids=1&ids=2&tags=a,b&ip=10.0.0.1&limit=5&timeout=3s", "", "", http.StatusOK,
+			`"true 9000000000 2 1.5 2020-01-02 [1 2] [a b] 10.0.0.1 5 3s"`},
+		{http.MethodGet, "/search?since=2020-01-02T03:04:05Z&ids=1,2,3&ip=::1", "", "", http.StatusOK,
+			`"false 0 0 0 2020-01-02 [1 2 3] [] ::1 nil 0s"`},
+		{http.MethodPost, "/search", "application/json", `{"active":true,"id":1,"page":0,"score":2,"ids":[4,5],"tags":["x"],"since":"2021-03-04 05:06:07","ip":"::1","timeout":"1m"}`, http.StatusOK,
+			`"true 1 0 2 2021-03-04 [4 5] [x] ::1 nil 1m0s"`},
+		{http.MethodPost, "/search", "application/json", `{"active":true}`, http.StatusBadRequest, ""},
+		{http.MethodGet, "/search?page=-1", "", "", http.StatusBadRequest, ""},
+		{http.MethodGet, "/search?since=yesterday", "", "", http.StatusBadRequest, ""},
+		{http.MethodGet, "/search?ids=1,x", "", "", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Code != tt.status || (tt.want != "" && w.Body.String() != tt.want) {
+			t.Errorf("%s %s got %d %s want %d %s", tt.method, tt.target, w.Code, w.Body.String(), tt.status, tt.want)
+		}
+	}
+}
+
+func (c *binderTestController) Sources(traceID string, sid string, page int, name string) string {
+	return fmt.Sprintf("%s %s %d %s", traceID, sid, page, name)
+}
+
+func TestParamSources(t *testing.T) {
+	app := NewApp()
+	app.Route("/sources", &binderTestController{}, "traceId;sid;page;name", "post:Sources", RouteOption{
+		Params: map[string]ParamSpec{
+			"traceId": {In: SourceHeader, Required: true},
+			"sid":     {In: SourceCookie},
+			"page":    {In: SourceQuery},
+			"name":    {In: SourceForm},
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/sources?page=2&name=query&traceId=query", strings.NewReader("name=form&page=9"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("traceId", "t1")
+	req.AddCookie(&http.Cookie{Name: "sid", Value: "s1"})
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != `"t1 s1 2 form"` {
+		t.Errorf("sources got %d %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/sources?traceId=query", nil)
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "missing_param") {
+		t.Errorf("missing header got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,488 @@
+package hiweb
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+)
+
+// Codec encodes the responses and decodes the request bodies of a media type
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// ErrUnsupportedType is returned by the codecs for the values they can not encode or decode,
+// e.g. CSVCodec for a map. Render answers it with 406 Not Acceptable.
+var ErrUnsupportedType = errors.New("codec: unsupported type")
+
+// CodecRegistry is the codecs by media type, the order of registration is the order of preference
+// when the Accept header allows several of them
+type CodecRegistry struct {
+	mu         sync.RWMutex
+	mediaTypes []string
+	codecs     map[string]Codec
+}
+
+// NewCodecRegistry creates an empty CodecRegistry
+func NewCodecRegistry() *CodecRegistry {
+	return &CodecRegistry{codecs: make(map[string]Codec)}
+}
+
+// DefaultCodecs are the codecs of the apps without Config.Codecs: JSON, XML, YAML, MessagePack and CSV
+var DefaultCodecs = newDefaultCodecs()
+
+func newDefaultCodecs() *CodecRegistry {
+	r := NewCodecRegistry()
+	r.Register("application/json", JSONCodec{})
+	r.Register("application/xml", XMLCodec{})
+	r.Register("text/xml", XMLCodec{})
+	r.Register("application/yaml", YAMLCodec{})
+	r.Register("application/x-yaml", YAMLCodec{})
+	r.Register("text/yaml", YAMLCodec{})
+	r.Register("application/msgpack", MsgpackCodec{})
+	r.Register("application/x-msgpack", MsgpackCodec{})
+	r.Register("application/vnd.msgpack", MsgpackCodec{})
+	r.Register("text/csv", CSVCodec{})
+	return r
+}
+
+// Register adds the codec of mediaType, e.g. application/json, or replaces it keeping its preference
+func (r *CodecRegistry) Register(mediaType string, codec Codec) {
+	mediaType = strings.ToLower(mediaType)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.codecs == nil {
+		r.codecs = make(map[string]Codec)
+	}
+	if _, has := r.codecs[mediaType]; !has {
+		r.mediaTypes = append(r.mediaTypes, mediaType)
+	}
+	r.codecs[mediaType] = codec
+}
+
+// MediaTypes returns the media types in the order of preference
+func (r *CodecRegistry) MediaTypes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]string(nil), r.mediaTypes...)
+}
+
+// Lookup returns the media type and the codec of a Content-Type, the structured syntax suffixes
+// like application/problem+json use the codec of application/json. The codec is nil when none matches.
+func (r *CodecRegistry) Lookup(contentType string) (string, Codec) {
+	mediaType := parseMediaType(contentType)
+	if mediaType == "" {
+		return "", nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if codec, has := r.codecs[mediaType]; has {
+		return mediaType, codec
+	}
+	if i := strings.LastIndexByte(mediaType, '+'); i >= 0 {
+		if codec, has := r.codecs["application/"+mediaType[i+1:]]; has {
+			return mediaType, codec
+		}
+	}
+	return mediaType, nil
+}
+
+// parseMediaType returns the lower case media type of a Content-Type without its parameters
+func parseMediaType(contentType string) string {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mediaType
+	}
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+// codecs returns Config.Codecs, DefaultCodecs when it is nil
+func (c *WebContext) codecs() *CodecRegistry {
+	if codecs := c.Config().Codecs; codecs != nil {
+		return codecs
+	}
+	return DefaultCodecs
+}
+
+// negotiateCodec returns the codec preferred by the Accept header among the media types of the route,
+// RouteOption.Produces or all the codecs, and the first of them when the header accepts none
+func (c *WebContext) negotiateCodec() (string, Codec) {
+	registry := c.codecs()
+	offers := registry.MediaTypes()
+	if c.option != nil && len(c.option.Produces) > 0 {
+		offers = offers[:0]
+		for _, mediaType := range c.option.Produces {
+			if _, codec := registry.Lookup(mediaType); codec != nil {
+				offers = append(offers, parseMediaType(mediaType))
+			}
+		}
+	}
+	if len(offers) == 0 {
+		return "application/json", JSONCodec{}
+	}
+	mediaType := Negotiate(c.GetHeader("Accept"), offers...)
+	if mediaType == "" {
+		mediaType = offers[0]
+	}
+	_, codec := registry.Lookup(mediaType)
+	return mediaType, codec
+}
+
+// checkConsumes rejects with 415 the request bodies whose media type is not in RouteOption.Consumes
+func (c *WebContext) checkConsumes() error {
+	if c.option == nil || len(c.option.Consumes) == 0 {
+		return nil
+	}
+	mediaType := parseMediaType(c.GetHeader("Content-Type"))
+	for _, consume := range c.option.Consumes {
+		if parseMediaType(consume) == mediaType {
+			return nil
+		}
+	}
+	return &HTTPError{
+		Status:  http.StatusUnsupportedMediaType,
+		Code:    "unsupported_media_type",
+		Message: fmt.Sprintf("content type %q is not one of %s", mediaType, strings.Join(c.option.Consumes, ", ")),
+	}
+}
+
+// decodeBody decodes the request body into v by the codec of its Content-Type,
+// handled is false when no codec matches the Content-Type
+func (c *WebContext) decodeBody(v interface{}) (handled bool, err error) {
+	mediaType, codec := c.codecs().Lookup(c.GetHeader("Content-Type"))
+	if codec == nil {
+		return false, nil
+	}
+	body, err := c.GetBody()
+	if err != nil {
+		return true, err
+	}
+	if err := codec.Unmarshal(body, v); err != nil {
+		if errors.Is(err, ErrUnsupportedType) {
+			return true, &HTTPError{Status: http.StatusUnsupportedMediaType, Code: "unsupported_media_type", Message: fmt.Sprintf("content type %s is not supported here", mediaType), Cause: err}
+		}
+		return true, err
+	}
+	return true, nil
+}
+
+// contentTypeOf adds the charset to the textual media types
+func contentTypeOf(mediaType string) string {
+	if strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "json") ||
+		strings.HasSuffix(mediaType, "xml") || strings.HasSuffix(mediaType, "yaml") {
+		return mediaType + "; charset=utf-8"
+	}
+	return mediaType
+}
+
+// JSONCodec encodes with encoding/json, the bodies must be json objects or arrays
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	data = bytes.TrimSpace(data)
+	if !IsJSONBody(data) {
+		return fmt.Errorf("input not json")
+	}
+	return json.Unmarshal(data, v)
+}
+
+// xmlListElement is the root element of the slices encoded by XMLCodec
+const xmlListElement = "items"
+
+// XMLCodec encodes with encoding/xml, a slice is encoded as the children of an <items> element
+type XMLCodec struct{}
+
+func (XMLCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	var err error
+	if (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && rv.Type().Elem().Kind() != reflect.Uint8 {
+		start := xml.StartElement{Name: xml.Name{Local: xmlListElement}}
+		err = enc.EncodeToken(start)
+		for i := 0; err == nil && i < rv.Len(); i++ {
+			err = enc.Encode(rv.Index(i).Interface())
+		}
+		if err == nil {
+			err = enc.EncodeToken(start.End())
+		}
+	} else {
+		err = enc.Encode(v)
+	}
+	if err == nil {
+		err = enc.Flush()
+	}
+	var unsupported *xml.UnsupportedTypeError
+	if errors.As(err, &unsupported) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (XMLCodec) Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("%w: xml decodes into a pointer, not %T", ErrUnsupportedType, v)
+	}
+	list := rv.Elem()
+	switch list.Kind() {
+	case reflect.Map, reflect.Interface:
+		return fmt.Errorf("%w: xml does not decode into %T", ErrUnsupportedType, v)
+	case reflect.Slice:
+		if list.Type().Elem().Kind() != reflect.Uint8 {
+			break
+		}
+		fallthrough
+	default:
+		return xml.Unmarshal(data, v)
+	}
+	// the children of the root element are the items
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	depth := 0
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				depth++
+				continue
+			}
+			item := reflect.New(list.Type().Elem())
+			if err := dec.DecodeElement(item.Interface(), &t); err != nil {
+				return err
+			}
+			list.Set(reflect.Append(list, item.Elem()))
+		case xml.EndElement:
+			depth--
+		}
+	}
+}
+
+// YAMLCodec encodes with the json names of the fields, like JSONCodec
+type YAMLCodec struct{}
+
+func (YAMLCodec) Marshal(v interface{}) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+
+func (YAMLCodec) Unmarshal(data []byte, v interface{}) error {
+	return yaml.Unmarshal(data, v)
+}
+
+// CSVCodec encodes a slice of structs, or a struct, as a header row and a row by struct.
+// The columns are named by the csv tags of the fields, the json names otherwise.
+type CSVCodec struct{}
+
+type csvField struct {
+	name  string
+	index []int
+}
+
+// csvFields returns the columns of the struct type t, the fields of the embedded structs are flattened
+func csvFields(t reflect.Type, index []int) []csvField {
+	var fields []csvField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, tagged := f.Tag.Lookup("csv")
+		if !tagged {
+			name = f.Tag.Get("json")
+		}
+		name = strings.Split(name, ",")[0]
+		if name == "-" || (f.PkgPath != "" && !f.Anonymous) {
+			continue
+		}
+		fieldIndex := append(append([]int(nil), index...), i)
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			fields = append(fields, csvFields(f.Type, fieldIndex)...)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, csvField{name: name, index: fieldIndex})
+	}
+	return fields
+}
+
+// csvElem returns the struct type of the elements of a slice type, e.g. []Order or []*Order
+func csvElem(t reflect.Type) (reflect.Type, bool) {
+	if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+		return nil, false
+	}
+	elem := t.Elem()
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	return elem, elem.Kind() == reflect.Struct && elem != timeType
+}
+
+func (CSVCodec) Marshal(v interface{}) ([]byte, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() == reflect.Struct && rv.Type() != timeType {
+		one := reflect.MakeSlice(reflect.SliceOf(rv.Type()), 1, 1)
+		one.Index(0).Set(rv)
+		rv = one
+	}
+	elem, ok := csvElem(rv.Type())
+	if !ok {
+		return nil, fmt.Errorf("%w: csv encodes slices of structs, not %T", ErrUnsupportedType, v)
+	}
+	fields := csvFields(elem, nil)
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	record := make([]string, len(fields))
+	for i, f := range fields {
+		record[i] = f.name
+	}
+	if err := w.Write(record); err != nil {
+		return nil, err
+	}
+	for row := 0; row < rv.Len(); row++ {
+		item := reflect.Indirect(rv.Index(row))
+		for i, f := range fields {
+			record[i] = ""
+			if !item.IsValid() {
+				continue
+			}
+			cell, err := csvCell(item.FieldByIndex(f.index))
+			if err != nil {
+				return nil, fmt.Errorf("csv: row %d column %s: %w", row+1, f.name, err)
+			}
+			record[i] = cell
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func csvCell(v reflect.Value) (string, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	if (v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.IsNil() {
+		return "", nil
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.Format(time.RFC3339Nano), nil
+	}
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		text, err := m.MarshalText()
+		return string(text), err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), nil
+	}
+	// the nested values are written as json
+	content, err := json.Marshal(v.Interface())
+	return string(content), err
+}
+
+func (CSVCodec) Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("%w: csv decodes into a pointer, not %T", ErrUnsupportedType, v)
+	}
+	list := rv.Elem()
+	elem, ok := csvElem(list.Type())
+	if !ok || list.Kind() != reflect.Slice {
+		return fmt.Errorf("%w: csv decodes into slices of structs, not %T", ErrUnsupportedType, v)
+	}
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	byName := make(map[string]csvField)
+	for _, f := range csvFields(elem, nil) {
+		byName[f.name] = f
+	}
+	header := records[0]
+	for row, record := range records[1:] {
+		item := reflect.New(elem).Elem()
+		for i, cell := range record {
+			f, has := byName[strings.TrimSpace(header[i])]
+			if !has || cell == "" {
+				continue
+			}
+			if err := setCSVCell(item.FieldByIndex(f.index), cell); err != nil {
+				return fmt.Errorf("csv: line %d column %s: %w", row+2, f.name, err)
+			}
+		}
+		if list.Type().Elem().Kind() == reflect.Ptr {
+			item = item.Addr()
+		}
+		list.Set(reflect.Append(list, item))
+	}
+	return nil
+}
+
+func setCSVCell(v reflect.Value, cell string) error {
+	t := v.Type()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	nested := false
+	switch t.Kind() {
+	case reflect.Map, reflect.Array:
+		nested = true
+	case reflect.Slice:
+		nested = t.Elem().Kind() != reflect.Uint8
+	case reflect.Struct:
+		nested = t != timeType
+	}
+	if nested && !reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return json.Unmarshal([]byte(cell), v.Addr().Interface())
+	}
+	return bindValue(v, cell, DefaultTimeLayouts)
+}
@@ -0,0 +1,206 @@
+package hiweb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+type codecTestOrder struct {
+	XMLName xml.Name          `json:"-" xml:"order" csv:"-"`
+	ID      int64             `json:"id" xml:"id"`
+	Name    string            `json:"name" xml:"name"`
+	Price   float64           `json:"price" xml:"price"`
+	Paid    *bool             `json:"paid,omitempty" xml:"paid,omitempty"`
+	Created time.Time         `json:"created" xml:"created"`
+	Tags    []string          `json:"tags,omitempty" xml:"tag" csv:"tags"`
+	Extra   map[string]string `json:"extra,omitempty" xml:"-" csv:"-"`
+}
+
+type codecTestController struct {
+	Controller
+}
+
+func (c *codecTestController) List() ([]codecTestOrder, error) {
+	return []codecTestOrder{{ID: 1, Name: "o1", Price: 9.5, Created: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)}}, nil
+}
+
+func (c *codecTestController) Stats() (map[string]int, error) {
+	return map[string]int{"orders": 2}, nil
+}
+
+func (c *codecTestController) Create(order codecTestOrder) (codecTestOrder, error) {
+	return order, nil
+}
+
+func (c *codecTestController) Rename(id int64, name string) (string, error) {
+	return name, nil
+}
+
+func (c *codecTestController) Import() error {
+	var orders []*codecTestOrder
+	if err := c.ParseValid(&orders); err != nil {
+		return err
+	}
+	return c.Render(http.StatusCreated, orders)
+}
+
+func TestCodecs(t *testing.T) {
+	paid := true
+	orders := []codecTestOrder{
+		{ID: 1, Name: "o1", Price: 9.5, Paid: &paid, Created: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC), Tags: []string{"a", "b"}},
+		{ID: 2, Name: "o, \"2\"", Price: 10, Created: time.Date(2024, 5, 2, 8, 0, 0, 0, time.UTC)},
+	}
+	for _, mediaType := range DefaultCodecs.MediaTypes() {
+		_, codec := DefaultCodecs.Lookup(mediaType)
+		content, err := codec.Marshal(orders)
+		if err != nil {
+			t.Fatalf("%s marshal err:%s", mediaType, err)
+		}
+		var decoded []codecTestOrder
+		if err := codec.Unmarshal(content, &decoded); err != nil {
+			t.Fatalf("%s unmarshal err:%s\n%s", mediaType, err, content)
+		}
+		for i := range decoded {
+			decoded[i].XMLName = xml.Name{}
+		}
+		if !reflect.DeepEqual(decoded, orders) {
+			t.Errorf("%s round trip got %+v\n%s", mediaType, decoded, content)
+		}
+	}
+
+	content, _ := CSVCodec{}.Marshal(orders)
+	want := "id,name,price,paid,created,tags\n1,o1,9.5,true,2024-05-01T08:00:00Z,\"[\"\"a\"\",\"\"b\"\"]\"\n2,\"o, \"\"2\"\"\",10,,2024-05-02T08:00:00Z,\n"
+	if string(content) != want {
+		t.Errorf("csv got\n%s", content)
+	}
+	content, _ = XMLCodec{}.Marshal(orders[1:])
+	if !strings.HasPrefix(string(content), xml.Header+"<items><order><id>2</id>") {
+		t.Errorf("xml got %s", content)
+	}
+	if _, err := (CSVCodec{}).Marshal(map[string]int{"a": 1}); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("csv of a map err:%v", err)
+	}
+	if _, err := (XMLCodec{}).Marshal(map[string]int{"a": 1}); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("xml of a map err:%v", err)
+	}
+
+	for _, c := range []struct{ contentType, mediaType string }{
+		{"application/json; charset=utf-8", "application/json"},
+		{"application/problem+json", "application/problem+json"},
+		{"Application/XML", "application/xml"},
+		{"application/vnd.api+yaml", "application/vnd.api+yaml"},
+	} {
+		if mediaType, codec := DefaultCodecs.Lookup(c.contentType); mediaType != c.mediaType || codec == nil {
+			t.Errorf("lookup %s got %s %v", c.contentType, mediaType, codec)
+		}
+	}
+	if _, codec := DefaultCodecs.Lookup("text/plain"); codec != nil {
+		t.Error("text/plain has a codec")
+	}
+}
+
+func TestMsgpackCodec(t *testing.T) {
+	content, err := MsgpackCodec{}.Marshal(map[string]interface{}{"a": 1, "b": []interface{}{-1, 300, "x", true, nil, 1.5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(content); got != "82a16101a16296ffcd012ca178c3c0cb3ff8000000000000" {
+		t.Errorf("msgpack got %s", got)
+	}
+	// {"id": 1 as uint16, "name": bin "ab", "created": timestamp 32 of 1714550400, "tags": ["t"]}
+	data, _ := hex.DecodeString("84a26964cd0001a46e616d65c4026162a763726561746564d6ff66317580a47461677391a174")
+	var order struct {
+		ID      int       `json:"id"`
+		Name    []byte    `json:"name"`
+		Created time.Time `json:"created"`
+		Tags    []string  `json:"tags"`
+	}
+	if err := (MsgpackCodec{}).Unmarshal(data, &order); err != nil {
+		t.Fatal(err)
+	}
+	if order.ID != 1 || string(order.Name) != "ab" || !order.Created.Equal(time.Unix(1714517376, 0)) || len(order.Tags) != 1 {
+		t.Errorf("msgpack decoded %+v", order)
+	}
+	for _, bad := range []string{"", "92", "dd7fffffff", "c1", "0101", "d4017f"} {
+		data, _ := hex.DecodeString(bad)
+		var v interface{}
+		if err := (MsgpackCodec{}).Unmarshal(data, &v); err == nil {
+			t.Errorf("invalid msgpack %s is decoded", bad)
+		}
+	}
+
+	// nested array32 headers claiming the size of the body must not allocate by the claimed lengths
+	body := bytes.Repeat([]byte{0xc1}, 1<<20)
+	for i := 0; i < 100; i++ {
+		body[i*5] = 0xdd
+		binary.BigEndian.PutUint32(body[i*5+1:], uint32(len(body)-i*5-5))
+	}
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	before := stats.TotalAlloc
+	var v interface{}
+	if err := (MsgpackCodec{}).Unmarshal(body, &v); err == nil {
+		t.Error("invalid nested arrays are decoded")
+	}
+	runtime.ReadMemStats(&stats)
+	if allocated := stats.TotalAlloc - before; allocated > 64<<20 {
+		t.Errorf("nested array headers allocated %d bytes", allocated)
+	}
+}
+
+func TestRenderNegotiation(t *testing.T) {
+	app := NewApp()
+	app.Route("/orders", &codecTestController{}, "", "get:List", RouteOption{})
+	app.Route("/stats", &codecTestController{}, "", "get:Stats", RouteOption{})
+	app.Route("/only", &codecTestController{}, "", "get:List", RouteOption{Produces: []string{"text/csv", "application/xml"}})
+	app.Route("/orders", &codecTestController{}, "order", "post:Create", RouteOption{})
+	app.Route("/strict", &codecTestController{}, "order", "post:Create", RouteOption{Consumes: []string{"application/yaml"}})
+	app.Route("/rename", &codecTestController{}, "id;name", "post:Rename", RouteOption{})
+	app.Route("/import", &codecTestController{}, "", "post:Import", RouteOption{})
+	send := func(method, path, contentType, accept, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w
+	}
+	cases := []struct {
+		method, path, contentType, accept, body string
+		status                                  int
+		responseType, prefix                    string
+	}{
+		{"GET", "/orders", "", "", "", 200, "application/json; charset=utf-8", `[{"id":1`},
+		{"GET", "/orders", "", "application/xml;q=0.5, application/yaml", "", 200, "application/yaml; charset=utf-8", "- created:"},
+		{"GET", "/orders", "", "text/csv, */*;q=0.1", "", 200, "text/csv; charset=utf-8", "id,name,price"},
+		{"GET", "/orders", "", "application/msgpack", "", 200, "application/msgpack", "\x91"},
+		{"GET", "/orders", "", "text/html", "", 200, "application/json; charset=utf-8", "["},
+		{"GET", "/stats", "", "text/csv", "", 406, "application/problem+json", "{"},
+		{"GET", "/only", "", "application/json, application/xml;q=0.9", "", 200, "application/xml; charset=utf-8", "<?xml"},
+		{"GET", "/only", "", "", "", 200, "text/csv; charset=utf-8", "id,"},
+		{"POST", "/orders", "application/xml", "application/json", "<order><id>7</id><name>x</name></order>", 200, "application/json; charset=utf-8", `{"id":7,"name":"x"`},
+		{"POST", "/orders", "application/x-yaml", "application/json", "id: 8\nname: w\n", 200, "application/json; charset=utf-8", `{"id":8,"name":"w"`},
+		{"POST", "/orders", "application/msgpack", "application/json", "\x82\xa2id\x09\xa4name\xa1z", 200, "application/json; charset=utf-8", `{"id":9,"name":"z"`},
+		{"POST", "/strict", "application/json", "", `{"id":1}`, 415, "application/problem+json", "{"},
+		{"POST", "/strict", "application/yaml", "", "id: 1", 200, "application/json; charset=utf-8", `{"id":1`},
+		{"POST", "/rename", "application/yaml", "", "id: 1\nname: n1", 200, "application/json; charset=utf-8", `"n1"`},
+		{"POST", "/import", "text/csv", "text/csv", "name,id\na,1\nb,2\n", 201, "text/csv; charset=utf-8", "id,name,price,paid,created,tags\n1,a,0,,0001-01-01T00:00:00Z,\n2,b,0,,0001-01-01T00:00:00Z,\n"},
+		{"POST", "/import", "application/xml", "", "<items><order><id>1</id></order></items>", 201, "application/json; charset=utf-8", `[{"id":1`},
+	}
+	for _, c := range cases {
+		w := send(c.method, c.path, c.contentType, c.accept, c.body)
+		if w.Code != c.status || w.Header().Get("Content-Type") != c.responseType || !bytes.HasPrefix(w.Body.Bytes(), []byte(c.prefix)) {
+			t.Errorf("%s %s %s accept %s got %d %s %q", c.method, c.path, c.contentType, c.accept, w.Code, w.Header().Get("Content-Type"), w.Body.String())
+		}
+	}
+}
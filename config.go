@@ -1,23 +1,106 @@
 package hiweb
 
+import "time"
+
 type Config struct {
-	EnableGzip  bool
-	SecretKey   string
-	Logger      Logger
+	EnableGzip bool
+	// SecretKey signs the session cookies, and the jwt tokens when JWT is nil. App.Run fails while it is the default
+	SecretKey string
+	// JWT signs and validates the jwt tokens, HS256 with SecretKey when nil
+	JWT    *JWTConfig
+	Logger Logger
+	// FilterIpMap denies its keys, ip addresses or CIDR networks.
+	// Deprecated: use IPAccess.
 	FilterIpMap map[string]int
-	AuthHandler func(context *WebContext) error
-	paramMap    map[string]interface{}
+	// IPAccess allows the client ips of the routes, routes select another one by RouteOption.IPAccess
+	IPAccess *IPAccess
+	// TrustedProxies are the proxies whose Forwarded, X-Forwarded-For or X-Real-IP give WebContext.RemoteIP,
+	// e.g. hiweb.MustParseIPList("10.0.0.0/8"); without them the headers are ignored as they can be spoofed
+	TrustedProxies *IPList
+	AuthHandler    func(context *WebContext) error
+	// Authorizer checks the roles and scopes of the routes against the claims, ClaimsAuthorizer when nil
+	Authorizer Authorizer
+	// ErrorHandler writes the errors of the routes, DefaultErrorHandler when nil
+	ErrorHandler func(context *WebContext, err error)
+	// CORS is the cross-origin policy of all the routes, nil disables CORS headers
+	CORS *CORSConfig
+	// Codecs decode the request bodies and encode the responses by media type, DefaultCodecs when nil
+	Codecs *CodecRegistry
+	// TimeLayouts are the layouts tried in order to bind time.Time arguments, DefaultTimeLayouts when empty
+	TimeLayouts []string
+	// ReadTimeout, ReadHeaderTimeout, WriteTimeout and IdleTimeout are the timeouts of the server started by App.Run
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// RequestTimeout is the deadline of the request context of the routes without RouteOption.Timeout, 0 is none.
+	// A route which fails or returns a result after its deadline gets 503 Service Unavailable. The timeout is
+	// cooperative: the handler keeps running until it returns, and a response written before that is sent.
+	RequestTimeout time.Duration
+	// MaxBodySize is the limit of the request bodies of the routes without RouteOption.MaxBody, 0 is unlimited.
+	// A larger body gets 413 Request Entity Too Large.
+	MaxBodySize int64
+	// MaxMultipartMemory is how many bytes of a multipart form are kept in memory, the rest go to temporary files
+	MaxMultipartMemory int64
+	// RateLimiter gives the Key, Algorithm and Store of the routes with RouteOption.RateLimit, its Rate is not used.
+	// The routes key by the remote ip and share a MemoryRateLimitStore when it is nil.
+	RateLimiter *RateLimiter
+	// ShutdownTimeout is how long the in-flight requests are drained after SIGINT or SIGTERM
+	ShutdownTimeout time.Duration
+	// HealthPath and ReadyPath are the liveness and readiness endpoints registered by App.Run, empty disables them
+	HealthPath string
+	ReadyPath  string
+	// MetricsPath serves App.Metrics in the Prometheus text format when it is not empty, it is registered by App.Run
+	MetricsPath string
+	// JWKSPath publishes the public keys of JWT.Keys when it is not empty, e.g. DefaultJWKSPath, it is registered by App.Run
+	JWKSPath string
+	// SessionStore stores the sessions, a MemorySessionStore when nil
+	SessionStore SessionStore
+	// SessionTTL is the idle timeout of the sessions, it is refreshed on every read
+	SessionTTL time.Duration
+	// SessionAbsoluteTimeout is the lifetime of the sessions from their creation, 0 is unlimited
+	SessionAbsoluteTimeout time.Duration
+	// SessionCookie is the cookie carrying the signed session id of WebContext.Session
+	SessionCookie SessionCookieConfig
+	paramMap      map[string]interface{}
 }
 
+// WebConfig is the config of the default app
 var WebConfig Config
 
 func init() {
-	WebConfig.SecretKey = "asdfsadfwexczv asfwe"
-	WebConfig.EnableGzip = true
-	WebConfig.Logger = &DefaultLogger{}
-	WebConfig.AuthHandler = nil
-	WebConfig.FilterIpMap = make(map[string]int)
-	WebConfig.paramMap = make(map[string]interface{})
+	WebConfig = *NewConfig()
+}
+
+// NewConfig creates a Config with default values
+func NewConfig() *Config {
+	return &Config{
+		SecretKey:   defaultSecretKey,
+		EnableGzip:  true,
+		Logger:      &DefaultLogger{},
+		AuthHandler: nil,
+		FilterIpMap: make(map[string]int),
+		CORS:        DefaultCORSConfig(),
+		TimeLayouts: append([]string(nil), DefaultTimeLayouts...),
+
+		ReadTimeout:       30 * time.Second,
+		ReadHeaderTimeout: 10 * time.Second,
+		WriteTimeout:      60 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		ShutdownTimeout:   30 * time.Second,
+		HealthPath:        "/healthz",
+		ReadyPath:         "/readyz",
+		MetricsPath:       "/metrics",
+		SessionTTL:        time.Hour,
+
+		MaxBodySize:        64 << 20,
+		MaxMultipartMemory: 64 << 20,
+
+		SessionAbsoluteTimeout: 24 * time.Hour,
+		SessionCookie:          DefaultSessionCookieConfig(),
+
+		paramMap: make(map[string]interface{}),
+	}
 }
 
 func (c *Config) SetParam(key string, val interface{}) {
@@ -1,26 +1,174 @@
 package hiweb
 
 import (
+	"bytes"
+	"context"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
 )
 
 type WebContext struct {
 	Request        *http.Request
 	ResponseWriter http.ResponseWriter
 	Body           []byte
+
+	app        *App
+	params     map[string]string
+	controller ControllerInterface
+	option     *RouteOption
+	session    *Session
+	claims     jwt.MapClaims
+	valuesMu   sync.RWMutex
+	values     map[string]interface{}
+	// bodyTooLarge is set when the body passes the limit of the route
+	bodyTooLarge bool
+	requestID    string
+	route        string
+	logger       FieldLogger
+	recorder     *ResponseRecorder
+	done         []func(ctx *WebContext)
+	// bodyFields is the request body decoded by its codec for the SourceBody arguments
+	bodyFields map[string]interface{}
+}
+
+func newWebContext(app *App, writer http.ResponseWriter, req *http.Request) *WebContext {
+	return &WebContext{
+		Request:        req,
+		ResponseWriter: writer,
+		Body:           []byte{},
+		app:            app,
+	}
+}
+
+// App returns the app which serves the request, the default app if the context is not created by an app
+func (c *WebContext) App() *App {
+	if c.app == nil {
+		return defaultApp
+	}
+	return c.app
+}
+
+// Controller returns the controller of the route, nil outside of controller routes
+func (c *WebContext) Controller() ControllerInterface {
+	return c.controller
+}
+
+// Config returns the config of the app which serves the request
+func (c *WebContext) Config() *Config {
+	return c.App().Config
+}
+
+// Context returns the context of the request, it is done when the client goes away or the timeout of the route passes.
+// Pass it to the database and http calls so they stop with the request.
+func (c *WebContext) Context() context.Context {
+	return c.Request.Context()
+}
+
+// SetContext replaces the context of the request, e.g. by a middleware adding values for the downstream calls
+func (c *WebContext) SetContext(ctx context.Context) {
+	c.Request = c.Request.WithContext(ctx)
+}
+
+// withTimeout adds the deadline of timeout to the context of the request
+func (c *WebContext) withTimeout(timeout time.Duration) context.CancelFunc {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	c.SetContext(ctx)
+	return cancel
+}
+
+// Set stores a value for the rest of the request, e.g. the user loaded by a middleware
+func (c *WebContext) Set(key string, value interface{}) {
+	c.valuesMu.Lock()
+	defer c.valuesMu.Unlock()
+	if c.values == nil {
+		c.values = make(map[string]interface{})
+	}
+	c.values[key] = value
+}
+
+// Get returns the value stored by Set
+func (c *WebContext) Get(key string) (interface{}, bool) {
+	c.valuesMu.RLock()
+	defer c.valuesMu.RUnlock()
+	value, has := c.values[key]
+	return value, has
+}
+
+// GetString returns the string stored by Set, "" when it is missing or not a string
+func (c *WebContext) GetString(key string) string {
+	value, _ := c.Get(key)
+	s, _ := value.(string)
+	return s
+}
+
+// GetInt returns the int stored by Set, 0 when it is missing or not an int
+func (c *WebContext) GetInt(key string) int {
+	value, _ := c.Get(key)
+	i, _ := value.(int)
+	return i
+}
+
+// GetBool returns the bool stored by Set, false when it is missing or not a bool
+func (c *WebContext) GetBool(key string) bool {
+	value, _ := c.Get(key)
+	b, _ := value.(bool)
+	return b
+}
+
+// PathParam returns the value of the route path placeholder, e.g. orderId of /orders/{orderId}
+func (c *WebContext) PathParam(key string) string {
+	return c.params[key]
+}
+
+// PathParams returns all the route path placeholder values
+func (c *WebContext) PathParams() map[string]string {
+	return c.params
 }
 
 func (c *WebContext) GetHeader(key string) string {
 	return c.Request.Header.Get(key)
 }
 
+// GetRemoteAddr returns WebContext.RemoteIP.
+// Deprecated: use RemoteIP.
 func (c *WebContext) GetRemoteAddr() string {
-	header := c.Request.Header.Get("X-Forwarded-For")
-	if header == "" {
-		return c.Request.RemoteAddr
+	return c.RemoteIP()
+}
+
+// RemoteIP returns the ip of the client, the host of RemoteAddr, or the address forwarded by the proxy
+// when RemoteAddr is in Config.TrustedProxies
+func (c *WebContext) RemoteIP() string {
+	return clientIP(c.Request, c.Config().TrustedProxies)
+}
+
+// ServeBody writes content compressed by the Accept-Encoding of the request when Config.EnableGzip
+func (c *WebContext) ServeBody(status int, content []byte) error {
+	var encoding string
+	var buf = &bytes.Buffer{}
+	if c.Config().EnableGzip {
+		encoding = ParseEncoding(c.Request)
+	}
+	headers := c.ResponseWriter.Header()
+	if b, n, _ := WriteBody(encoding, buf, content); b {
+		c.App().serverMetrics().observeCompression(n, len(content), buf.Len())
+		headers.Set("Content-Encoding", n)
+		headers.Set("Content-Length", strconv.Itoa(buf.Len()))
+	} else {
+		headers.Set("Content-Length", strconv.Itoa(len(content)))
+	}
+	// Write status code if it has been set manually
+	// Set it to 0 afterwards to prevent "multiple response.WriteHeader calls"
+	if status != 0 {
+		c.ResponseWriter.WriteHeader(status)
 	}
-	return header
+	_, err := io.Copy(c.ResponseWriter, buf)
+	return err
 }
 
 func (c *WebContext) GetBody() ([]byte, error) {
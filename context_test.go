@@ -0,0 +1,77 @@
+package hiweb
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type contextTestKey struct{}
+
+type contextTestController struct {
+	Controller
+}
+
+func (c *contextTestController) Wait(ctx context.Context, name string) (string, error) {
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-time.After(200 * time.Millisecond):
+	}
+	return name + ":" + c.Ctx.GetString("user") + ":" + ctx.Value(contextTestKey{}).(string), nil
+}
+
+func TestRequestContext(t *testing.T) {
+	app := NewApp()
+	app.Use(func(next Next) Next {
+		return func(ctx *WebContext) error {
+			ctx.Set("user", "u1")
+			ctx.Set("n", 3)
+			ctx.SetContext(context.WithValue(ctx.Context(), contextTestKey{}, "trace1"))
+			return next(ctx)
+		}
+	})
+	app.Route("/wait", &contextTestController{}, "name", "get:Wait", RouteOption{})
+	app.Route("/timeout", &contextTestController{}, "name", "get:Wait", RouteOption{Timeout: 50 * time.Millisecond})
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/wait?name=a", nil))
+	if w.Body.String() != `"a:u1:trace1"` {
+		t.Errorf("wait got %d %s", w.Code, w.Body.String())
+	}
+
+	start := time.Now()
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/timeout?name=a", nil))
+	if w.Code != http.StatusServiceUnavailable || time.Since(start) > 150*time.Millisecond {
+		t.Errorf("timeout got %d after %s", w.Code, time.Since(start))
+	}
+
+	// a cancelled client stops the method
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/wait?name=a", nil).WithContext(ctx)
+	done := make(chan struct{})
+	go func() {
+		app.ServeHTTP(httptest.NewRecorder(), req)
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(150 * time.Millisecond):
+		t.Error("cancelled request is not stopped")
+	}
+
+	c := newWebContext(app, httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	c.Set("n", 3)
+	c.Set("ok", true)
+	if c.GetInt("n") != 3 || !c.GetBool("ok") || c.GetString("n") != "" {
+		t.Errorf("typed values got %d %v %q", c.GetInt("n"), c.GetBool("ok"), c.GetString("n"))
+	}
+	if _, has := c.Get("missing"); has {
+		t.Error("missing value is found")
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"os"
@@ -43,7 +42,7 @@ func (c *Controller) Input() url.Values {
 	if c.Ctx.Request.Form == nil {
 		err := c.Ctx.Request.ParseForm()
 		if err != nil {
-			WebConfig.Logger.Error(err)
+			c.Ctx.Logger().Error(err)
 		}
 	}
 	return c.Ctx.Request.Form
@@ -53,57 +52,52 @@ func (c *Controller) Init(ctx *WebContext) {
 	c.Ctx = ctx
 }
 
+// CheckAuth validates the bearer token of the Authorization header by App.JwtClaims and keeps its claims
 func (c *Controller) CheckAuth() (bool, error) {
-	token, err := request.ParseFromRequest(c.Ctx.Request, request.AuthorizationHeaderExtractor,
-		func(token *jwt.Token) (interface{}, error) {
-			return []byte(WebConfig.SecretKey), nil
-		})
-	if err == nil {
-		if token.Valid {
-			if claims, ok := token.Claims.(jwt.MapClaims); ok {
-				c.Claims = claims
-			}
-			return true, nil
-		} else {
-			return false, fmt.Errorf("Token is not valid")
-		}
-	} else {
+	tokenString, err := request.AuthorizationHeaderExtractor.ExtractToken(c.Ctx.Request)
+	if err != nil {
 		return false, fmt.Errorf("Unauthorized access to this resource")
 	}
+	claims, err := c.Ctx.App().JwtClaims(tokenString)
+	if err != nil {
+		return false, err
+	}
+	c.Claims = claims
+	c.Ctx.SetClaims(claims)
+	return true, nil
 }
 
+// Session returns the session of the request, see WebContext.Session
+func (c *Controller) Session() (*Session, error) {
+	return c.Ctx.Session()
+}
+
+// GetClaim returns a claim of the token checked by CheckAuth, or of the claims set by a Config.AuthHandler
 func (c *Controller) GetClaim(key string) interface{} {
-	if v, h := c.Claims[key]; h {
+	claims := c.Claims
+	if claims == nil {
+		claims = c.Ctx.Claims()
+	}
+	if v, h := claims[key]; h {
 		return v
 	} else {
 		return nil
 	}
 }
 
-// ParseValid maps input data map to obj struct.include(form,json)
+// ParseValid maps input data map to obj struct.include(form, and the bodies of Config.Codecs: json, xml, yaml, msgpack, csv)
 func (c *Controller) ParseValid(obj interface{}, vs ...*validator.Validate) error {
 	contentType := c.Ctx.GetHeader("Content-Type")
-	if strings.HasPrefix(contentType, "application/json") ||
-		strings.HasPrefix(contentType, "application/*+json") {
-		tBody, err := c.GetBody()
+	if strings.HasPrefix(contentType, "multipart/form-data") {
+		err := c.Ctx.parseMultipartForm()
 		if err != nil {
 			return err
 		}
-		requestBody := bytes.TrimSpace(tBody)
-		if len(requestBody) != 0 && IsJSONBody(requestBody) {
-			err = json.Unmarshal(requestBody, obj)
-			if err != nil {
-				return err
-			}
-		} else {
-			return fmt.Errorf("input not json")
-		}
-	} else if strings.HasPrefix(contentType, "multipart/form-data") {
-		err := c.Ctx.Request.ParseMultipartForm(1 << 26)
+		err = ParseForm(c.Input(), obj)
 		if err != nil {
 			return err
 		}
-		err = ParseForm(c.Input(), obj)
+	} else if handled, err := c.Ctx.decodeBody(obj); handled {
 		if err != nil {
 			return err
 		}
@@ -132,6 +126,7 @@ func (c *Controller) Param(key string) string {
 	return ""
 }
 
+// ParseJson reads the fields of a json body into JsonParam, and of the bodies of the other codecs which decode maps
 func (c *Controller) ParseJson() error {
 	if c.JsonParam == nil {
 		c.JsonParam = make(map[string]interface{})
@@ -140,16 +135,23 @@ func (c *Controller) ParseJson() error {
 			return err
 		}
 		requestBody := bytes.TrimSpace(tBody)
+		obj := make(map[string]interface{})
 		if len(requestBody) != 0 && IsJSONBody(requestBody) {
-			obj := make(map[string]interface{})
 			err := json.Unmarshal(requestBody, &obj)
 			if err != nil {
 				return err
 			}
-			for k, v := range obj {
-				c.JsonParam[k] = v
+		} else if _, codec := c.Ctx.codecs().Lookup(c.Ctx.GetHeader("Content-Type")); len(requestBody) != 0 && codec != nil {
+			// the bodies of the other codecs, e.g. yaml or msgpack
+			if _, isJSON := codec.(JSONCodec); !isJSON {
+				if err := codec.Unmarshal(requestBody, &obj); err != nil {
+					return err
+				}
 			}
 		}
+		for k, v := range obj {
+			c.JsonParam[k] = v
+		}
 	}
 	return nil
 }
@@ -164,8 +166,7 @@ func (c *Controller) Query(key string, def ...interface{}) (interface{}, error)
 	}
 
 	contentType := c.Ctx.GetHeader("Content-Type")
-	if strings.HasPrefix(contentType, "application/json") ||
-		strings.HasPrefix(contentType, "application/*+json") {
+	if _, codec := c.Ctx.codecs().Lookup(contentType); codec != nil {
 		err := c.ParseJson()
 		if err != nil {
 			return "", err
@@ -176,7 +177,7 @@ func (c *Controller) Query(key string, def ...interface{}) (interface{}, error)
 			return "", fmt.Errorf("not found:%s", key)
 		}
 	} else if strings.HasPrefix(contentType, "multipart/form-data") {
-		err := c.Ctx.Request.ParseMultipartForm(1 << 26)
+		err := c.Ctx.parseMultipartForm()
 		if err != nil {
 			return "", err
 		}
@@ -255,6 +256,11 @@ func (c *Controller) ServeJSON(status int, obj interface{}) error {
 	return c.JSON(status, obj, true, false)
 }
 
+// Render writes v with the media type negotiated by the Accept header, see WebContext.Render
+func (c *Controller) Render(status int, v interface{}) error {
+	return c.Ctx.Render(status, v)
+}
+
 func (c *Controller) JSON(status int, data interface{}, hasIndent bool, coding bool) error {
 	c.SetHeader("Content-Type", "application/json; charset=utf-8")
 	var content []byte
@@ -275,24 +281,7 @@ func (c *Controller) JSON(status int, data interface{}, hasIndent bool, coding b
 }
 
 func (c *Controller) ServeBody(status int, content []byte) error {
-	var encoding string
-	var buf = &bytes.Buffer{}
-	if WebConfig.EnableGzip {
-		encoding = ParseEncoding(c.Ctx.Request)
-	}
-	if b, n, _ := WriteBody(encoding, buf, content); b {
-		c.SetHeader("Content-Encoding", n)
-		c.SetHeader("Content-Length", strconv.Itoa(buf.Len()))
-	} else {
-		c.SetHeader("Content-Length", strconv.Itoa(len(content)))
-	}
-	// Write status code if it has been set manually
-	// Set it to 0 afterwards to prevent "multiple response.WriteHeader calls"
-	if status != 0 {
-		c.Ctx.ResponseWriter.WriteHeader(status)
-	}
-	_, err := io.Copy(c.Ctx.ResponseWriter, buf)
-	return err
+	return c.Ctx.ServeBody(status, content)
 }
 
 //ServeDownload
@@ -0,0 +1,155 @@
+package hiweb
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CORSConfig is the cross-origin resource sharing policy of an app
+type CORSConfig struct {
+	// AllowOrigins are the allowed origins, an origin is exact ("https://example.com"),
+	// a wildcard subdomain ("https://*.example.com") or "*" for any origin
+	AllowOrigins []string
+	// AllowOriginPatterns are regular expressions of the allowed origins, they must match the whole origin
+	AllowOriginPatterns []string
+	// AllowMethods are the methods allowed by preflight requests
+	AllowMethods []string
+	// AllowHeaders are the request headers allowed by preflight requests,
+	// when empty the headers requested by the preflight request are allowed
+	AllowHeaders []string
+	// ExposeHeaders are the response headers the browser can read
+	ExposeHeaders []string
+	// MaxAge is the seconds the preflight response can be cached, 0 is not sent
+	MaxAge int
+	// AllowCredentials allows cookies and authorization headers, the origin is then echoed instead of "*"
+	AllowCredentials bool
+}
+
+// DefaultCORSConfig allows any origin without credentials
+func DefaultCORSConfig() *CORSConfig {
+	return &CORSConfig{
+		AllowOrigins:  []string{"*"},
+		AllowMethods:  []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead},
+		ExposeHeaders: []string{"Content-Disposition"},
+	}
+}
+
+var corsRegexps sync.Map
+
+func corsRegexp(pattern string) *regexp.Regexp {
+	if re, has := corsRegexps.Load(pattern); has {
+		return re.(*regexp.Regexp)
+	}
+	re, err := regexp.Compile(`^(?:` + pattern + `)$`)
+	if err != nil {
+		// an invalid pattern matches nothing
+		re = regexp.MustCompile(`[^\x00-\x{10FFFF}]`)
+	}
+	corsRegexps.Store(pattern, re)
+	return re
+}
+
+// AllowOrigin reports whether origin is allowed by the policy
+func (c *CORSConfig) AllowOrigin(origin string) bool {
+	for _, o := range c.AllowOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+		if i := strings.Index(o, "*"); i >= 0 {
+			prefix, suffix := o[:i], o[i+1:]
+			if len(origin) > len(prefix)+len(suffix) &&
+				strings.HasPrefix(strings.ToLower(origin), strings.ToLower(prefix)) &&
+				strings.HasSuffix(strings.ToLower(origin), strings.ToLower(suffix)) {
+				return true
+			}
+		}
+	}
+	for _, pattern := range c.AllowOriginPatterns {
+		if corsRegexp(pattern).MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CORSConfig) allowAnyOrigin() bool {
+	for _, o := range c.AllowOrigins {
+		if o == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CORSConfig) allowMethod(method string) bool {
+	for _, m := range c.AllowMethods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// CORS applies Config.CORS to the requests, preflight requests are answered without calling the next handlers.
+// The app uses it for every route, a nil Config.CORS disables it.
+func CORS() Middleware {
+	return func(next Next) Next {
+		return func(ctx *WebContext) error {
+			cors := ctx.Config().CORS
+			if cors == nil {
+				return next(ctx)
+			}
+			headers := ctx.ResponseWriter.Header()
+			req := ctx.Request
+			origin := req.Header.Get("Origin")
+			preflight := req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != ""
+			anyOrigin := cors.allowAnyOrigin() && !cors.AllowCredentials
+			if !anyOrigin {
+				headers.Add("Vary", "Origin")
+			}
+			if origin == "" || !cors.AllowOrigin(origin) {
+				if preflight {
+					ctx.ResponseWriter.WriteHeader(http.StatusForbidden)
+					return nil
+				}
+				return next(ctx)
+			}
+			if anyOrigin {
+				headers.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				headers.Set("Access-Control-Allow-Origin", origin)
+			}
+			if cors.AllowCredentials {
+				headers.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if !preflight {
+				if len(cors.ExposeHeaders) > 0 {
+					headers.Set("Access-Control-Expose-Headers", strings.Join(cors.ExposeHeaders, ", "))
+				}
+				return next(ctx)
+			}
+
+			headers.Add("Vary", "Access-Control-Request-Method")
+			headers.Add("Vary", "Access-Control-Request-Headers")
+			method := req.Header.Get("Access-Control-Request-Method")
+			if !cors.allowMethod(method) {
+				ctx.ResponseWriter.WriteHeader(http.StatusForbidden)
+				return nil
+			}
+			headers.Set("Access-Control-Allow-Methods", strings.Join(cors.AllowMethods, ", "))
+			if len(cors.AllowHeaders) > 0 {
+				headers.Set("Access-Control-Allow-Headers", strings.Join(cors.AllowHeaders, ", "))
+			} else if requestHeaders := req.Header.Get("Access-Control-Request-Headers"); requestHeaders != "" {
+				headers.Set("Access-Control-Allow-Headers", requestHeaders)
+			}
+			if cors.MaxAge > 0 {
+				headers.Set("Access-Control-Max-Age", strconv.Itoa(cors.MaxAge))
+			}
+			ctx.ResponseWriter.WriteHeader(http.StatusNoContent)
+			return nil
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package hiweb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSAllowOrigin(t *testing.T) {
+	cors := &CORSConfig{
+		AllowOrigins:        []string{"https://example.com", "https://*.example.org"},
+		AllowOriginPatterns: []string{`^http://localhost:\d+$`, `https://.*\.example\.net`},
+	}
+	tests := map[string]bool{
+		"https://example.com":     true,
+		"https://EXAMPLE.com":     true,
+		"https://a.example.org":   true,
+		"https://example.org":     false,
+		"https://a.example.org.x": false,
+		"http://localhost:8080":   true,
+		"http://localhost":        false,
+		"https://evil.com":        false,
+		"https://a.example.net":   true,
+		"https://a.example.net.x": false,
+		"http://a.example.net":    false,
+	}
+	for origin, allowed := range tests {
+		if cors.AllowOrigin(origin) != allowed {
+			t.Errorf("origin %s allowed should be %v", origin, allowed)
+		}
+	}
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	app := NewApp(func(c *Config) {
+		c.CORS = &CORSConfig{
+			AllowOrigins:     []string{"https://*.example.com"},
+			AllowMethods:     []string{"GET", "POST"},
+			AllowHeaders:     []string{"Authorization", "Content-Type"},
+			ExposeHeaders:    []string{"Content-Disposition"},
+			MaxAge:           600,
+			AllowCredentials: true,
+		}
+	})
+	app.HandleFunc("/orders", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodOptions, "/orders", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	headers := w.Header()
+	if w.Code != http.StatusNoContent ||
+		headers.Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		headers.Get("Access-Control-Allow-Credentials") != "true" ||
+		headers.Get("Access-Control-Allow-Methods") != "GET, POST" ||
+		headers.Get("Access-Control-Allow-Headers") != "Authorization, Content-Type" ||
+		headers.Get("Access-Control-Max-Age") != "600" ||
+		headers.Get("Vary") != "Origin" {
+		t.Errorf("preflight got %d %v", w.Code, headers)
+	}
+
+	req = httptest.NewRequest(http.MethodOptions, "/orders", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "DELETE")
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("preflight of a not allowed method got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set("Origin", "https://evil.com")
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "" || w.Header().Get("Vary") != "Origin" {
+		t.Errorf("not allowed origin got %d %v", w.Code, w.Header())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		w.Header().Get("Access-Control-Expose-Headers") != "Content-Disposition" {
+		t.Errorf("allowed origin got %v", w.Header())
+	}
+}
@@ -0,0 +1,145 @@
+package hiweb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// HTTPError is an error with the http status of the response.
+// The default ErrorHandler writes it as an RFC 7807 application/problem+json document.
+type HTTPError struct {
+	// Status is the http status code
+	Status int
+	// Code is a machine readable error code, e.g. invalid_param
+	Code string
+	// Message is the human readable detail sent to the client
+	Message string
+	// Details are extra data sent to the client, e.g. the invalid fields
+	Details interface{}
+	// Cause is the underlying error, it is logged but not sent to the client
+	Cause error
+}
+
+// NewHTTPError creates an HTTPError
+func NewHTTPError(status int, code string, message string) *HTTPError {
+	return &HTTPError{Status: status, Code: code, Message: message}
+}
+
+// WrapHTTPError creates an HTTPError caused by err
+func WrapHTTPError(status int, code string, err error) *HTTPError {
+	return &HTTPError{Status: status, Code: code, Message: err.Error(), Cause: err}
+}
+
+func (e *HTTPError) Error() string {
+	if e.Cause != nil && e.Cause.Error() != e.Message {
+		return fmt.Sprintf("%d %s: %s: %s", e.Status, e.Code, e.Message, e.Cause)
+	}
+	return fmt.Sprintf("%d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// Unwrap returns the cause of the error
+func (e *HTTPError) Unwrap() error {
+	return e.Cause
+}
+
+// StatusCode returns the http status of the error
+func (e *HTTPError) StatusCode() int {
+	return e.Status
+}
+
+// ProblemDetails is the RFC 7807 problem document
+type ProblemDetails struct {
+	Type     string      `json:"type"`
+	Title    string      `json:"title"`
+	Status   int         `json:"status"`
+	Detail   string      `json:"detail,omitempty"`
+	Instance string      `json:"instance,omitempty"`
+	Code     string      `json:"code,omitempty"`
+	Details  interface{} `json:"details,omitempty"`
+}
+
+// ToHTTPError converts any error to an HTTPError, the status of other errors is ErrorStatus
+// and the message of server errors is not exposed.
+func ToHTTPError(err error) *HTTPError {
+	var he *HTTPError
+	if errors.As(err, &he) {
+		return he
+	}
+	status := ErrorStatus(err)
+	message := err.Error()
+	if status >= http.StatusInternalServerError {
+		message = http.StatusText(status)
+	}
+	return &HTTPError{Status: status, Code: "", Message: message, Cause: err}
+}
+
+// DefaultErrorHandler writes err as application/problem+json
+func DefaultErrorHandler(ctx *WebContext, err error) {
+	he := ToHTTPError(err)
+	problem := ProblemDetails{
+		Type:     "about:blank",
+		Title:    http.StatusText(he.Status),
+		Status:   he.Status,
+		Detail:   he.Message,
+		Instance: ctx.Request.URL.Path,
+		Code:     he.Code,
+		Details:  he.Details,
+	}
+	content, mErr := json.Marshal(problem)
+	if mErr != nil {
+		http.Error(ctx.ResponseWriter, he.Message, he.Status)
+		return
+	}
+	headers := ctx.ResponseWriter.Header()
+	headers.Set("Content-Type", "application/problem+json")
+	headers.Set("X-Content-Type-Options", "nosniff")
+	ctx.ResponseWriter.WriteHeader(he.Status)
+	_, _ = ctx.ResponseWriter.Write(content)
+}
+
+// handleError logs err and writes it by Config.ErrorHandler, only logs it when the response is already written
+func (app *App) handleError(ctx *WebContext, err error) {
+	status := ErrorStatus(err)
+	level := LevelWarning
+	if status >= http.StatusInternalServerError {
+		level = LevelError
+	}
+	if ctx.responseWritten() {
+		ctx.Logger().Log(level, "request error after the response is written", "path", ctx.Request.URL.Path, "status", ctx.Response().Status(), "error", err)
+		return
+	}
+	ctx.Logger().Log(level, "request error", "path", ctx.Request.URL.Path, "status", status, "error", err)
+	handler := app.Config.ErrorHandler
+	if handler == nil {
+		handler = DefaultErrorHandler
+	}
+	handler(ctx, err)
+}
+
+// FieldError is the detail of an invalid struct field
+type FieldError struct {
+	Field string `json:"field"`
+	Tag   string `json:"tag"`
+	Param string `json:"param,omitempty"`
+}
+
+// bindError converts an argument binding error to a 400 HTTPError, validation errors carry the invalid fields
+func bindError(err error) *HTTPError {
+	var he *HTTPError
+	if errors.As(err, &he) {
+		return he
+	}
+	var ves validator.ValidationErrors
+	if errors.As(err, &ves) {
+		fields := make([]FieldError, 0, len(ves))
+		for _, fe := range ves {
+			fields = append(fields, FieldError{Field: fe.Namespace(), Tag: fe.Tag(), Param: fe.Param()})
+		}
+		return &HTTPError{Status: http.StatusBadRequest, Code: "validation_failed", Message: "validation failed", Details: fields, Cause: err}
+	}
+	return WrapHTTPError(http.StatusBadRequest, "invalid_param", err)
+}
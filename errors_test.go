@@ -0,0 +1,88 @@
+package hiweb
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type errorsTestController struct {
+	Controller
+}
+
+func (c *errorsTestController) Get(id int) error {
+	return &HTTPError{Status: http.StatusConflict, Code: "order_locked", Message: "order is locked", Details: map[string]int{"id": id}, Cause: errors.New("row lock")}
+}
+
+func (c *errorsTestController) Fail() error {
+	return errors.New("database password is wrong")
+}
+
+func (c *errorsTestController) Partial() error {
+	if err := c.ServeBody(http.StatusOK, []byte(`{"ok":true}`)); err != nil {
+		return err
+	}
+	return errors.New("audit log is down")
+}
+
+func errorsTestProblem(t *testing.T, app *App, method, path string) (ProblemDetails, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	var problem ProblemDetails
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("%s %s body %s err:%s", method, path, w.Body.String(), err)
+	}
+	if w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("%s %s content type %s", method, path, w.Header().Get("Content-Type"))
+	}
+	return problem, w
+}
+
+func TestErrorResponses(t *testing.T) {
+	app := NewApp()
+	app.Route("/orders/{id}", &errorsTestController{}, "id", "get:Get", RouteOption{})
+	app.Route("/fail", &errorsTestController{}, "", "post:Fail", RouteOption{})
+	app.Route("/partial", &errorsTestController{}, "", "get:Partial", RouteOption{})
+
+	problem, w := errorsTestProblem(t, app, http.MethodGet, "/orders/7")
+	if w.Code != http.StatusConflict || problem.Code != "order_locked" || problem.Detail != "order is locked" || problem.Instance != "/orders/7" {
+		t.Errorf("http error got %d %+v", w.Code, problem)
+	}
+
+	problem, w = errorsTestProblem(t, app, http.MethodGet, "/orders/x")
+	if w.Code != http.StatusBadRequest || problem.Code != "invalid_param" {
+		t.Errorf("bind error got %d %+v", w.Code, problem)
+	}
+
+	problem, w = errorsTestProblem(t, app, http.MethodPost, "/fail")
+	if w.Code != http.StatusInternalServerError || problem.Detail != http.StatusText(http.StatusInternalServerError) {
+		t.Errorf("internal error got %d %+v", w.Code, problem)
+	}
+
+	problem, w = errorsTestProblem(t, app, http.MethodGet, "/fail")
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "POST" {
+		t.Errorf("method not allowed got %d %+v", w.Code, problem)
+	}
+
+	problem, w = errorsTestProblem(t, app, http.MethodGet, "/missing")
+	if w.Code != http.StatusNotFound || problem.Code != "not_found" {
+		t.Errorf("not found got %d %+v", w.Code, problem)
+	}
+
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/partial", nil))
+	if w.Code != http.StatusOK || w.Body.String() != `{"ok":true}` {
+		t.Errorf("error after the response got %d %s", w.Code, w.Body.String())
+	}
+
+	app.Config.ErrorHandler = func(ctx *WebContext, err error) {
+		ctx.ResponseWriter.WriteHeader(ToHTTPError(err).Status)
+	}
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/1", nil))
+	if w.Code != http.StatusConflict || w.Body.Len() != 0 {
+		t.Errorf("custom error handler got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -7,17 +7,10 @@ import (
 	"bytes"
 	"encoding/json"
 	"github.com/autumnzw/hiweb"
-	"net/http"
 
 	"github.com/alecthomas/template"
 )
 
-func init() {
-	http.HandleFunc("/swag/", hiweb.Handler(
-		hiweb.URL("./swagger.json", "example"), //The url pointing to API definition"
-	))
-}
-
 var doc = `{
     "openapi": "3.0.1",
     "info": {
@@ -48,6 +41,21 @@ var doc = `{
                                 "$ref": "#/components/schemas/UserCredentials"
                             }
                         },
+                        "application/msgpack": {
+                            "schema": {
+                                "$ref": "#/components/schemas/UserCredentials"
+                            }
+                        },
+                        "application/xml": {
+                            "schema": {
+                                "$ref": "#/components/schemas/UserCredentials"
+                            }
+                        },
+                        "application/yaml": {
+                            "schema": {
+                                "$ref": "#/components/schemas/UserCredentials"
+                            }
+                        },
                         "text/json": {
                             "schema": {
                                 "$ref": "#/components/schemas/UserCredentials"
@@ -59,11 +67,55 @@ var doc = `{
                     "200": {
                         "description": "Success"
                     },
+                    "400": {
+                        "description": "Bad Request",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
                     "401": {
-                        "description": "Unauthorized"
+                        "description": "Unauthorized",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
                     },
                     "403": {
-                        "description": "Forbidden"
+                        "description": "Forbidden",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
                     }
                 }
             }
@@ -78,11 +130,55 @@ var doc = `{
                     "200": {
                         "description": "Success"
                     },
+                    "400": {
+                        "description": "Bad Request",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
                     "401": {
-                        "description": "Unauthorized"
+                        "description": "Unauthorized",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
                     },
                     "403": {
-                        "description": "Forbidden"
+                        "description": "Forbidden",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
                     }
                 },
                 "security": [
@@ -95,14 +191,51 @@ var doc = `{
     },
     "components": {
         "schemas": {
+            "ProblemDetails": {
+                "type": "object",
+                "properties": {
+                    "code": {
+                        "type": "string",
+                        "items": {}
+                    },
+                    "detail": {
+                        "type": "string",
+                        "items": {}
+                    },
+                    "details": {
+                        "type": "object",
+                        "items": {}
+                    },
+                    "instance": {
+                        "type": "string",
+                        "items": {}
+                    },
+                    "status": {
+                        "type": "integer",
+                        "items": {},
+                        "format": "int32"
+                    },
+                    "title": {
+                        "type": "string",
+                        "items": {}
+                    },
+                    "type": {
+                        "type": "string",
+                        "items": {}
+                    }
+                },
+                "additionalProperties": true
+            },
             "UserCredentials": {
                 "type": "object",
                 "properties": {
                     "password": {
-                        "type": "string"
+                        "type": "string",
+                        "items": {}
                     },
                     "username": {
-                        "type": "string"
+                        "type": "string",
+                        "items": {}
                     }
                 },
                 "additionalProperties": false
@@ -150,14 +283,48 @@ func (s *s) ReadDoc() string {
 }
 
 func init() {
-	hiweb.SwaggerRegister(&s{})
+	RegisterRoutes(hiweb.Default())
+}
+
+// RegisterRoutes registers the swagger document and the controller routes on app
+func RegisterRoutes(app *hiweb.App) {
+	app.SwaggerRegister(&s{})
+	app.HandleFunc("/swag/", app.SwaggerHandler(
+		hiweb.URL("./swagger.json", "example"), //The url pointing to API definition"
+	))
 
 	token := Token{}
 
-	hiweb.Route("/Token/GenToken", &token, "", "post:GenToken", hiweb.RouteOption{IsAuth: false})
+	app.Route("/Token/GenToken", &token, "userIn", "post:GenToken", hiweb.RouteOption{IsAuth: false, New: hiwebNewToken, Invoke: hiwebInvokeTokenGenToken})
 
 	user := User{}
 
-	hiweb.Route("/User/GetUser", &user, "", "post:GetUser", hiweb.RouteOption{IsAuth: true})
+	app.Route("/User/GetUser", &user, "", "post:GetUser", hiweb.RouteOption{IsAuth: true, New: hiwebNewUser, Invoke: hiwebInvokeUserGetUser})
+
+}
+
+func hiwebNewToken() hiweb.ControllerInterface {
+	return &Token{}
+}
+
+func hiwebInvokeTokenGenToken(ctx *hiweb.WebContext) error {
+	c := ctx.Controller().(*Token)
+	args := hiweb.NewArgs(ctx)
+	var arg0 UserCredentials
+	args.Body(&arg0)
+	if err := args.Err(); err != nil {
+		return err
+	}
+	c.GenToken(arg0)
+	return nil
+}
+
+func hiwebNewUser() hiweb.ControllerInterface {
+	return &User{}
+}
 
+func hiwebInvokeUserGetUser(ctx *hiweb.WebContext) error {
+	c := ctx.Controller().(*User)
+	c.GetUser()
+	return nil
 }
@@ -6,3 +6,4 @@ require (
 	github.com/alecthomas/template v0.0.0-20190718012654-fb15b899a751
 	github.com/autumnzw/hiweb v0.0.0-20200724021019-a33480cda067
 )
+replace github.com/autumnzw/hiweb => ../
@@ -5,7 +5,6 @@ import (
 	"fmt"
 
 	"flag"
-	"net/http"
 
 	"github.com/autumnzw/hiweb"
 )
@@ -21,7 +20,7 @@ func main() {
 	hiweb.WebConfig.SecretKey = "asdfsvasf"
 	hiweb.RouteFiles("/", "./dist")
 	fmt.Printf("start web %s:%s dir:%s", *ip, *port, "./dist")
-	e := http.ListenAndServe(*ip+":"+*port, nil)
+	e := hiweb.Run(*ip + ":" + *port)
 	if e != nil {
 		fmt.Printf("err:%s", e)
 	}
@@ -0,0 +1,165 @@
+package hiweb
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type invokerTestController struct {
+	Controller
+}
+
+func (c *invokerTestController) Get(id int, name string, limit *int) (*renderTestUser, error) {
+	if limit != nil {
+		id += *limit
+	}
+	return &renderTestUser{ID: id, Name: name}, nil
+}
+
+func invokerTestNew() ControllerInterface {
+	return &invokerTestController{}
+}
+
+// invokerTestGet is written as webcmd generates the invokers
+func invokerTestGet(ctx *WebContext) error {
+	c := ctx.Controller().(*invokerTestController)
+	args := NewArgs(ctx)
+	var arg0 int
+	args.Bind("id", &arg0)
+	var arg1 string
+	args.Bind("name", &arg1)
+	var arg2 *int
+	if args.Has("limit") {
+		arg2 = new(int)
+		args.Bind("limit", arg2)
+	}
+	if err := args.Err(); err != nil {
+		return err
+	}
+	v, err := c.Get(arg0, arg1, arg2)
+	return RenderResult(ctx, v, err)
+}
+
+func invokerTestApp() *App {
+	app := NewApp()
+	app.Route("/reflect/{id:int}", &invokerTestController{}, "id;name;limit", "get:Get", RouteOption{})
+	app.Route("/invoke/{id:int}", &invokerTestController{}, "id;name;limit", "get:Get", RouteOption{New: invokerTestNew, Invoke: invokerTestGet})
+	required := map[string]ParamSpec{"limit": {In: SourceQuery, Required: true}}
+	app.Route("/reflect-required/{id:int}", &invokerTestController{}, "id;name;limit", "get:Get", RouteOption{Params: required})
+	app.Route("/invoke-required/{id:int}", &invokerTestController{}, "id;name;limit", "get:Get", RouteOption{Params: required, New: invokerTestNew, Invoke: invokerTestGet})
+	return app
+}
+
+func TestRouteInvoker(t *testing.T) {
+	app := invokerTestApp()
+	for _, query := range []string{"?name=u", "?name=u&limit=2", "?limit=x"} {
+		var bodies [2]string
+		var codes [2]int
+		for i, prefix := range []string{"/reflect/3", "/invoke/3"} {
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, prefix+query, nil))
+			bodies[i], codes[i] = w.Body.String(), w.Code
+		}
+		if codes[0] != codes[1] || (codes[0] == http.StatusOK && bodies[0] != bodies[1]) {
+			t.Errorf("%s reflect %d %s invoke %d %s", query, codes[0], bodies[0], codes[1], bodies[1])
+		}
+	}
+	for _, prefix := range []string{"/reflect-required/3", "/invoke-required/3"} {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, prefix+"?name=u", nil))
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "missing_param") {
+			t.Errorf("%s without the required limit got %d %s", prefix, w.Code, w.Body.String())
+		}
+	}
+}
+
+func benchmarkRoute(b *testing.B, target string) {
+	app := invokerTestApp()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req.Form = nil
+		app.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
+
+func BenchmarkRouteReflect(b *testing.B) {
+	benchmarkRoute(b, "/reflect/3?name=u&limit=2")
+}
+
+func BenchmarkRouteInvoker(b *testing.B) {
+	benchmarkRoute(b, "/invoke/3?name=u&limit=2")
+}
+
+type invokerTestCountingCodec struct {
+	JSONCodec
+	decodes *int
+}
+
+func (c invokerTestCountingCodec) Unmarshal(data []byte, v interface{}) error {
+	*c.decodes++
+	return c.JSONCodec.Unmarshal(data, v)
+}
+
+func (c *invokerTestController) Body(count int, ok bool, tags []string, ids []int64, limit *int) (string, error) {
+	return fmt.Sprint(count, ok, tags, ids, *limit), nil
+}
+
+// invokerTestBody is written as webcmd generates the invokers
+func invokerTestBody(ctx *WebContext) error {
+	c := ctx.Controller().(*invokerTestController)
+	args := NewArgs(ctx)
+	var arg0 int
+	args.Bind("count", &arg0)
+	var arg1 bool
+	args.Bind("ok", &arg1)
+	var arg2 []string
+	args.Bind("tags", &arg2)
+	var arg3 []int64
+	args.Bind("ids", &arg3)
+	var arg4 *int
+	if args.Has("limit") {
+		arg4 = new(int)
+		args.Bind("limit", arg4)
+	}
+	if err := args.Err(); err != nil {
+		return err
+	}
+	v, err := c.Body(arg0, arg1, arg2, arg3, arg4)
+	return RenderResult(ctx, v, err)
+}
+
+func TestRouteInvokerBody(t *testing.T) {
+	decodes := 0
+	codecs := NewCodecRegistry()
+	codecs.Register("application/json", invokerTestCountingCodec{decodes: &decodes})
+	app := NewApp(func(c *Config) { c.Codecs = codecs })
+	params := map[string]ParamSpec{}
+	for _, name := range []string{"count", "ok", "tags", "ids", "limit"} {
+		params[name] = ParamSpec{In: SourceBody}
+	}
+	app.Route("/reflect", &invokerTestController{}, "count;ok;tags;ids;limit", "post:Body", RouteOption{Params: params})
+	app.Route("/invoke", &invokerTestController{}, "count;ok;tags;ids;limit", "post:Body", RouteOption{Params: params, New: invokerTestNew, Invoke: invokerTestBody})
+	for _, body := range []string{`{"count":3,"ok":true,"tags":["a,b","c"],"ids":[1,2],"limit":4}`, `{"count":1.5,"limit":1}`, `{"ids":["x"],"limit":1}`} {
+		var bodies [2]string
+		var codes [2]int
+		for i, path := range []string{"/reflect", "/invoke"} {
+			decodes = 0
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, req)
+			bodies[i], codes[i] = w.Body.String(), w.Code
+			if decodes != 1 {
+				t.Errorf("%s %s decoded the body %d times", path, body, decodes)
+			}
+		}
+		if codes[0] != codes[1] || bodies[0] != bodies[1] && codes[0] == http.StatusOK {
+			t.Errorf("%s reflect %d %s invoke %d %s", body, codes[0], bodies[0], codes[1], bodies[1])
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package hiweb
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// IPList is a list of ip addresses and CIDR networks, a nil list contains nothing
+type IPList struct {
+	nets []*net.IPNet
+}
+
+// ParseIPList parses entries like 10.0.0.0/8, 192.168.1.7 or 2001:db8::/32
+func ParseIPList(entries ...string) (*IPList, error) {
+	list := &IPList{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			_, network, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("ip list: %w", err)
+			}
+			list.nets = append(list.nets, network)
+			continue
+		}
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, fmt.Errorf("ip list: %q is not an ip address or a CIDR network", entry)
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		list.nets = append(list.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return list, nil
+}
+
+// MustParseIPList is ParseIPList which panics on invalid entries, e.g. for Config.TrustedProxies
+func MustParseIPList(entries ...string) *IPList {
+	list, err := ParseIPList(entries...)
+	if err != nil {
+		panic(err)
+	}
+	return list
+}
+
+// Len returns the number of the entries
+func (l *IPList) Len() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.nets)
+}
+
+// Contains reports whether ip is in one of the entries
+func (l *IPList) Contains(ip net.IP) bool {
+	if l == nil || ip == nil {
+		return false
+	}
+	for _, network := range l.nets {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *IPList) String() string {
+	if l == nil {
+		return ""
+	}
+	entries := make([]string, len(l.nets))
+	for i, network := range l.nets {
+		entries[i] = network.String()
+	}
+	return strings.Join(entries, ",")
+}
+
+// IPAccess allows the client ips by an allow list and a deny list. The deny list wins, and an empty allow list
+// allows every ip not denied. The lists can be reloaded while the requests are served.
+type IPAccess struct {
+	rules atomic.Value
+}
+
+type ipRules struct {
+	allow, deny *IPList
+}
+
+// NewIPAccess creates an IPAccess with the allow and deny entries, see ParseIPList
+func NewIPAccess(allow, deny []string) (*IPAccess, error) {
+	access := &IPAccess{}
+	if err := access.Reload(allow, deny); err != nil {
+		return nil, err
+	}
+	return access, nil
+}
+
+// Reload replaces the lists, the old lists are kept when an entry is invalid
+func (a *IPAccess) Reload(allow, deny []string) error {
+	allowList, err := ParseIPList(allow...)
+	if err != nil {
+		return err
+	}
+	denyList, err := ParseIPList(deny...)
+	if err != nil {
+		return err
+	}
+	a.rules.Store(ipRules{allow: allowList, deny: denyList})
+	return nil
+}
+
+// Allowed reports whether ip passes the lists, an ip which is not parsed passes only without an allow list
+func (a *IPAccess) Allowed(ip net.IP) bool {
+	rules, _ := a.rules.Load().(ipRules)
+	if rules.deny.Contains(ip) {
+		return false
+	}
+	return rules.allow.Len() == 0 || rules.allow.Contains(ip)
+}
+
+// LoadFile reloads the lists from a file of lines like "allow 10.0.0.0/8" or "deny 203.0.113.7",
+// the empty lines and the lines starting with # are skipped
+func (a *IPAccess) LoadFile(filename string) error {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var allow, deny []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return fmt.Errorf("%s:%d: want \"allow|deny <ip or CIDR>\"", filename, line)
+		}
+		switch strings.ToLower(fields[0]) {
+		case "allow":
+			allow = append(allow, fields[1])
+		case "deny":
+			deny = append(deny, fields[1])
+		default:
+			return fmt.Errorf("%s:%d: unknown rule %s", filename, line, fields[0])
+		}
+	}
+	if err := a.Reload(allow, deny); err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	return nil
+}
+
+// WatchFile loads filename and reloads it every interval when its modification time changes, until stop is called.
+// The errors of the reloads go to onError, the first load fails WatchFile. No reload runs after stop returns.
+func (a *IPAccess) WatchFile(filename string, interval time.Duration, onError func(err error)) (stop func(), err error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.LoadFile(filename); err != nil {
+		return nil, err
+	}
+	modTime := info.ModTime()
+	done, exited := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			info, err := os.Stat(filename)
+			if err == nil && info.ModTime().Equal(modTime) {
+				continue
+			}
+			if err == nil {
+				modTime = info.ModTime()
+				err = a.LoadFile(filename)
+			}
+			if err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-exited
+	}, nil
+}
+
+// RegisterIPAccess names an IPAccess, routes select it by RouteOption.IPAccess instead of Config.IPAccess
+func (app *App) RegisterIPAccess(name string, access *IPAccess) {
+	app.middlewareMu.Lock()
+	defer app.middlewareMu.Unlock()
+	if app.ipAccess == nil {
+		app.ipAccess = make(map[string]*IPAccess)
+	}
+	app.ipAccess[name] = access
+}
+
+// routeIPAccess returns the IPAccess of the route, Config.IPAccess for the routes without RouteOption.IPAccess
+func (c *WebContext) routeIPAccess() (*IPAccess, error) {
+	if c.option == nil || c.option.IPAccess == "" {
+		return c.Config().IPAccess, nil
+	}
+	app := c.App()
+	app.middlewareMu.RLock()
+	defer app.middlewareMu.RUnlock()
+	access, has := app.ipAccess[c.option.IPAccess]
+	if !has {
+		return nil, fmt.Errorf("ip access %s is not registered", c.option.IPAccess)
+	}
+	return access, nil
+}
+
+// filterIPList parses Config.FilterIpMap on its first use, the entries which are not ip addresses or CIDR networks
+// are logged and skipped
+func (app *App) filterIPList() *IPList {
+	app.filterIPOnce.Do(func() {
+		list := &IPList{}
+		for entry := range app.Config.FilterIpMap {
+			parsed, err := ParseIPList(entry)
+			if err != nil {
+				app.Logger().Warning("FilterIpMap entry %s is skipped: %s", entry, err)
+				continue
+			}
+			list.nets = append(list.nets, parsed.nets...)
+		}
+		app.filterIPs = list
+	})
+	return app.filterIPs
+}
+
+// IPFilter rejects with 403 the client ips (WebContext.RemoteIP) denied by the IPAccess of the route,
+// Config.IPAccess for the routes without one, and the ips in Config.FilterIpMap. The CIDR networks of
+// FilterIpMap are parsed once, the ips added later are denied by their exact address only.
+func IPFilter() Middleware {
+	return func(next Next) Next {
+		return func(ctx *WebContext) error {
+			access, err := ctx.routeIPAccess()
+			if err != nil {
+				return err
+			}
+			remoteIP := ctx.RemoteIP()
+			ip := net.ParseIP(remoteIP)
+			denied := access != nil && !access.Allowed(ip)
+			if filter := ctx.Config().FilterIpMap; !denied && len(filter) > 0 {
+				_, denied = filter[remoteIP]
+				denied = denied || ctx.App().filterIPList().Contains(ip)
+			}
+			if denied {
+				return &HTTPError{Status: http.StatusForbidden, Code: "ip_denied", Message: "the client ip is not allowed", Cause: fmt.Errorf("filter ip:%s", remoteIP)}
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// clientIP returns the ip of the client of r. The peer of a trusted proxy is the nearest untrusted address
+// of Forwarded, X-Forwarded-For or X-Real-IP, the first one present; the other peers are the client.
+func clientIP(r *http.Request, trusted *IPList) string {
+	peer := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+	if trusted.Len() == 0 || !trusted.Contains(net.ParseIP(peer)) {
+		return peer
+	}
+	var hops []string
+	if forwarded := r.Header["Forwarded"]; len(forwarded) > 0 {
+		hops = forwardedFor(forwarded)
+	} else if xff := r.Header["X-Forwarded-For"]; len(xff) > 0 {
+		for _, value := range xff {
+			for _, hop := range strings.Split(value, ",") {
+				hops = append(hops, strings.TrimSpace(hop))
+			}
+		}
+	} else if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		hops = []string{realIP}
+	}
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(stripPort(hops[i]))
+		if ip == nil {
+			// an obfuscated or broken hop, the address behind it is not known
+			break
+		}
+		client = ip.String()
+		if !trusted.Contains(ip) {
+			break
+		}
+	}
+	return client
+}
+
+// forwardedFor returns the for= addresses of the Forwarded headers (RFC 7239) in order
+func forwardedFor(values []string) []string {
+	var hops []string
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			hop := ""
+			for _, pair := range strings.Split(element, ";") {
+				pair = strings.TrimSpace(pair)
+				if len(pair) > 4 && strings.EqualFold(pair[:4], "for=") {
+					hop = strings.Trim(pair[4:], `"`)
+				}
+			}
+			hops = append(hops, hop)
+		}
+	}
+	return hops
+}
+
+// stripPort removes the port of 192.0.2.60:8080 or [2001:db8::1]:8080, and the brackets of [2001:db8::1]
+func stripPort(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+}
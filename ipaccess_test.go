@@ -0,0 +1,160 @@
+package hiweb
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type ipAccessTestController struct {
+	Controller
+}
+
+func (c *ipAccessTestController) Get() (string, error) {
+	return c.Ctx.RemoteIP(), nil
+}
+
+func TestIPAccess(t *testing.T) {
+	if _, err := ParseIPList("10.0.0.0/33"); err == nil {
+		t.Error("invalid CIDR is parsed")
+	}
+	if _, err := ParseIPList("10.0.0"); err == nil {
+		t.Error("invalid ip is parsed")
+	}
+	access, err := NewIPAccess([]string{"10.0.0.0/8", "2001:db8::/32"}, []string{"10.1.0.0/16", "10.2.3.4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]bool{
+		"10.9.8.7":        true,
+		"10.1.2.3":        false,
+		"10.2.3.4":        false,
+		"10.2.3.5":        true,
+		"192.168.1.1":     false,
+		"2001:db8::1":     true,
+		"::ffff:10.9.8.7": true,
+		"2001:db9::1":     false,
+	}
+	for ip, want := range cases {
+		if got := access.Allowed(net.ParseIP(ip)); got != want {
+			t.Errorf("ip %s allowed %v", ip, got)
+		}
+	}
+	if access.Allowed(nil) {
+		t.Error("unknown ip passes the allow list")
+	}
+	if err := access.Reload([]string{"bad"}, nil); err == nil || !access.Allowed(net.ParseIP("10.9.8.7")) {
+		t.Errorf("invalid reload err:%v", err)
+	}
+	if err := access.Reload(nil, []string{"10.9.8.7"}); err != nil || access.Allowed(net.ParseIP("10.9.8.7")) || !access.Allowed(net.ParseIP("192.168.1.1")) {
+		t.Errorf("reload err:%v", err)
+	}
+}
+
+func TestIPAccessWatchFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hiweb-ip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "ip.conf")
+	if err := ioutil.WriteFile(filename, []byte("# office\nallow 192.168.0.0/16\n\ndeny 192.168.9.9\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	access := &IPAccess{}
+	stop, err := access.WatchFile(filename, 10*time.Millisecond, func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	if !access.Allowed(net.ParseIP("192.168.1.1")) || access.Allowed(net.ParseIP("192.168.9.9")) || access.Allowed(net.ParseIP("10.0.0.1")) {
+		t.Error("loaded lists are not applied")
+	}
+	if err := ioutil.WriteFile(filename, []byte("allow 10.0.0.0/8\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(filename, time.Now(), time.Now().Add(time.Second))
+	for i := 0; i < 100 && !access.Allowed(net.ParseIP("10.0.0.1")); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !access.Allowed(net.ParseIP("10.0.0.1")) || access.Allowed(net.ParseIP("192.168.1.1")) {
+		t.Error("changed file is not reloaded")
+	}
+	if err := (&IPAccess{}).LoadFile(filename + ".missing"); err == nil {
+		t.Error("missing file is loaded")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted := MustParseIPList("10.0.0.0/8", "::1")
+	cases := []struct {
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{"203.0.113.9:5000", map[string]string{"X-Forwarded-For": "1.2.3.4"}, "203.0.113.9"},
+		{"10.0.0.2:5000", nil, "10.0.0.2"},
+		{"10.0.0.2:5000", map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.7, 10.0.0.3"}, "198.51.100.7"},
+		{"10.0.0.2:5000", map[string]string{"X-Forwarded-For": "10.0.0.5, 10.0.0.3"}, "10.0.0.5"},
+		{"10.0.0.2:5000", map[string]string{"X-Forwarded-For": "garbage, 10.0.0.3"}, "10.0.0.3"},
+		{"[::1]:5000", map[string]string{"Forwarded": `for=192.0.2.60;proto=http, for="[2001:db8:cafe::17]:4711"`}, "2001:db8:cafe::17"},
+		{"10.0.0.2:5000", map[string]string{"Forwarded": "for=198.51.100.1:80", "X-Forwarded-For": "1.2.3.4"}, "198.51.100.1"},
+		{"10.0.0.2:5000", map[string]string{"X-Real-IP": "198.51.100.2"}, "198.51.100.2"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = c.remoteAddr
+		for key, value := range c.headers {
+			req.Header.Set(key, value)
+		}
+		if got := clientIP(req, trusted); got != c.want {
+			t.Errorf("client ip of %s %v got %s", c.remoteAddr, c.headers, got)
+		}
+	}
+}
+
+func TestIPFilter(t *testing.T) {
+	app := NewApp(func(c *Config) {
+		c.TrustedProxies = MustParseIPList("10.0.0.1")
+		c.IPAccess, _ = NewIPAccess(nil, []string{"203.0.113.0/24"})
+		c.FilterIpMap["198.51.100.9"] = 1
+		c.FilterIpMap["198.51.100.128/25"] = 1
+		c.FilterIpMap["legacy.example.com"] = 1
+	})
+	internal, _ := NewIPAccess([]string{"192.168.0.0/16"}, nil)
+	app.RegisterIPAccess("internal", internal)
+	app.Route("/public", &ipAccessTestController{}, "", "get:Get", RouteOption{})
+	app.Route("/internal", &ipAccessTestController{}, "", "get:Get", RouteOption{IPAccess: "internal"})
+	app.Route("/missing", &ipAccessTestController{}, "", "get:Get", RouteOption{IPAccess: "missing"})
+	get := func(path, forwarded string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "10.0.0.1:5000"
+		req.Header.Set("X-Forwarded-For", forwarded)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w.Code
+	}
+	cases := []struct {
+		path, ip string
+		status   int
+	}{
+		{"/public", "198.51.100.1", http.StatusOK},
+		{"/public", "203.0.113.5", http.StatusForbidden},
+		{"/public", "198.51.100.9", http.StatusForbidden},
+		{"/public", "198.51.100.200", http.StatusForbidden},
+		{"/internal", "192.168.3.4", http.StatusOK},
+		{"/internal", "203.0.113.5", http.StatusForbidden},
+		{"/internal", "198.51.100.1", http.StatusForbidden},
+		{"/missing", "198.51.100.1", http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		if status := get(c.path, c.ip); status != c.status {
+			t.Errorf("%s from %s got %d", c.path, c.ip, status)
+		}
+	}
+}
@@ -0,0 +1,364 @@
+package hiweb
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultJWKSPath is the well-known path of the JSON Web Key Set, see Config.JWKSPath
+const DefaultJWKSPath = "/.well-known/jwks.json"
+
+// JWK is a public JSON Web Key (RFC 7517) of kty RSA, EC or OKP (Ed25519)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSet is a JSON Web Key Set document
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK returns the public part of the key, false for the HS keys which are never published
+func (k JWTKey) JWK() (JWK, bool) {
+	jwk := JWK{Kid: k.ID, Alg: k.Algorithm, Use: "sig"}
+	switch key := k.verifyKey().(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = key.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(padBytes(key.X.Bytes(), size))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(padBytes(key.Y.Bytes(), size))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(key)
+	default:
+		return JWK{}, false
+	}
+	return jwk, true
+}
+
+func padBytes(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	return append(make([]byte, size-len(b)), b...)
+}
+
+// JWTKey converts the JWK to a verify-only JWTKey, the algorithm is taken from the kty and crv when alg is empty
+func (j JWK) JWTKey() (JWTKey, error) {
+	key := JWTKey{ID: j.Kid, Algorithm: j.Alg}
+	decode := base64.RawURLEncoding.DecodeString
+	switch j.Kty {
+	case "RSA":
+		n, err := decode(j.N)
+		if err != nil {
+			return key, fmt.Errorf("jwk %q: n: %w", j.Kid, err)
+		}
+		e, err := decode(j.E)
+		if err != nil {
+			return key, fmt.Errorf("jwk %q: e: %w", j.Kid, err)
+		}
+		key.PublicKey = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if key.Algorithm == "" {
+			key.Algorithm = "RS256"
+		}
+	case "EC":
+		curves := map[string]struct {
+			curve elliptic.Curve
+			alg   string
+		}{"P-256": {elliptic.P256(), "ES256"}, "P-384": {elliptic.P384(), "ES384"}, "P-521": {elliptic.P521(), "ES512"}}
+		c, ok := curves[j.Crv]
+		if !ok {
+			return key, fmt.Errorf("jwk %q: unsupported curve %q", j.Kid, j.Crv)
+		}
+		x, err := decode(j.X)
+		if err != nil {
+			return key, fmt.Errorf("jwk %q: x: %w", j.Kid, err)
+		}
+		y, err := decode(j.Y)
+		if err != nil {
+			return key, fmt.Errorf("jwk %q: y: %w", j.Kid, err)
+		}
+		publicKey := &ecdsa.PublicKey{Curve: c.curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !c.curve.IsOnCurve(publicKey.X, publicKey.Y) {
+			return key, fmt.Errorf("jwk %q: point is not on the curve", j.Kid)
+		}
+		key.PublicKey = publicKey
+		if key.Algorithm == "" {
+			key.Algorithm = c.alg
+		}
+	case "OKP":
+		x, err := decode(j.X)
+		if err != nil {
+			return key, fmt.Errorf("jwk %q: x: %w", j.Kid, err)
+		}
+		if j.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return key, fmt.Errorf("jwk %q: unsupported curve %q", j.Kid, j.Crv)
+		}
+		key.PublicKey = ed25519.PublicKey(x)
+		if key.Algorithm == "" {
+			key.Algorithm = "EdDSA"
+		}
+	default:
+		return key, fmt.Errorf("jwk %q: unsupported kty %q", j.Kid, j.Kty)
+	}
+	return key, key.validate()
+}
+
+// JWKS returns the public keys of the set, the HS keys are left out
+func (s *JWTKeySet) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range s.Keys() {
+		if jwk, ok := key.JWK(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+// ParseJWKS parses a JSON Web Key Set, the encryption keys are skipped and so are the keys which can not verify
+// tokens, e.g. of an unsupported kty or alg. It fails when no key is left.
+func ParseJWKS(data []byte) ([]JWTKey, error) {
+	var set JWKSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	keys := make([]JWTKey, 0, len(set.Keys))
+	var skipped error
+	for _, jwk := range set.Keys {
+		if jwk.Use == "enc" {
+			continue
+		}
+		key, err := jwk.JWTKey()
+		if err != nil {
+			if skipped == nil {
+				skipped = err
+			}
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		if skipped != nil {
+			return nil, fmt.Errorf("jwks: no usable key: %w", skipped)
+		}
+		return nil, errors.New("jwks: no signing key")
+	}
+	return keys, nil
+}
+
+// JWKS verifies the tokens by the JSON Web Key Set of an identity provider, see JWTConfig.JWKS.
+// The keys are loaded on the first use and reloaded every RefreshInterval in the background,
+// a token of an unknown kid reloads them at most every MinRefreshInterval.
+type JWKS struct {
+	// URL is where the key set is fetched from, with If-None-Match after the first fetch
+	URL string
+	// File is read when URL is empty
+	File string
+	// Client fetches URL, a client with a 10s timeout when nil
+	Client *http.Client
+	// RefreshInterval is how often the keys are reloaded, default 1h
+	RefreshInterval time.Duration
+	// MinRefreshInterval is the least time between two reloads for unknown kids, default 1 minute
+	MinRefreshInterval time.Duration
+
+	mu        sync.RWMutex
+	keys      []JWTKey
+	etag      string
+	loaded    time.Time
+	err       error
+	refreshMu sync.Mutex
+	startOnce sync.Once
+	closeOnce sync.Once
+	stop      chan struct{}
+}
+
+// NewJWKS creates a JWKS fetched from url
+func NewJWKS(url string) *JWKS {
+	return &JWKS{URL: url, RefreshInterval: time.Hour, MinRefreshInterval: time.Minute}
+}
+
+// NewJWKSFile creates a JWKS read from the file path
+func NewJWKSFile(path string) *JWKS {
+	return &JWKS{File: path, RefreshInterval: time.Hour, MinRefreshInterval: time.Minute}
+}
+
+// Refresh reloads the keys, the current keys are kept when it fails
+func (j *JWKS) Refresh(ctx context.Context) error {
+	j.refreshMu.Lock()
+	defer j.refreshMu.Unlock()
+	return j.refresh(ctx)
+}
+
+func (j *JWKS) refresh(ctx context.Context) error {
+	keys, etag, err := j.load(ctx)
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.loaded, j.err = time.Now(), err
+	if err != nil {
+		return err
+	}
+	if keys != nil {
+		j.keys, j.etag = keys, etag
+	}
+	return nil
+}
+
+// load reads the key set, nil keys when the server answers 304 Not Modified
+func (j *JWKS) load(ctx context.Context) ([]JWTKey, string, error) {
+	if j.URL == "" {
+		data, err := ioutil.ReadFile(j.File)
+		if err != nil {
+			return nil, "", err
+		}
+		keys, err := ParseJWKS(data)
+		return keys, "", err
+	}
+	req, err := http.NewRequest(http.MethodGet, j.URL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	j.mu.RLock()
+	if j.etag != "" {
+		req.Header.Set("If-None-Match", j.etag)
+	}
+	j.mu.RUnlock()
+	client := j.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("jwks: %s returns %s", j.URL, resp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, "", err
+	}
+	keys, err := ParseJWKS(data)
+	return keys, resp.Header.Get("ETag"), err
+}
+
+// Keys returns the loaded keys, the first call loads them
+func (j *JWKS) Keys() []JWTKey {
+	j.start()
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return append([]JWTKey(nil), j.keys...)
+}
+
+// Err returns the error of the last reload
+func (j *JWKS) Err() error {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.err
+}
+
+// Close stops the background reloads
+func (j *JWKS) Close() error {
+	j.closeOnce.Do(func() {
+		close(j.stopChan())
+	})
+	return nil
+}
+
+func (j *JWKS) stopChan() chan struct{} {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.stop == nil {
+		j.stop = make(chan struct{})
+	}
+	return j.stop
+}
+
+// start loads the keys and starts the background reloads
+func (j *JWKS) start() {
+	j.startOnce.Do(func() {
+		j.Refresh(context.Background())
+		interval := j.RefreshInterval
+		if interval <= 0 {
+			interval = time.Hour
+		}
+		stop := j.stopChan()
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					j.Refresh(context.Background())
+				case <-stop:
+					return
+				}
+			}
+		}()
+	})
+}
+
+// candidates returns the keys of kid and alg, reloading the keys once for an unknown kid
+func (j *JWKS) candidates(kid, alg string) []JWTKey {
+	keys := matchKeys(j.Keys(), kid, alg)
+	if len(keys) > 0 {
+		return keys
+	}
+	min := j.MinRefreshInterval
+	if min <= 0 {
+		min = time.Minute
+	}
+	j.refreshMu.Lock()
+	j.mu.RLock()
+	stale := time.Since(j.loaded) >= min
+	j.mu.RUnlock()
+	if stale {
+		j.refresh(context.Background())
+	}
+	j.refreshMu.Unlock()
+	return matchKeys(j.Keys(), kid, alg)
+}
+
+// registerJWKS publishes the public keys of JWTConfig.Keys at Config.JWKSPath
+func (app *App) registerJWKS() {
+	path := app.Config.JWKSPath
+	if path == "" || app.hasRoute(http.MethodGet, path) {
+		return
+	}
+	app.handle(http.MethodGet, path, func(ctx *WebContext) error {
+		ctx.ResponseWriter.Header().Set("Cache-Control", "public, max-age=300")
+		return ctx.Render(http.StatusOK, app.jwtConfig().Keys.JWKS())
+	})
+}
@@ -0,0 +1,175 @@
+package hiweb
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestJWKSPublish(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	keys, err := NewJWTKeySet(
+		JWTKey{ID: "rs", Algorithm: "RS256", PrivateKey: rsaKey},
+		JWTKey{ID: "es", Algorithm: "ES384", PrivateKey: ecKey},
+		JWTKey{ID: "ed", Algorithm: "EdDSA", PrivateKey: edKey},
+		JWTKey{ID: "hs", Algorithm: "HS256", Secret: []byte("jwks test secret")},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := NewApp(func(c *Config) {
+		c.JWT = NewJWTConfig(keys)
+		c.JWKSPath = DefaultJWKSPath
+	})
+	issuer.registerHealth()
+	w := httptest.NewRecorder()
+	issuer.ServeHTTP(w, httptest.NewRequest(http.MethodGet, DefaultJWKSPath, nil))
+	body := w.Body.String()
+	if w.Code != http.StatusOK || strings.Contains(body, `"hs"`) || strings.Contains(body, `"d"`) {
+		t.Fatalf("jwks got %d %s", w.Code, body)
+	}
+
+	// the published keys verify the tokens of every key in a file
+	dir, err := ioutil.TempDir("", "hiweb-jwks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "jwks.json")
+	ioutil.WriteFile(file, w.Body.Bytes(), 0600)
+	jwks := NewJWKSFile(file)
+	defer jwks.Close()
+	verifier := NewApp(func(c *Config) { c.JWT = &JWTConfig{JWKS: jwks, ClockSkew: time.Minute} })
+	for _, key := range keys.Keys() {
+		signer, _ := NewJWTKeySet(key)
+		tokenString, err := NewJWTConfig(signer).issue(map[string]interface{}{"sub": key.ID}, time.Hour, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims, err := verifier.JwtClaims(tokenString)
+		if key.Algorithm == "HS256" {
+			if !errors.Is(err, ErrTokenInvalid) {
+				t.Errorf("hs token verified by jwks got %v", err)
+			}
+		} else if err != nil || claims["sub"] != key.ID {
+			t.Errorf("%s verified by jwks got %v %v", key.ID, claims, err)
+		}
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	keys, _ := NewJWTKeySet(JWTKey{ID: "ed", Algorithm: "EdDSA", PrivateKey: edKey}, JWTKey{ID: "rsa", Algorithm: "RS256", PrivateKey: rsaKey})
+	set := keys.JWKS()
+	// an encryption key without use, a key of an unknown kty and a broken key are skipped
+	oaep := set.Keys[1]
+	oaep.Kid, oaep.Alg, oaep.Use = "oaep", "RSA-OAEP", ""
+	broken := set.Keys[0]
+	broken.Kid, broken.X = "broken", "!"
+	set.Keys = append(set.Keys, oaep, JWK{Kid: "oct", Kty: "oct"}, broken)
+	data, _ := json.Marshal(set)
+	parsed, err := ParseJWKS(data)
+	if err != nil || len(parsed) != 2 || parsed[0].ID != "ed" || parsed[1].ID != "rsa" {
+		t.Errorf("jwks with unusable keys got %v %v", parsed, err)
+	}
+	data, _ = json.Marshal(JWKSet{Keys: []JWK{oaep}})
+	if _, err := ParseJWKS(data); err == nil {
+		t.Error("jwks without usable keys is parsed")
+	}
+}
+
+func TestJWKSRemote(t *testing.T) {
+	_, key1, _ := ed25519.GenerateKey(rand.Reader)
+	_, key2, _ := ed25519.GenerateKey(rand.Reader)
+	keys, _ := NewJWTKeySet(JWTKey{ID: "k1", Algorithm: "EdDSA", PrivateKey: key1})
+	var mu sync.Mutex
+	fetches, notModified := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, _ := json.Marshal(keys.JWKS())
+		sum := sha256.Sum256(content)
+		etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+		mu.Lock()
+		fetches++
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			mu.Unlock()
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		mu.Unlock()
+		w.Header().Set("ETag", etag)
+		w.Write(content)
+	}))
+	defer server.Close()
+
+	jwks := NewJWKS(server.URL)
+	jwks.MinRefreshInterval = 20 * time.Millisecond
+	defer jwks.Close()
+	issuer := NewApp(func(c *Config) { c.JWT = NewJWTConfig(keys) })
+	verifier := NewApp(func(c *Config) {
+		c.JWT = NewJWTConfig(nil)
+		c.JWT.JWKS = jwks
+	})
+
+	token1, _ := issuer.JwtToken(map[string]interface{}{"sub": "u1"})
+	for i := 0; i < 3; i++ {
+		if _, err := verifier.JwtClaims(token1); err != nil {
+			t.Fatalf("k1 token err:%s", err)
+		}
+	}
+	mu.Lock()
+	if fetches != 1 {
+		t.Errorf("fetches of cached keys got %d", fetches)
+	}
+	mu.Unlock()
+
+	// the access tokens of an IdP carry their own typ, the refresh tokens are still rejected
+	bearer, _ := issuer.JwtToken(map[string]interface{}{"sub": "u1", "typ": "Bearer"})
+	if claims, err := verifier.JwtClaims(bearer); err != nil || claims["sub"] != "u1" {
+		t.Errorf("token with typ Bearer got %v %v", claims, err)
+	}
+	refresh, _ := issuer.Config.JWT.issue(map[string]interface{}{"sub": "u1"}, time.Hour, refreshTokenType)
+	if _, err := verifier.JwtClaims(refresh); !errors.Is(err, ErrTokenType) {
+		t.Errorf("refresh token as access token err:%v", err)
+	}
+
+	// an unknown kid reloads the keys after MinRefreshInterval
+	keys.Rotate(JWTKey{ID: "k2", Algorithm: "EdDSA", PrivateKey: key2})
+	token2, _ := issuer.JwtToken(map[string]interface{}{"sub": "u2"})
+	time.Sleep(30 * time.Millisecond)
+	if claims, err := verifier.JwtClaims(token2); err != nil || claims["sub"] != "u2" {
+		t.Errorf("k2 token got %v %v", claims, err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if err := jwks.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	if fetches != 3 || notModified != 1 {
+		t.Errorf("fetches got %d, not modified %d", fetches, notModified)
+	}
+	mu.Unlock()
+	if _, err := verifier.JwtClaims(token1); err != nil {
+		t.Errorf("k1 token after refresh err:%s", err)
+	}
+}
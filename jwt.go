@@ -0,0 +1,589 @@
+package hiweb
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// defaultSecretKey is the SecretKey of NewConfig, App.Run fails while it is in use
+const defaultSecretKey = "asdfsadfwexczv asfwe"
+
+// refreshTokenType is the typ claim of the refresh tokens, the access tokens issued here have no typ
+const refreshTokenType = "refresh"
+
+var (
+	ErrTokenInvalid     = errors.New("token is not valid")
+	ErrTokenExpired     = errors.New("token is expired")
+	ErrTokenNotValidYet = errors.New("token is not valid yet")
+	ErrTokenIssuer      = errors.New("token issuer is not accepted")
+	ErrTokenAudience    = errors.New("token audience is not accepted")
+	ErrTokenType        = errors.New("token type is not accepted")
+	ErrTokenRevoked     = errors.New("token is revoked")
+)
+
+// SigningMethodEdDSA signs the tokens with ed25519 keys, jwt-go v3 has no EdDSA
+var SigningMethodEdDSA jwt.SigningMethod = signingMethodEdDSA{}
+
+func init() {
+	jwt.RegisterSigningMethod("EdDSA", func() jwt.SigningMethod {
+		return SigningMethodEdDSA
+	})
+}
+
+type signingMethodEdDSA struct{}
+
+func (signingMethodEdDSA) Alg() string {
+	return "EdDSA"
+}
+
+func (signingMethodEdDSA) Verify(signingString, signature string, key interface{}) error {
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok || len(publicKey) != ed25519.PublicKeySize {
+		return jwt.ErrInvalidKeyType
+	}
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(publicKey, []byte(signingString), sig) {
+		return jwt.ErrSignatureInvalid
+	}
+	return nil
+}
+
+func (signingMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok || len(privateKey) != ed25519.PrivateKeySize {
+		return "", jwt.ErrInvalidKeyType
+	}
+	return jwt.EncodeSegment(ed25519.Sign(privateKey, []byte(signingString))), nil
+}
+
+// JWTKey is a key of a JWTKeySet, the tokens it signs carry its ID as the kid header
+type JWTKey struct {
+	// ID is the kid of the key
+	ID string
+	// Algorithm is HS256/384/512, RS256/384/512, PS256/384/512, ES256/384/512 or EdDSA
+	Algorithm string
+	// Secret is the shared secret of the HS algorithms
+	Secret []byte
+	// PrivateKey signs the tokens: *rsa.PrivateKey, *ecdsa.PrivateKey or ed25519.PrivateKey, nil for a key only verifying
+	PrivateKey crypto.PrivateKey
+	// PublicKey verifies the tokens, it is taken from PrivateKey when nil
+	PublicKey crypto.PublicKey
+}
+
+// ParseJWTKeyPEM creates a JWTKey from a PEM private key (PKCS #1, PKCS #8 or SEC 1) or public key (PKIX or PKCS #1)
+func ParseJWTKeyPEM(kid, alg string, data []byte) (JWTKey, error) {
+	key := JWTKey{ID: kid, Algorithm: alg}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return key, errors.New("jwt: no PEM data")
+	}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key.PrivateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key.PrivateKey, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key.PrivateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		key.PublicKey, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key.PublicKey, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		err = fmt.Errorf("jwt: unsupported PEM type %q", block.Type)
+	}
+	if err != nil {
+		return JWTKey{ID: kid, Algorithm: alg}, err
+	}
+	return key, key.validate()
+}
+
+func (k JWTKey) method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+func (k JWTKey) isHMAC() bool {
+	return strings.HasPrefix(k.Algorithm, "HS")
+}
+
+// signKey returns the key passed to the signing method, nil when the key cannot sign
+func (k JWTKey) signKey() interface{} {
+	if k.isHMAC() {
+		if len(k.Secret) == 0 {
+			return nil
+		}
+		return k.Secret
+	}
+	return k.PrivateKey
+}
+
+func (k JWTKey) verifyKey() interface{} {
+	if k.isHMAC() {
+		if len(k.Secret) == 0 {
+			return nil
+		}
+		return k.Secret
+	}
+	if k.PublicKey != nil {
+		return k.PublicKey
+	}
+	if signer, ok := k.PrivateKey.(interface{ Public() crypto.PublicKey }); ok {
+		return signer.Public()
+	}
+	return nil
+}
+
+// validate checks the keys are of the algorithm and the private key matches the public key
+func (k JWTKey) validate() error {
+	method := k.method()
+	if method == nil || method.Alg() == "none" {
+		return fmt.Errorf("jwt: unknown algorithm %q of key %q", k.Algorithm, k.ID)
+	}
+	if key := k.signKey(); key != nil {
+		sig, err := method.Sign("hiweb", key)
+		if err == nil {
+			err = method.Verify("hiweb", sig, k.verifyKey())
+		}
+		if err != nil {
+			return fmt.Errorf("jwt: key %q does not match %s: %w", k.ID, k.Algorithm, err)
+		}
+		return nil
+	}
+	if k.verifyKey() == nil || method.Verify("hiweb", "AA", k.verifyKey()) == jwt.ErrInvalidKeyType {
+		return fmt.Errorf("jwt: key %q does not match %s", k.ID, k.Algorithm)
+	}
+	return nil
+}
+
+// JWTKeySet is the keys of a JWTConfig by kid, it is safe for concurrent use.
+// A key is rotated by Rotate with the new key, the old key keeps verifying the tokens it signed
+// until it is removed after they are expired.
+type JWTKeySet struct {
+	mu         sync.RWMutex
+	keys       []JWTKey
+	signing    string
+	hasSigning bool
+}
+
+// NewJWTKeySet creates a JWTKeySet, the first key with a secret or private key signs the tokens
+func NewJWTKeySet(keys ...JWTKey) (*JWTKeySet, error) {
+	s := &JWTKeySet{}
+	for _, key := range keys {
+		if err := s.Add(key); err != nil {
+			return nil, err
+		}
+		if !s.hasSigning && key.signKey() != nil {
+			s.signing, s.hasSigning = key.ID, true
+		}
+	}
+	return s, nil
+}
+
+// Add adds a key verifying the tokens, it replaces the key of the same ID
+func (s *JWTKeySet) Add(key JWTKey) error {
+	if err := key.validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.keys {
+		if s.keys[i].ID == key.ID {
+			s.keys[i] = key
+			return nil
+		}
+	}
+	s.keys = append(s.keys, key)
+	return nil
+}
+
+// Rotate adds key and signs the new tokens with it
+func (s *JWTKeySet) Rotate(key JWTKey) error {
+	if key.signKey() == nil {
+		return fmt.Errorf("jwt: key %q cannot sign", key.ID)
+	}
+	if err := s.Add(key); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.signing, s.hasSigning = key.ID, true
+	s.mu.Unlock()
+	return nil
+}
+
+// Remove removes the key of kid, the tokens it signed are not valid any more
+func (s *JWTKeySet) Remove(kid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.keys {
+		if s.keys[i].ID == kid {
+			s.keys = append(s.keys[:i:i], s.keys[i+1:]...)
+			break
+		}
+	}
+	if s.hasSigning && s.signing == kid {
+		s.signing, s.hasSigning = "", false
+	}
+}
+
+// Keys returns a copy of the keys
+func (s *JWTKeySet) Keys() []JWTKey {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]JWTKey(nil), s.keys...)
+}
+
+// SigningKey returns the key signing the new tokens
+func (s *JWTKeySet) SigningKey() (JWTKey, bool) {
+	if s == nil {
+		return JWTKey{}, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.hasSigning {
+		for _, key := range s.keys {
+			if key.ID == s.signing {
+				return key, true
+			}
+		}
+	}
+	return JWTKey{}, false
+}
+
+// candidates returns the keys verifying a token of kid and alg, a token without kid is tried with every key of alg
+func (s *JWTKeySet) candidates(kid, alg string) []JWTKey {
+	return matchKeys(s.Keys(), kid, alg)
+}
+
+func matchKeys(keys []JWTKey, kid, alg string) []JWTKey {
+	var matched []JWTKey
+	for _, key := range keys {
+		if key.Algorithm == alg && (kid == "" || key.ID == kid) {
+			matched = append(matched, key)
+		}
+	}
+	return matched
+}
+
+// JWTConfig is how the tokens of JwtToken and CheckAuth are signed and validated
+type JWTConfig struct {
+	// Keys sign and verify the tokens, a token is only verified by a key of its kid and alg
+	Keys *JWTKeySet
+	// JWKS verifies the tokens of an identity provider besides Keys
+	JWKS *JWKS
+	// Issuer is set as iss in the tokens and required when it is not empty
+	Issuer string
+	// Audience is the accepted aud values, the first one is set in the tokens; empty accepts any aud
+	Audience []string
+	// TTL is the lifetime of the access tokens, default 1h
+	TTL time.Duration
+	// RefreshTTL is the lifetime of the refresh tokens, default 7 days
+	RefreshTTL time.Duration
+	// ClockSkew is the leeway of the exp, nbf and iat checks
+	ClockSkew time.Duration
+	// IsRevoked is called with the claims of every valid token, true rejects the token
+	IsRevoked func(claims jwt.MapClaims) (bool, error)
+	// Revoke is called by JwtRevoke and with the refresh tokens used by JwtRefresh,
+	// e.g. to keep the jti until the exp for IsRevoked. Without it a refresh token can be used until it expires.
+	Revoke func(claims jwt.MapClaims) error
+}
+
+// NewJWTConfig creates a JWTConfig of keys with the default TTL, RefreshTTL and a clock skew of 1 minute
+func NewJWTConfig(keys *JWTKeySet) *JWTConfig {
+	return &JWTConfig{
+		Keys:       keys,
+		TTL:        time.Hour,
+		RefreshTTL: 7 * 24 * time.Hour,
+		ClockSkew:  time.Minute,
+	}
+}
+
+// jwtConfig returns Config.JWT, or HS256 with Config.SecretKey when it is nil
+func (app *App) jwtConfig() *JWTConfig {
+	if app.Config.JWT != nil {
+		return app.Config.JWT
+	}
+	keys := &JWTKeySet{}
+	keys.Rotate(JWTKey{Algorithm: "HS256", Secret: []byte(app.Config.SecretKey)})
+	return NewJWTConfig(keys)
+}
+
+// checkSecret fails when the secrets are still the default one
+func (app *App) checkSecret() error {
+	if app.Config.SecretKey == defaultSecretKey {
+		return errors.New("hiweb: Config.SecretKey is the default secret, set a secret of your own")
+	}
+	if app.Config.JWT != nil {
+		for _, key := range app.Config.JWT.Keys.Keys() {
+			if key.isHMAC() && string(key.Secret) == defaultSecretKey {
+				return fmt.Errorf("hiweb: jwt key %q is the default secret, set a secret of your own", key.ID)
+			}
+		}
+	}
+	return nil
+}
+
+// issue signs infos with the registered claims iat, exp, jti, iss and aud, the claims in infos are kept
+func (c *JWTConfig) issue(infos map[string]interface{}, ttl time.Duration, typ string) (string, error) {
+	key, ok := c.Keys.SigningKey()
+	if !ok {
+		return "", errors.New("jwt: no signing key")
+	}
+	now := time.Now()
+	claims := make(jwt.MapClaims, len(infos)+6)
+	for k, v := range infos {
+		claims[k] = v
+	}
+	setClaim := func(name string, value interface{}) {
+		if _, has := claims[name]; !has {
+			claims[name] = value
+		}
+	}
+	jti, err := newSessionID()
+	if err != nil {
+		return "", err
+	}
+	setClaim("iat", now.Unix())
+	setClaim("exp", now.Add(ttl).Unix())
+	setClaim("jti", jti)
+	if c.Issuer != "" {
+		setClaim("iss", c.Issuer)
+	}
+	if len(c.Audience) > 0 {
+		setClaim("aud", c.Audience[0])
+	}
+	if typ != "" {
+		claims["typ"] = typ
+	}
+	token := jwt.NewWithClaims(key.method(), claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	return token.SignedString(key.signKey())
+}
+
+func (c *JWTConfig) ttl() time.Duration {
+	if c.TTL <= 0 {
+		return time.Hour
+	}
+	return c.TTL
+}
+
+func (c *JWTConfig) refreshTTL() time.Duration {
+	if c.RefreshTTL <= 0 {
+		return 7 * 24 * time.Hour
+	}
+	return c.RefreshTTL
+}
+
+// anyTokenType accepts the access and refresh tokens in parse
+const anyTokenType = "*"
+
+// parse verifies the signature and validates the claims of a token of typ
+func (c *JWTConfig) parse(tokenString string, typ string) (jwt.MapClaims, error) {
+	unverified, _, err := new(jwt.Parser).ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrTokenInvalid, err)
+	}
+	kid, _ := unverified.Header["kid"].(string)
+	keys := c.Keys.candidates(kid, unverified.Method.Alg())
+	if c.JWKS != nil {
+		keys = append(keys, c.JWKS.candidates(kid, unverified.Method.Alg())...)
+	}
+	if len(keys) == 0 {
+		if c.JWKS != nil && c.JWKS.Err() != nil {
+			return nil, fmt.Errorf("%w: no key of kid %q and alg %s: %s", ErrTokenInvalid, kid, unverified.Method.Alg(), c.JWKS.Err())
+		}
+		return nil, fmt.Errorf("%w: no key of kid %q and alg %s", ErrTokenInvalid, kid, unverified.Method.Alg())
+	}
+	var claims jwt.MapClaims
+	for _, key := range keys {
+		key := key
+		claims = jwt.MapClaims{}
+		parser := &jwt.Parser{ValidMethods: []string{key.Algorithm}, SkipClaimsValidation: true}
+		_, err = parser.ParseWithClaims(tokenString, claims, func(*jwt.Token) (interface{}, error) {
+			return key.verifyKey(), nil
+		})
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrTokenInvalid, err)
+	}
+	if err := c.validate(claims, typ, time.Now()); err != nil {
+		return nil, err
+	}
+	if c.IsRevoked != nil {
+		revoked, err := c.IsRevoked(claims)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
+	}
+	return claims, nil
+}
+
+// validate checks exp, nbf and iat with the clock skew, iss, aud and typ, typ "" accepts any typ but refresh, e.g. "Bearer" of an IdP
+func (c *JWTConfig) validate(claims jwt.MapClaims, typ string, now time.Time) error {
+	exp, ok := timeClaim(claims, "exp")
+	if !ok {
+		return fmt.Errorf("%w: exp is missing", ErrTokenInvalid)
+	}
+	if now.After(exp.Add(c.ClockSkew)) {
+		return ErrTokenExpired
+	}
+	if nbf, ok := timeClaim(claims, "nbf"); ok && now.Add(c.ClockSkew).Before(nbf) {
+		return ErrTokenNotValidYet
+	}
+	if iat, ok := timeClaim(claims, "iat"); ok && now.Add(c.ClockSkew).Before(iat) {
+		return ErrTokenNotValidYet
+	}
+	if c.Issuer != "" && claims["iss"] != c.Issuer {
+		return ErrTokenIssuer
+	}
+	if len(c.Audience) > 0 && !audienceAccepted(claims["aud"], c.Audience) {
+		return ErrTokenAudience
+	}
+	t, _ := claims["typ"].(string)
+	switch typ {
+	case anyTokenType:
+	case "":
+		if t == refreshTokenType {
+			return ErrTokenType
+		}
+	default:
+		if t != typ {
+			return ErrTokenType
+		}
+	}
+	return nil
+}
+
+// timeClaim reads a NumericDate claim
+func timeClaim(claims jwt.MapClaims, name string) (time.Time, bool) {
+	var seconds float64
+	switch v := claims[name].(type) {
+	case float64:
+		seconds = v
+	case int64:
+		seconds = float64(v)
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return time.Time{}, false
+		}
+		seconds = f
+	default:
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))), true
+}
+
+// audienceAccepted reports whether aud, a string or an array of strings, contains one of accepted
+func audienceAccepted(aud interface{}, accepted []string) bool {
+	var values []string
+	switch v := aud.(type) {
+	case string:
+		values = []string{v}
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	for _, value := range values {
+		for _, a := range accepted {
+			if value == a {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// registeredClaims are not copied from a refresh token to the new tokens
+var registeredClaims = map[string]bool{"iat": true, "exp": true, "nbf": true, "jti": true, "typ": true, "iss": true, "aud": true}
+
+// JwtRefreshToken issues a refresh token of infos with the default app
+func JwtRefreshToken(infos map[string]interface{}) (string, error) {
+	return defaultApp.JwtRefreshToken(infos)
+}
+
+// JwtRefresh exchanges a refresh token for new access and refresh tokens with the default app
+func JwtRefresh(refreshToken string) (string, string, error) {
+	return defaultApp.JwtRefresh(refreshToken)
+}
+
+// JwtRevoke revokes an access or refresh token with the default app
+func JwtRevoke(tokenString string) error {
+	return defaultApp.JwtRevoke(tokenString)
+}
+
+// JwtRefreshToken issues a refresh token of infos which expires after JWTConfig.RefreshTTL.
+// It is only accepted by JwtRefresh, not by JwtClaims or CheckAuth.
+func (app *App) JwtRefreshToken(infos map[string]interface{}) (string, error) {
+	config := app.jwtConfig()
+	return config.issue(infos, config.refreshTTL(), refreshTokenType)
+}
+
+// JwtRefresh validates refreshToken, revokes it by JWTConfig.Revoke and returns new access and refresh tokens
+// with the claims of refreshToken
+func (app *App) JwtRefresh(refreshToken string) (string, string, error) {
+	config := app.jwtConfig()
+	claims, err := config.parse(refreshToken, refreshTokenType)
+	if err != nil {
+		return "", "", err
+	}
+	if config.Revoke != nil {
+		if err := config.Revoke(claims); err != nil {
+			return "", "", err
+		}
+	}
+	infos := make(map[string]interface{}, len(claims))
+	for k, v := range claims {
+		if !registeredClaims[k] {
+			infos[k] = v
+		}
+	}
+	access, err := config.issue(infos, config.ttl(), "")
+	if err != nil {
+		return "", "", err
+	}
+	refresh, err := config.issue(infos, config.refreshTTL(), refreshTokenType)
+	if err != nil {
+		return "", "", err
+	}
+	return access, refresh, nil
+}
+
+// JwtRevoke validates tokenString and passes its claims to JWTConfig.Revoke
+func (app *App) JwtRevoke(tokenString string) error {
+	config := app.jwtConfig()
+	if config.Revoke == nil {
+		return errors.New("jwt: JWTConfig.Revoke is not set")
+	}
+	claims, err := config.parse(tokenString, anyTokenType)
+	if err != nil {
+		return err
+	}
+	return config.Revoke(claims)
+}
@@ -0,0 +1,117 @@
+package hiweb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// bodyTooLargeError is the 413 error of a body larger than limit
+func bodyTooLargeError(limit int64, cause error) *HTTPError {
+	return &HTTPError{
+		Status:  http.StatusRequestEntityTooLarge,
+		Code:    "body_too_large",
+		Message: fmt.Sprintf("request body is larger than %d bytes", limit),
+		Cause:   cause,
+	}
+}
+
+// timeoutError is the 503 error of a request which is not done before the route timeout
+func timeoutError(timeout time.Duration, cause error) *HTTPError {
+	return &HTTPError{
+		Status:  http.StatusServiceUnavailable,
+		Code:    "timeout",
+		Message: fmt.Sprintf("request is not done in %s", timeout),
+		Cause:   cause,
+	}
+}
+
+// maxBodyReader is the http.MaxBytesReader of a request, it marks the context when the limit is exceeded
+type maxBodyReader struct {
+	io.ReadCloser
+	ctx   *WebContext
+	limit int64
+	read  int64
+}
+
+func (r *maxBodyReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.read += int64(n)
+	if err != nil && err != io.EOF && r.read >= r.limit {
+		r.ctx.bodyTooLarge = true
+		return n, bodyTooLargeError(r.limit, err)
+	}
+	return n, err
+}
+
+// routeMaxBody returns the body limit of the route, RouteOption.MaxBody or Config.MaxBodySize, 0 is unlimited
+func routeMaxBody(config *Config, option RouteOption) int64 {
+	limit := option.MaxBody
+	if limit == 0 {
+		limit = config.MaxBodySize
+	}
+	if limit < 0 {
+		return 0
+	}
+	return limit
+}
+
+// routeTimeout returns the timeout of the route, RouteOption.Timeout or Config.RequestTimeout, 0 is none
+func routeTimeout(config *Config, option RouteOption) time.Duration {
+	timeout := option.Timeout
+	if timeout == 0 {
+		timeout = config.RequestTimeout
+	}
+	if timeout < 0 {
+		return 0
+	}
+	return timeout
+}
+
+// routeLimits limits the body size of the route by http.MaxBytesReader and its time by the deadline of the
+// request context, the errors of the exceeded limits become 413 and 503, and so do the handlers done after
+// the deadline without writing the response
+func routeLimits(option RouteOption) Middleware {
+	return func(next Next) Next {
+		return func(ctx *WebContext) error {
+			config := ctx.Config()
+			limit := routeMaxBody(config, option)
+			if limit > 0 && ctx.Request.Body != nil && ctx.Request.Body != http.NoBody {
+				if ctx.Request.ContentLength > limit {
+					return bodyTooLargeError(limit, nil)
+				}
+				ctx.Request.Body = &maxBodyReader{
+					ReadCloser: http.MaxBytesReader(ctx.ResponseWriter, ctx.Request.Body, limit),
+					ctx:        ctx,
+					limit:      limit,
+				}
+			}
+			timeout := routeTimeout(config, option)
+			if timeout > 0 {
+				defer ctx.withTimeout(timeout)()
+			}
+			err := next(ctx)
+			if err == nil {
+				// a handler which ignores the deadline and writes nothing is late too
+				if timeout > 0 && ctx.Context().Err() == context.DeadlineExceeded && !ctx.responseWritten() {
+					return timeoutError(timeout, ctx.Context().Err())
+				}
+				return nil
+			}
+			if ctx.bodyTooLarge {
+				return bodyTooLargeError(limit, err)
+			}
+			if timeout > 0 && ctx.Context().Err() == context.DeadlineExceeded {
+				return timeoutError(timeout, err)
+			}
+			return err
+		}
+	}
+}
+
+// parseMultipartForm parses the multipart body keeping Config.MaxMultipartMemory bytes in memory
+func (c *WebContext) parseMultipartForm() error {
+	return c.Request.ParseMultipartForm(c.Config().MaxMultipartMemory)
+}
@@ -0,0 +1,119 @@
+package hiweb
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type limitsTestController struct {
+	Controller
+}
+
+func (c *limitsTestController) Echo() (int, error) {
+	body, err := c.GetBody()
+	if err != nil {
+		return 0, err
+	}
+	return len(body), nil
+}
+
+func (c *limitsTestController) Slow() error {
+	select {
+	case <-c.Ctx.Context().Done():
+		return c.Ctx.Context().Err()
+	case <-time.After(200 * time.Millisecond):
+		return nil
+	}
+}
+
+func (c *limitsTestController) Late() (string, error) {
+	time.Sleep(60 * time.Millisecond)
+	return "late", nil
+}
+
+func (c *limitsTestController) LateEmpty() error {
+	time.Sleep(60 * time.Millisecond)
+	return nil
+}
+
+// limitsTestLate is written as webcmd generates the invokers
+func limitsTestLate(ctx *WebContext) error {
+	c := ctx.Controller().(*limitsTestController)
+	v, err := c.Late()
+	return RenderResult(ctx, v, err)
+}
+
+func TestRouteLimits(t *testing.T) {
+	app := NewApp(func(c *Config) {
+		c.MaxBodySize = 16
+		c.RequestTimeout = 30 * time.Millisecond
+	})
+	app.Route("/echo", &limitsTestController{}, "", "post:Echo", RouteOption{})
+	app.Route("/big", &limitsTestController{}, "", "post:Echo", RouteOption{MaxBody: 64})
+	app.Route("/any", &limitsTestController{}, "", "post:Echo", RouteOption{MaxBody: -1})
+	app.Route("/slow", &limitsTestController{}, "", "get:Slow", RouteOption{})
+	app.Route("/patient", &limitsTestController{}, "", "get:Slow", RouteOption{Timeout: -1})
+	app.Route("/late", &limitsTestController{}, "", "get:Late", RouteOption{})
+	app.Route("/late-empty", &limitsTestController{}, "", "get:LateEmpty", RouteOption{})
+	app.Route("/late-invoker", &limitsTestController{}, "", "get:Late", RouteOption{Invoke: limitsTestLate})
+
+	send := func(path, body string, chunked bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if chunked {
+			req.Body = ioutil.NopCloser(strings.NewReader(body))
+			req.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w
+	}
+	large := strings.Repeat("a", 32)
+	cases := []struct {
+		path    string
+		body    string
+		chunked bool
+		status  int
+	}{
+		{"/echo", "small", false, http.StatusOK},
+		{"/echo", large, false, http.StatusRequestEntityTooLarge},
+		{"/echo", large, true, http.StatusRequestEntityTooLarge},
+		{"/big", large, true, http.StatusOK},
+		{"/big", large + large + large, true, http.StatusRequestEntityTooLarge},
+		{"/any", large + large + large, false, http.StatusOK},
+	}
+	for _, c := range cases {
+		w := send(c.path, c.body, c.chunked)
+		if w.Code != c.status {
+			t.Errorf("%s %d bytes chunked %v got %d %s", c.path, len(c.body), c.chunked, w.Code, w.Body.String())
+			continue
+		}
+		if c.status == http.StatusRequestEntityTooLarge {
+			var problem ProblemDetails
+			json.Unmarshal(w.Body.Bytes(), &problem)
+			if problem.Code != "body_too_large" || w.Header().Get("Content-Type") != "application/problem+json" {
+				t.Errorf("%s problem got %s", c.path, w.Body.String())
+			}
+		}
+	}
+
+	// the handlers ignoring the deadline get 503 too
+	for _, path := range []string{"/slow", "/late", "/late-empty", "/late-invoker"} {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var problem ProblemDetails
+		json.Unmarshal(w.Body.Bytes(), &problem)
+		if w.Code != http.StatusServiceUnavailable || problem.Code != "timeout" {
+			t.Errorf("%s got %d %s", path, w.Code, w.Body.String())
+		}
+	}
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/patient", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("route without timeout got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,130 @@
+package hiweb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogEncoder formats a log entry as a line of buf
+type LogEncoder interface {
+	Encode(buf *bytes.Buffer, entry LogEntry) error
+}
+
+// badKey is the key of a value without a key, like log/slog
+const badKey = "!BADKEY"
+
+// logFields calls fn for the key/value pairs of kv, a value without a string key is named badKey
+func logFields(kv []interface{}, fn func(key string, value interface{})) {
+	for i := 0; i < len(kv); i++ {
+		key, ok := kv[i].(string)
+		if !ok || i+1 == len(kv) {
+			fn(badKey, kv[i])
+			continue
+		}
+		fn(key, kv[i+1])
+		i++
+	}
+}
+
+// TextEncoder formats the entries as key=value pairs,
+// e.g. time=2006-01-02T15:04:05.000Z07:00 level=INFO msg="request done" status=200
+type TextEncoder struct {
+	// TimeLayout is the layout of the time, RFC3339 with milliseconds when empty
+	TimeLayout string
+}
+
+const defaultLogTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+func (e TextEncoder) Encode(buf *bytes.Buffer, entry LogEntry) error {
+	layout := e.TimeLayout
+	if layout == "" {
+		layout = defaultLogTimeLayout
+	}
+	buf.WriteString("time=")
+	buf.WriteString(entry.Time.Format(layout))
+	buf.WriteString(" level=")
+	buf.WriteString(entry.Level.String())
+	buf.WriteString(" msg=")
+	writeTextValue(buf, entry.Message)
+	appendTextFields(buf, entry.Fields)
+	buf.WriteByte('\n')
+	return nil
+}
+
+func appendTextFields(buf *bytes.Buffer, kv []interface{}) {
+	logFields(kv, func(key string, value interface{}) {
+		buf.WriteByte(' ')
+		buf.WriteString(key)
+		buf.WriteByte('=')
+		writeTextValue(buf, textValue(value))
+	})
+}
+
+func textValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprint(value)
+}
+
+// writeTextValue quotes the values with spaces, quotes, = or control characters
+func writeTextValue(buf *bytes.Buffer, s string) {
+	if s == "" || strings.IndexFunc(s, func(r rune) bool {
+		return r <= ' ' || r == '"' || r == '=' || r == 0x7f
+	}) >= 0 {
+		buf.WriteString(strconv.Quote(s))
+		return
+	}
+	buf.WriteString(s)
+}
+
+// JSONEncoder formats the entries as JSON lines with the keys time, level and msg and the fields,
+// errors, durations and Stringers are written as strings
+type JSONEncoder struct{}
+
+func (JSONEncoder) Encode(buf *bytes.Buffer, entry LogEntry) error {
+	buf.WriteString(`{"time":`)
+	writeJSONValue(buf, entry.Time.Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeJSONValue(buf, entry.Level.String())
+	buf.WriteString(`,"msg":`)
+	writeJSONValue(buf, entry.Message)
+	logFields(entry.Fields, func(key string, value interface{}) {
+		buf.WriteByte(',')
+		writeJSONValue(buf, key)
+		buf.WriteByte(':')
+		switch v := value.(type) {
+		case error:
+			value = v.Error()
+		case time.Duration:
+			value = v.String()
+		case time.Time:
+			value = v.Format(time.RFC3339Nano)
+		case json.Marshaler:
+		case fmt.Stringer:
+			value = v.String()
+		}
+		writeJSONValue(buf, value)
+	})
+	buf.WriteString("}\n")
+	return nil
+}
+
+func writeJSONValue(buf *bytes.Buffer, value interface{}) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		content, _ = json.Marshal(fmt.Sprint(value))
+	}
+	buf.Write(content)
+}
@@ -1,10 +1,16 @@
 package hiweb
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"os"
 	"strings"
+	"sync"
+	"time"
 )
 
+// Logger is the printf style logger of Config.Logger
 type Logger interface {
 	Error(f interface{}, v ...interface{})
 	Debug(f interface{}, v ...interface{})
@@ -12,23 +18,201 @@ type Logger interface {
 	Info(f interface{}, v ...interface{})
 }
 
+// Level is the severity of a log entry, the values are those of log/slog
+type Level int
+
+const (
+	LevelDebug   Level = -4
+	LevelInfo    Level = 0
+	LevelWarning Level = 4
+	LevelError   Level = 8
+)
+
+func (l Level) String() string {
+	switch {
+	case l < LevelInfo:
+		return "DEBUG"
+	case l < LevelWarning:
+		return "INFO"
+	case l < LevelError:
+		return "WARN"
+	}
+	return "ERROR"
+}
+
+// ParseLevel parses debug, info, warn(ing) or error
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return LevelDebug, nil
+	case "info", "":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarning, nil
+	case "error":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q", s)
+}
+
+// FieldLogger is a structured Logger, the entries carry key/value fields
+type FieldLogger interface {
+	Logger
+	// Log writes msg at level with the key/value pairs kv, e.g. Log(LevelInfo, "paid", "order", id, "amount", 3)
+	Log(level Level, msg string, kv ...interface{})
+	// With returns a logger adding the key/value pairs kv to every entry
+	With(kv ...interface{}) FieldLogger
+	// Enabled reports whether the entries of level are written
+	Enabled(level Level) bool
+}
+
+// WithFields adds the key/value pairs kv to logger, a logger which is not a FieldLogger
+// gets the fields appended to its messages
+func WithFields(logger Logger, kv ...interface{}) FieldLogger {
+	if fl, ok := logger.(FieldLogger); ok {
+		return fl.With(kv...)
+	}
+	return &printfLogger{logger: logger, fields: kv}
+}
+
+// LogEntry is an entry passed to a LogEncoder
+type LogEntry struct {
+	Time    time.Time
+	Level   Level
+	Message string
+	// Fields are the key/value pairs of the entry
+	Fields []interface{}
+}
+
+// DefaultLogger writes the entries of Level and above to Writer by Encoder.
+// The zero value writes the info entries to os.Stderr as text.
 type DefaultLogger struct {
+	// Writer receives an entry per Write call, os.Stderr when nil
+	Writer io.Writer
+	// Level is the least level written, LevelInfo by default
+	Level Level
+	// Encoder formats the entries, TextEncoder when nil
+	Encoder LogEncoder
+
+	fields []interface{}
+}
+
+// NewLogger creates a DefaultLogger writing to w
+func NewLogger(w io.Writer, level Level, encoder LogEncoder) *DefaultLogger {
+	return &DefaultLogger{Writer: w, Level: level, Encoder: encoder}
+}
+
+// logWriteMu serializes the writes of the DefaultLoggers
+var logWriteMu sync.Mutex
+
+var logBufferPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+func (dl *DefaultLogger) Enabled(level Level) bool {
+	return level >= dl.Level
+}
+
+func (dl *DefaultLogger) With(kv ...interface{}) FieldLogger {
+	l := *dl
+	l.fields = append(append([]interface{}(nil), dl.fields...), kv...)
+	return &l
+}
+
+func (dl *DefaultLogger) Log(level Level, msg string, kv ...interface{}) {
+	if !dl.Enabled(level) {
+		return
+	}
+	entry := LogEntry{Time: time.Now(), Level: level, Message: msg, Fields: dl.fields}
+	if len(kv) > 0 {
+		entry.Fields = append(append([]interface{}(nil), dl.fields...), kv...)
+	}
+	encoder := dl.Encoder
+	if encoder == nil {
+		encoder = TextEncoder{}
+	}
+	w := dl.Writer
+	if w == nil {
+		w = os.Stderr
+	}
+	buf := logBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer logBufferPool.Put(buf)
+	if err := encoder.Encode(buf, entry); err != nil {
+		return
+	}
+	logWriteMu.Lock()
+	_, _ = w.Write(buf.Bytes())
+	logWriteMu.Unlock()
 }
 
 func (dl *DefaultLogger) Error(f interface{}, v ...interface{}) {
-	formatLog(f, v...)
+	if dl.Enabled(LevelError) {
+		dl.Log(LevelError, formatLog(f, v...))
+	}
 }
 
 func (dl *DefaultLogger) Warning(f interface{}, v ...interface{}) {
-	formatLog(f, v...)
+	if dl.Enabled(LevelWarning) {
+		dl.Log(LevelWarning, formatLog(f, v...))
+	}
 }
 
 func (dl *DefaultLogger) Info(f interface{}, v ...interface{}) {
-	formatLog(f, v...)
+	if dl.Enabled(LevelInfo) {
+		dl.Log(LevelInfo, formatLog(f, v...))
+	}
 }
 
 func (dl *DefaultLogger) Debug(f interface{}, v ...interface{}) {
-	formatLog(f, v...)
+	if dl.Enabled(LevelDebug) {
+		dl.Log(LevelDebug, formatLog(f, v...))
+	}
+}
+
+// printfLogger is the FieldLogger of a printf style Logger, the fields are appended to the messages as key=value
+type printfLogger struct {
+	logger Logger
+	fields []interface{}
+}
+
+func (l *printfLogger) Enabled(level Level) bool {
+	return true
+}
+
+func (l *printfLogger) With(kv ...interface{}) FieldLogger {
+	return &printfLogger{logger: l.logger, fields: append(append([]interface{}(nil), l.fields...), kv...)}
+}
+
+func (l *printfLogger) Log(level Level, msg string, kv ...interface{}) {
+	var buf bytes.Buffer
+	buf.WriteString(msg)
+	appendTextFields(&buf, append(append([]interface{}(nil), l.fields...), kv...))
+	msg = buf.String()
+	switch {
+	case level >= LevelError:
+		l.logger.Error(msg)
+	case level >= LevelWarning:
+		l.logger.Warning(msg)
+	case level >= LevelInfo:
+		l.logger.Info(msg)
+	default:
+		l.logger.Debug(msg)
+	}
+}
+
+func (l *printfLogger) Error(f interface{}, v ...interface{}) {
+	l.Log(LevelError, formatLog(f, v...))
+}
+
+func (l *printfLogger) Warning(f interface{}, v ...interface{}) {
+	l.Log(LevelWarning, formatLog(f, v...))
+}
+
+func (l *printfLogger) Info(f interface{}, v ...interface{}) {
+	l.Log(LevelInfo, formatLog(f, v...))
+}
+
+func (l *printfLogger) Debug(f interface{}, v ...interface{}) {
+	l.Log(LevelDebug, formatLog(f, v...))
 }
 
 func formatLog(f interface{}, v ...interface{}) string {
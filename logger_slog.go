@@ -0,0 +1,59 @@
+//go:build go1.21
+// +build go1.21
+
+package hiweb
+
+import (
+	"context"
+	"log/slog"
+)
+
+// slogLogger is the FieldLogger of a *slog.Logger
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger adapts logger to the FieldLogger of Config.Logger, slog.Default() when nil.
+// The levels are the slog levels, so the level filter of the slog handler applies.
+func NewSlogLogger(logger *slog.Logger) FieldLogger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &slogLogger{logger: logger}
+}
+
+func (l *slogLogger) Enabled(level Level) bool {
+	return l.logger.Enabled(context.Background(), slog.Level(level))
+}
+
+func (l *slogLogger) With(kv ...interface{}) FieldLogger {
+	return &slogLogger{logger: l.logger.With(kv...)}
+}
+
+func (l *slogLogger) Log(level Level, msg string, kv ...interface{}) {
+	l.logger.Log(context.Background(), slog.Level(level), msg, kv...)
+}
+
+func (l *slogLogger) Error(f interface{}, v ...interface{}) {
+	if l.Enabled(LevelError) {
+		l.Log(LevelError, formatLog(f, v...))
+	}
+}
+
+func (l *slogLogger) Warning(f interface{}, v ...interface{}) {
+	if l.Enabled(LevelWarning) {
+		l.Log(LevelWarning, formatLog(f, v...))
+	}
+}
+
+func (l *slogLogger) Info(f interface{}, v ...interface{}) {
+	if l.Enabled(LevelInfo) {
+		l.Log(LevelInfo, formatLog(f, v...))
+	}
+}
+
+func (l *slogLogger) Debug(f interface{}, v ...interface{}) {
+	if l.Enabled(LevelDebug) {
+		l.Log(LevelDebug, formatLog(f, v...))
+	}
+}
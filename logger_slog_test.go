@@ -0,0 +1,28 @@
+//go:build go1.21
+// +build go1.21
+
+package hiweb
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewSlogLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+	if logger.Enabled(LevelDebug) || !logger.Enabled(LevelWarning) {
+		t.Error("levels of the slog handler are not used")
+	}
+	logger.Debug("hidden")
+	logger.With("request_id", "r1").Log(LevelWarning, "slow", "ms", 900)
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("entry %s err:%s", buf.String(), err)
+	}
+	if entry["level"] != "WARN" || entry["msg"] != "slow" || entry["request_id"] != "r1" || entry["ms"] != float64(900) {
+		t.Errorf("slog entry got %v", entry)
+	}
+}
@@ -0,0 +1,132 @@
+package hiweb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type loggerTestController struct {
+	Controller
+}
+
+func (c *loggerTestController) Get(id int) (int, error) {
+	if id == 0 {
+		return 0, NewHTTPError(http.StatusNotFound, "order_not_found", "order is not found")
+	}
+	c.Ctx.Logger().Log(LevelDebug, "order loaded", "id", id)
+	return id, nil
+}
+
+func TestLoggerEncoders(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(&buf, LevelInfo, TextEncoder{})
+	logger.Debug("hidden %d", 1)
+	logger.With("app", "shop").Log(LevelWarning, "order paid", "order", "o 1", "amount", 3, "err", errors.New("late"), "odd")
+	logger.Info("plain", 2)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("text lines got %q", buf.String())
+	}
+	if !strings.Contains(lines[0], ` level=WARN msg="order paid" app=shop order="o 1" amount=3 err=late !BADKEY=odd`) {
+		t.Errorf("text entry got %s", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], "level=INFO msg=\"plain 2\"") {
+		t.Errorf("printf entry got %s", lines[1])
+	}
+
+	buf.Reset()
+	logger = NewLogger(&buf, LevelDebug, JSONEncoder{})
+	logger.With("request_id", "r1").Log(LevelDebug, "done", "latency", 1500*time.Millisecond, "status", 200)
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("json entry %s err:%s", buf.String(), err)
+	}
+	if entry["level"] != "DEBUG" || entry["msg"] != "done" || entry["request_id"] != "r1" || entry["latency"] != "1.5s" || entry["status"] != float64(200) {
+		t.Errorf("json entry got %v", entry)
+	}
+
+	if level, err := ParseLevel("warning"); err != nil || level != LevelWarning {
+		t.Errorf("parse level got %v %v", level, err)
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("unknown level is parsed")
+	}
+}
+
+type loggerTestPrintf struct {
+	lines []string
+}
+
+func (l *loggerTestPrintf) Error(f interface{}, v ...interface{}) {
+	l.lines = append(l.lines, "E "+formatLog(f, v...))
+}
+func (l *loggerTestPrintf) Warning(f interface{}, v ...interface{}) {
+	l.lines = append(l.lines, "W "+formatLog(f, v...))
+}
+func (l *loggerTestPrintf) Info(f interface{}, v ...interface{}) {
+	l.lines = append(l.lines, "I "+formatLog(f, v...))
+}
+func (l *loggerTestPrintf) Debug(f interface{}, v ...interface{}) {
+	l.lines = append(l.lines, "D "+formatLog(f, v...))
+}
+
+func TestRequestLogFields(t *testing.T) {
+	var buf bytes.Buffer
+	app := NewApp(func(c *Config) { c.Logger = NewLogger(&buf, LevelDebug, JSONEncoder{}) })
+	app.Route("/orders/{id}", &loggerTestController{}, "id", "get:Get", RouteOption{})
+
+	req := httptest.NewRequest(http.MethodGet, "/orders/7", nil)
+	req.Header.Set(RequestIDHeader, "req-7")
+	req.RemoteAddr = "10.0.0.1:5000"
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Header().Get(RequestIDHeader) != "req-7" {
+		t.Errorf("request id header got %q", w.Header().Get(RequestIDHeader))
+	}
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("entry %s err:%s", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("entries got %s", buf.String())
+	}
+	for _, entry := range entries {
+		if entry["request_id"] != "req-7" || entry["method"] != "GET" || entry["route"] != "/orders/{id}" || entry["remote_ip"] != "10.0.0.1" {
+			t.Errorf("request fields got %v", entry)
+		}
+	}
+	if entries[0]["msg"] != "order loaded" || entries[0]["id"] != float64(7) {
+		t.Errorf("handler entry got %v", entries[0])
+	}
+	if entries[1]["msg"] != "request" || entries[1]["status"] != float64(200) || entries[1]["latency"] == nil {
+		t.Errorf("request entry got %v", entries[1])
+	}
+
+	// a printf logger gets the fields in the message, an invalid request id is replaced
+	printf := &loggerTestPrintf{}
+	app.Config.Logger = printf
+	req = httptest.NewRequest(http.MethodGet, "/orders/0", nil)
+	req.Header.Set(RequestIDHeader, "bad id")
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	id := w.Header().Get(RequestIDHeader)
+	if len(id) != 32 || len(printf.lines) != 2 {
+		t.Fatalf("request id %q lines %q", id, printf.lines)
+	}
+	if !strings.HasPrefix(printf.lines[0], "W request error request_id="+id) || !strings.Contains(printf.lines[0], "status=404") {
+		t.Errorf("error line got %s", printf.lines[0])
+	}
+	if !strings.HasPrefix(printf.lines[1], "I request request_id="+id) || !strings.Contains(printf.lines[1], "status=404") {
+		t.Errorf("request line got %s", printf.lines[1])
+	}
+}
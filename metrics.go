@@ -0,0 +1,472 @@
+package hiweb
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MetricsContentType is the content type of the Prometheus text exposition format
+const MetricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the latency buckets in seconds, the ones of the Prometheus client libraries
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// SizeBuckets are the buckets of the response sizes in bytes
+var SizeBuckets = []float64{100, 1000, 10000, 100000, 1e6, 1e7, 1e8}
+
+// metric is a metric family of a MetricsRegistry
+type metric interface {
+	write(buf *bytes.Buffer)
+}
+
+// MetricsRegistry keeps the metrics of an app and writes them in the Prometheus text format.
+// The metrics are created on the first use of their name, a name used again returns the same metric.
+type MetricsRegistry struct {
+	mu      sync.RWMutex
+	metrics map[string]metric
+}
+
+// NewMetricsRegistry creates an empty registry
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{metrics: make(map[string]metric)}
+}
+
+// register returns the metric of name or adds the one created by newMetric,
+// it panics when name is a metric of another type like App.Route does for a route registered twice
+func (r *MetricsRegistry) register(name string, newMetric func() metric, same func(m metric) bool) metric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if m, has := r.metrics[name]; has {
+		if !same(m) {
+			panic(fmt.Sprintf("metric %s is registered with another type", name))
+		}
+		return m
+	}
+	m := newMetric()
+	r.metrics[name] = m
+	return m
+}
+
+// Counter returns the counter of name with the label names labels
+func (r *MetricsRegistry) Counter(name, help string, labels ...string) *CounterVec {
+	return r.register(name, func() metric {
+		m := &CounterVec{}
+		m.init(name, help, labels)
+		return m
+	}, func(m metric) bool {
+		_, ok := m.(*CounterVec)
+		return ok
+	}).(*CounterVec)
+}
+
+// Gauge returns the gauge of name with the label names labels
+func (r *MetricsRegistry) Gauge(name, help string, labels ...string) *GaugeVec {
+	return r.register(name, func() metric {
+		m := &GaugeVec{}
+		m.init(name, help, labels)
+		return m
+	}, func(m metric) bool {
+		_, ok := m.(*GaugeVec)
+		return ok
+	}).(*GaugeVec)
+}
+
+// Histogram returns the histogram of name with the upper bounds buckets, DefaultBuckets when empty
+func (r *MetricsRegistry) Histogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return r.register(name, func() metric {
+		if len(buckets) == 0 {
+			buckets = DefaultBuckets
+		}
+		bounds := append([]float64(nil), buckets...)
+		sort.Float64s(bounds)
+		m := &HistogramVec{buckets: bounds}
+		m.init(name, help, labels)
+		return m
+	}, func(m metric) bool {
+		_, ok := m.(*HistogramVec)
+		return ok
+	}).(*HistogramVec)
+}
+
+// GaugeFunc registers a gauge without labels whose value is read by fn on every scrape
+func (r *MetricsRegistry) GaugeFunc(name, help string, fn func() float64) {
+	r.register(name, func() metric {
+		return &gaugeFunc{name: name, help: help, fn: fn}
+	}, func(m metric) bool {
+		_, ok := m.(*gaugeFunc)
+		return ok
+	})
+}
+
+// WriteText writes the metrics in the Prometheus text exposition format, sorted by name
+func (r *MetricsRegistry) WriteText(w io.Writer) error {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	metrics := make([]metric, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		metrics = append(metrics, r.metrics[name])
+	}
+	r.mu.RUnlock()
+	var buf bytes.Buffer
+	for _, m := range metrics {
+		m.write(&buf)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// ServeHTTP serves the metrics, so the registry can be mounted on any mux
+func (r *MetricsRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", MetricsContentType)
+	_ = r.WriteText(w)
+}
+
+// vec keeps the values of a metric by their label values
+type vec struct {
+	name   string
+	help   string
+	labels []string
+	mu     sync.Mutex
+	values map[string]*sample
+}
+
+// sample is the value of a set of label values, counts are the cumulative bucket counts of histograms
+type sample struct {
+	labelValues []string
+	value       float64
+	counts      []uint64
+	count       uint64
+}
+
+func (v *vec) init(name, help string, labels []string) {
+	v.name, v.help, v.labels, v.values = name, help, labels, make(map[string]*sample)
+}
+
+// value returns the value of labelValues, 0 when it is not recorded
+func (v *vec) value(labelValues []string) float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if s, has := v.values[strings.Join(labelValues, "\xff")]; has {
+		return s.value
+	}
+	return 0
+}
+
+// sample returns the sample of labelValues, it is called with mu locked
+func (v *vec) sample(labelValues []string) *sample {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metric %s has labels %v, got values %v", v.name, v.labels, labelValues))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, has := v.values[key]
+	if !has {
+		s = &sample{labelValues: append([]string(nil), labelValues...)}
+		v.values[key] = s
+	}
+	return s
+}
+
+// sorted returns the samples sorted by their label values, it is called with mu locked
+func (v *vec) sorted() []*sample {
+	keys := make([]string, 0, len(v.values))
+	for key := range v.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	samples := make([]*sample, len(keys))
+	for i, key := range keys {
+		samples[i] = v.values[key]
+	}
+	return samples
+}
+
+func (v *vec) writeHeader(buf *bytes.Buffer, typ string) {
+	if v.help != "" {
+		fmt.Fprintf(buf, "# HELP %s %s\n", v.name, escapeHelp(v.help))
+	}
+	fmt.Fprintf(buf, "# TYPE %s %s\n", v.name, typ)
+}
+
+// writeSample writes a line of name with the labels of names and values plus the extra label pair
+func writeSample(buf *bytes.Buffer, name string, names, values []string, extraName, extraValue string, value float64) {
+	buf.WriteString(name)
+	if len(names) > 0 || extraName != "" {
+		buf.WriteByte('{')
+		for i, label := range names {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(label)
+			buf.WriteString(`="`)
+			buf.WriteString(escapeLabel(values[i]))
+			buf.WriteByte('"')
+		}
+		if extraName != "" {
+			if len(names) > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(extraName)
+			buf.WriteString(`="`)
+			buf.WriteString(extraValue)
+			buf.WriteByte('"')
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(formatMetricValue(value))
+	buf.WriteByte('\n')
+}
+
+func formatMetricValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+// CounterVec is a counter by label values
+type CounterVec struct {
+	vec
+}
+
+// Add adds v to the counter of labelValues, v must not be negative
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("counter %s cannot decrease", c.name))
+	}
+	c.mu.Lock()
+	c.sample(labelValues).value += v
+	c.mu.Unlock()
+}
+
+// Inc adds 1 to the counter of labelValues
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Value returns the counter of labelValues
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	return c.value(labelValues)
+}
+
+func (c *CounterVec) write(buf *bytes.Buffer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeHeader(buf, "counter")
+	for _, s := range c.sorted() {
+		writeSample(buf, c.name, c.labels, s.labelValues, "", "", s.value)
+	}
+}
+
+// GaugeVec is a gauge by label values
+type GaugeVec struct {
+	vec
+}
+
+// Set sets the gauge of labelValues to v
+func (g *GaugeVec) Set(v float64, labelValues ...string) {
+	g.mu.Lock()
+	g.sample(labelValues).value = v
+	g.mu.Unlock()
+}
+
+// Add adds v, which may be negative, to the gauge of labelValues
+func (g *GaugeVec) Add(v float64, labelValues ...string) {
+	g.mu.Lock()
+	g.sample(labelValues).value += v
+	g.mu.Unlock()
+}
+
+// Value returns the gauge of labelValues
+func (g *GaugeVec) Value(labelValues ...string) float64 {
+	return g.value(labelValues)
+}
+
+func (g *GaugeVec) write(buf *bytes.Buffer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.writeHeader(buf, "gauge")
+	for _, s := range g.sorted() {
+		writeSample(buf, g.name, g.labels, s.labelValues, "", "", s.value)
+	}
+}
+
+// HistogramVec is a histogram by label values
+type HistogramVec struct {
+	vec
+	buckets []float64
+}
+
+// Observe adds v to the histogram of labelValues
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.sample(labelValues)
+	if s.counts == nil {
+		s.counts = make([]uint64, len(h.buckets))
+	}
+	for i, bound := range h.buckets {
+		if v <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.value += v
+}
+
+func (h *HistogramVec) write(buf *bytes.Buffer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writeHeader(buf, "histogram")
+	for _, s := range h.sorted() {
+		for i, bound := range h.buckets {
+			writeSample(buf, h.name+"_bucket", h.labels, s.labelValues, "le", formatMetricValue(bound), float64(s.counts[i]))
+		}
+		writeSample(buf, h.name+"_bucket", h.labels, s.labelValues, "le", "+Inf", float64(s.count))
+		writeSample(buf, h.name+"_sum", h.labels, s.labelValues, "", "", s.value)
+		writeSample(buf, h.name+"_count", h.labels, s.labelValues, "", "", float64(s.count))
+	}
+}
+
+// gaugeFunc is a gauge read on every scrape
+type gaugeFunc struct {
+	name string
+	help string
+	fn   func() float64
+}
+
+func (g *gaugeFunc) write(buf *bytes.Buffer) {
+	if g.help != "" {
+		fmt.Fprintf(buf, "# HELP %s %s\n", g.name, escapeHelp(g.help))
+	}
+	fmt.Fprintf(buf, "# TYPE %s gauge\n", g.name)
+	writeSample(buf, g.name, nil, nil, "", "", g.fn())
+}
+
+// httpMetrics are the built-in metrics recorded by App.ServeHTTP
+type httpMetrics struct {
+	requests          *CounterVec
+	inFlight          *GaugeVec
+	duration          *HistogramVec
+	size              *HistogramVec
+	compressionInput  *CounterVec
+	compressionOutput *CounterVec
+	compressionRatio  *HistogramVec
+}
+
+// Metrics returns the metrics registry of the app, it has the built-in http and session metrics
+// and the metrics of the application can be added to it
+func (app *App) Metrics() *MetricsRegistry {
+	app.metricsOnce.Do(func() {
+		r := NewMetricsRegistry()
+		app.httpMetrics = &httpMetrics{
+			requests:          r.Counter("hiweb_http_requests_total", "Requests by method, route template and status.", "method", "route", "status"),
+			inFlight:          r.Gauge("hiweb_http_requests_in_flight", "Requests being served."),
+			duration:          r.Histogram("hiweb_http_request_duration_seconds", "Latency of the requests in seconds.", DefaultBuckets, "method", "route", "status"),
+			size:              r.Histogram("hiweb_http_response_size_bytes", "Size of the response bodies in bytes.", SizeBuckets, "method", "route", "status"),
+			compressionInput:  r.Counter("hiweb_http_compression_input_bytes_total", "Bytes compressed by ServeBody.", "encoding"),
+			compressionOutput: r.Counter("hiweb_http_compression_output_bytes_total", "Compressed bytes written by ServeBody.", "encoding"),
+			compressionRatio:  r.Histogram("hiweb_http_compression_ratio", "Compressed size divided by the original size of the ServeBody responses.", []float64{.1, .2, .3, .4, .5, .6, .7, .8, .9, 1}, "encoding"),
+		}
+		r.GaugeFunc("hiweb_sessions", "Sessions in the session store, NaN when the store cannot count them.", app.sessionCount)
+		app.metrics = r
+	})
+	return app.metrics
+}
+
+// sessionCount returns the size of a session store with a Len method, such as MemorySessionStore and FileSessionStore
+func (app *App) sessionCount() float64 {
+	app.sessionMu.Lock()
+	store := app.Config.SessionStore
+	app.sessionMu.Unlock()
+	if store == nil {
+		return 0
+	}
+	if counter, ok := store.(interface{ Len() int }); ok {
+		return float64(counter.Len())
+	}
+	return math.NaN()
+}
+
+func (app *App) serverMetrics() *httpMetrics {
+	app.Metrics()
+	return app.httpMetrics
+}
+
+// otherLabel is the route label of the requests without a route and the method label of the non-standard methods,
+// so the clients can not add series by the paths or the methods they send
+const otherLabel = "other"
+
+// standardMethods are the methods with their own method label
+var standardMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true, http.MethodPatch: true,
+	http.MethodDelete: true, http.MethodConnect: true, http.MethodOptions: true, http.MethodTrace: true,
+}
+
+// observeRequest records a served request of the route template
+func (m *httpMetrics) observeRequest(ctx *WebContext, seconds float64) {
+	method, route, status := ctx.Request.Method, ctx.route, strconv.Itoa(ctx.recorder.Status())
+	if !standardMethods[method] {
+		method = otherLabel
+	}
+	if route == "" {
+		route = otherLabel
+	}
+	m.requests.Inc(method, route, status)
+	m.duration.Observe(seconds, method, route, status)
+	m.size.Observe(float64(ctx.recorder.Size()), method, route, status)
+}
+
+// observeCompression records a body of input bytes written as output bytes by encoding
+func (m *httpMetrics) observeCompression(encoding string, input, output int) {
+	if input == 0 {
+		return
+	}
+	m.compressionInput.Add(float64(input), encoding)
+	m.compressionOutput.Add(float64(output), encoding)
+	m.compressionRatio.Observe(float64(output)/float64(input), encoding)
+}
+
+// registerMetrics serves the metrics of the app at Config.MetricsPath, it is registered by App.Run
+func (app *App) registerMetrics() {
+	path := app.Config.MetricsPath
+	if path == "" || app.hasRoute(http.MethodGet, path) {
+		return
+	}
+	app.handle(http.MethodGet, path, func(ctx *WebContext) error {
+		var buf bytes.Buffer
+		if err := app.Metrics().WriteText(&buf); err != nil {
+			return err
+		}
+		ctx.ResponseWriter.Header().Set("Content-Type", MetricsContentType)
+		return ctx.ServeBody(http.StatusOK, buf.Bytes())
+	})
+}
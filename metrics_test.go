@@ -0,0 +1,115 @@
+package hiweb
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type metricsTestController struct {
+	Controller
+}
+
+func (c *metricsTestController) Get(id int) (string, error) {
+	if id == 0 {
+		return "", NewHTTPError(http.StatusNotFound, "order_not_found", "order is not found")
+	}
+	return strings.Repeat("order ", 100), nil
+}
+
+func TestMetricsRegistry(t *testing.T) {
+	r := NewMetricsRegistry()
+	jobs := r.Counter("jobs_total", "Jobs done.", "queue")
+	jobs.Inc("mail")
+	jobs.Add(2, `a"b\c`)
+	r.Gauge("workers", "Busy\nworkers.").Set(3)
+	latency := r.Histogram("job_seconds", "", []float64{1, 0.5}, "queue")
+	latency.Observe(0.2, "mail")
+	latency.Observe(0.7, "mail")
+	latency.Observe(3, "mail")
+	r.GaugeFunc("queue_size", "Queued jobs.", func() float64 { return 7 })
+	if r.Counter("jobs_total", "", "queue") != jobs || jobs.Value("mail") != 1 {
+		t.Error("counter is not shared by name")
+	}
+
+	var buf bytes.Buffer
+	r.WriteText(&buf)
+	want := `# TYPE job_seconds histogram
+job_seconds_bucket{queue="mail",le="0.5"} 1
+job_seconds_bucket{queue="mail",le="1"} 2
+job_seconds_bucket{queue="mail",le="+Inf"} 3
+job_seconds_sum{queue="mail"} 3.9
+job_seconds_count{queue="mail"} 3
+# HELP jobs_total Jobs done.
+# TYPE jobs_total counter
+jobs_total{queue="a\"b\\c"} 2
+jobs_total{queue="mail"} 1
+# HELP queue_size Queued jobs.
+# TYPE queue_size gauge
+queue_size 7
+# HELP workers Busy\nworkers.
+# TYPE workers gauge
+workers 3
+`
+	if buf.String() != want {
+		t.Errorf("metrics got\n%s", buf.String())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("metric of another type is registered")
+		}
+	}()
+	r.Gauge("jobs_total", "")
+}
+
+func TestAppMetrics(t *testing.T) {
+	minLength, level, methodOnly, methods := gzipMinLength, gzipCompressLevel, getMethodOnly, includedMethods
+	defer func() {
+		gzipMinLength, gzipCompressLevel, getMethodOnly, includedMethods = minLength, level, methodOnly, methods
+	}()
+	InitGzip(20, 1, nil)
+	app := NewApp()
+	app.Route("/orders/{id}", &metricsTestController{}, "id", "get:Get", RouteOption{})
+	app.registerHealth()
+	if _, err := app.NewSession(map[string]interface{}{"user": "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/orders/1", "/orders/2", "/orders/0", "/missing"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		app.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	for _, path := range []string{"/orders/1", "/orders/2", "/orders/3"} {
+		app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodOptions, path, nil))
+	}
+	for _, method := range []string{"FOO", "BAR"} {
+		app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/missing/"+method, nil))
+	}
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != MetricsContentType {
+		t.Fatalf("metrics got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	body := w.Body.String()
+	for _, line := range []string{
+		`hiweb_http_requests_total{method="GET",route="/orders/{id}",status="200"} 2`,
+		`hiweb_http_requests_total{method="GET",route="/orders/{id}",status="404"} 1`,
+		`hiweb_http_requests_total{method="GET",route="other",status="404"} 1`,
+		`hiweb_http_requests_total{method="OPTIONS",route="/orders/{id}",status="204"} 3`,
+		`hiweb_http_requests_total{method="other",route="other",status="404"} 2`,
+		`hiweb_http_request_duration_seconds_count{method="GET",route="/orders/{id}",status="200"} 2`,
+		`hiweb_http_response_size_bytes_bucket{method="GET",route="/orders/{id}",status="200",le="100"} 2`,
+		`hiweb_http_compression_input_bytes_total{encoding="gzip"} 1204`,
+		`hiweb_http_compression_ratio_count{encoding="gzip"} 2`,
+		`hiweb_http_requests_in_flight 1`,
+		`hiweb_sessions 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("metrics miss %s", line)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package hiweb
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+)
+
+// Next handles the request of a route, the returned error is written to the client
+type Next func(ctx *WebContext) error
+
+// Middleware wraps the next handler of the chain.
+// Middlewares can be registered globally (App.Use), per controller (App.UseController)
+// and per route by name (App.RegisterMiddleware, RouteOption.Middlewares or the @Middleware annotation).
+type Middleware func(next Next) Next
+
+// Chain composes the middlewares around next, the first middleware is the outermost
+func Chain(next Next, middlewares ...Middleware) Next {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		next = middlewares[i](next)
+	}
+	return next
+}
+
+// Use adds middlewares wrapping every route of the app, including files and swagger routes.
+// Use is not safe to call while the app is serving requests.
+func (app *App) Use(middlewares ...Middleware) {
+	app.middlewares = append(app.middlewares, middlewares...)
+}
+
+// UseController adds middlewares wrapping every route of the controller type of obj
+func (app *App) UseController(obj ControllerInterface, middlewares ...Middleware) {
+	app.middlewareMu.Lock()
+	defer app.middlewareMu.Unlock()
+	if app.controllerMiddlewares == nil {
+		app.controllerMiddlewares = make(map[reflect.Type][]Middleware)
+	}
+	t := reflect.TypeOf(obj)
+	app.controllerMiddlewares[t] = append(app.controllerMiddlewares[t], middlewares...)
+}
+
+// RegisterMiddleware names a middleware, routes select it by RouteOption.Middlewares
+func (app *App) RegisterMiddleware(name string, middleware Middleware) {
+	app.middlewareMu.Lock()
+	defer app.middlewareMu.Unlock()
+	if app.namedMiddlewares == nil {
+		app.namedMiddlewares = make(map[string]Middleware)
+	}
+	app.namedMiddlewares[name] = middleware
+}
+
+// routeChain wraps handler with the controller and the named route middlewares.
+// The chain is built on the first request, so middlewares can be registered after the routes.
+// A missing named middleware fails the request and is looked up again by the next one.
+func (app *App) routeChain(controller reflect.Type, names []string, handler Next) Next {
+	var mu sync.Mutex
+	var built atomic.Value
+	return func(ctx *WebContext) error {
+		chain, _ := built.Load().(Next)
+		if chain == nil {
+			mu.Lock()
+			if chain, _ = built.Load().(Next); chain == nil {
+				var err error
+				if chain, err = app.buildChain(controller, names, handler); err != nil {
+					mu.Unlock()
+					return err
+				}
+				built.Store(chain)
+			}
+			mu.Unlock()
+		}
+		return chain(ctx)
+	}
+}
+
+func (app *App) buildChain(controller reflect.Type, names []string, handler Next) (Next, error) {
+	app.middlewareMu.RLock()
+	defer app.middlewareMu.RUnlock()
+	middlewares := make([]Middleware, 0, len(names))
+	middlewares = append(middlewares, app.controllerMiddlewares[controller]...)
+	for _, name := range names {
+		middleware, has := app.namedMiddlewares[name]
+		if !has {
+			return nil, fmt.Errorf("middleware %s is not registered", name)
+		}
+		middlewares = append(middlewares, middleware)
+	}
+	return Chain(handler, middlewares...), nil
+}
+
+// Recover recovers the panics of the next handlers
+func Recover() Middleware {
+	return func(next Next) Next {
+		return func(ctx *WebContext) (err error) {
+			defer func() {
+				if e := recover(); e != nil {
+					ctx.Logger().Error("recover err:%s stack:%s", e, debug.Stack())
+					err = &HTTPError{
+						Status:  http.StatusInternalServerError,
+						Code:    "internal_error",
+						Message: http.StatusText(http.StatusInternalServerError),
+						Cause:   fmt.Errorf("panic: %v", e),
+					}
+				}
+			}()
+			return next(ctx)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package hiweb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type middlewareTestController struct {
+	Controller
+}
+
+func (c *middlewareTestController) Hello() {
+	_ = c.ServeBody(http.StatusOK, []byte(c.Ctx.ResponseWriter.Header().Get("X-Trace")))
+}
+
+func (c *middlewareTestController) Panic() {
+	panic("boom")
+}
+
+func traceMiddleware(name string) Middleware {
+	return func(next Next) Next {
+		return func(ctx *WebContext) error {
+			header := ctx.ResponseWriter.Header()
+			header.Set("X-Trace", strings.TrimPrefix(header.Get("X-Trace")+","+name, ","))
+			return next(ctx)
+		}
+	}
+}
+
+func TestMiddlewareChain(t *testing.T) {
+	app := NewApp()
+	app.Route("/hello", &middlewareTestController{}, "", "get:Hello", RouteOption{Middlewares: []string{"audit"}})
+	app.Route("/missing", &middlewareTestController{}, "", "get:Hello", RouteOption{Middlewares: []string{"missing"}})
+	app.Route("/panic", &middlewareTestController{}, "", "get:Panic", RouteOption{})
+	// middlewares registered after the routes are used as well
+	app.Use(traceMiddleware("global"))
+	app.UseController(&middlewareTestController{}, traceMiddleware("controller"))
+	app.RegisterMiddleware("audit", traceMiddleware("audit"))
+
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	if body, status := appTestGet(t, server.URL+"/hello"); status != http.StatusOK || body != "global,controller,audit" {
+		t.Errorf("hello got %d %q", status, body)
+	}
+	if _, status := appTestGet(t, server.URL+"/missing"); status != http.StatusInternalServerError {
+		t.Errorf("missing middleware got %d", status)
+	}
+	// the failure of a missing middleware is not kept once it is registered
+	app.RegisterMiddleware("missing", traceMiddleware("missing"))
+	if body, status := appTestGet(t, server.URL+"/missing"); status != http.StatusOK || body != "global,controller,missing" {
+		t.Errorf("missing middleware registered late got %d %q", status, body)
+	}
+	if _, status := appTestGet(t, server.URL+"/panic"); status == http.StatusOK {
+		t.Errorf("panic got %d", status)
+	}
+}
@@ -0,0 +1,365 @@
+package hiweb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// MsgpackCodec encodes MessagePack with the json names of the fields, like JSONCodec.
+// The values go through their json form, so []byte is a base64 string and time.Time an RFC 3339 string;
+// the bin and timestamp values of the bodies are decoded into them.
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.UseNumber()
+	var tree interface{}
+	if err := dec.Decode(&tree); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := writeMsgpack(&buf, tree); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (MsgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	r := &msgpackReader{data: data}
+	tree, err := r.read(0)
+	if err != nil {
+		return err
+	}
+	if r.pos != len(r.data) {
+		return errors.New("msgpack: extra data after the value")
+	}
+	content, err := json.Marshal(tree)
+	if err != nil {
+		return fmt.Errorf("msgpack: %w", err)
+	}
+	return json.Unmarshal(content, v)
+}
+
+// writeMsgpack writes a value decoded from json with UseNumber
+func writeMsgpack(buf *bytes.Buffer, v interface{}) error {
+	switch x := v.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if x {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			writeMsgpackInt(buf, i)
+		} else if u, err := strconv.ParseUint(string(x), 10, 64); err == nil {
+			buf.WriteByte(0xcf)
+			binary.Write(buf, binary.BigEndian, u)
+		} else {
+			f, err := x.Float64()
+			if err != nil {
+				return err
+			}
+			buf.WriteByte(0xcb)
+			binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+		}
+	case string:
+		writeMsgpackLength(buf, len(x), 0xa0, 32, 0xd9, 0xda, 0xdb)
+		buf.WriteString(x)
+	case []interface{}:
+		writeMsgpackLength(buf, len(x), 0x90, 16, 0, 0xdc, 0xdd)
+		for _, item := range x {
+			if err := writeMsgpack(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for key := range x {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		writeMsgpackLength(buf, len(x), 0x80, 16, 0, 0xde, 0xdf)
+		for _, key := range keys {
+			writeMsgpack(buf, key)
+			if err := writeMsgpack(buf, x[key]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: unexpected %T", v)
+	}
+	return nil
+}
+
+// writeMsgpackLength writes the header of a str, array or map of n items, in the fix format below fixMax,
+// code8 is 0 for the formats without an 8 bit length
+func writeMsgpackLength(buf *bytes.Buffer, n int, fix byte, fixMax int, code8, code16, code32 byte) {
+	switch {
+	case n < fixMax:
+		buf.WriteByte(fix | byte(n))
+	case code8 != 0 && n <= math.MaxUint8:
+		buf.WriteByte(code8)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(code16)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(code32)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+// writeMsgpackInt writes i in its smallest format
+func writeMsgpackInt(buf *bytes.Buffer, i int64) {
+	switch {
+	case i >= 0 && i <= math.MaxInt8:
+		buf.WriteByte(byte(i))
+	case i >= -32 && i < 0:
+		buf.WriteByte(byte(i))
+	case i > 0 && i <= math.MaxUint8:
+		buf.WriteByte(0xcc)
+		buf.WriteByte(byte(i))
+	case i > 0 && i <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		binary.Write(buf, binary.BigEndian, uint16(i))
+	case i > 0 && i <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		binary.Write(buf, binary.BigEndian, uint32(i))
+	case i > 0:
+		buf.WriteByte(0xcf)
+		binary.Write(buf, binary.BigEndian, uint64(i))
+	case i >= math.MinInt8:
+		buf.WriteByte(0xd0)
+		buf.WriteByte(byte(i))
+	case i >= math.MinInt16:
+		buf.WriteByte(0xd1)
+		binary.Write(buf, binary.BigEndian, int16(i))
+	case i >= math.MinInt32:
+		buf.WriteByte(0xd2)
+		binary.Write(buf, binary.BigEndian, int32(i))
+	default:
+		buf.WriteByte(0xd3)
+		binary.Write(buf, binary.BigEndian, i)
+	}
+}
+
+// msgpackMaxDepth limits the nesting of the decoded values
+const msgpackMaxDepth = 100
+
+var errMsgpackShort = errors.New("msgpack: unexpected end of data")
+
+type msgpackReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *msgpackReader) next(n int) ([]byte, error) {
+	if n < 0 || len(r.data)-r.pos < n {
+		return nil, errMsgpackShort
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *msgpackReader) uint(size int) (uint64, error) {
+	b, err := r.next(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// length reads a length of size bytes for n items of at least minItemSize bytes each
+func (r *msgpackReader) length(size int, minItemSize uint64) (int, error) {
+	n, err := r.uint(size)
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(len(r.data)-r.pos)/minItemSize {
+		return 0, errMsgpackShort
+	}
+	return int(n), nil
+}
+
+// read decodes a value into nil, bool, int64, uint64, float64, string, []byte, time.Time,
+// []interface{} or map[string]interface{}
+func (r *msgpackReader) read(depth int) (interface{}, error) {
+	if depth > msgpackMaxDepth {
+		return nil, errors.New("msgpack: values are nested too deeply")
+	}
+	b, err := r.next(1)
+	if err != nil {
+		return nil, err
+	}
+	code := b[0]
+	switch {
+	case code <= 0x7f:
+		return int64(code), nil
+	case code >= 0xe0:
+		return int64(int8(code)), nil
+	case code&0xf0 == 0x80:
+		return r.readMap(int(code&0x0f), depth)
+	case code&0xf0 == 0x90:
+		return r.readArray(int(code&0x0f), depth)
+	case code&0xe0 == 0xa0:
+		return r.readString(int(code & 0x1f))
+	}
+	switch code {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := r.length(1<<(code-0xc4), 1)
+		if err != nil {
+			return nil, err
+		}
+		data, err := r.next(n)
+		return append([]byte(nil), data...), err
+	case 0xc7, 0xc8, 0xc9:
+		n, err := r.length(1<<(code-0xc7), 1)
+		if err != nil {
+			return nil, err
+		}
+		return r.readExt(n)
+	case 0xca:
+		u, err := r.uint(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 0xcb:
+		u, err := r.uint(8)
+		return math.Float64frombits(u), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := r.uint(1 << (code - 0xcc))
+		if u <= math.MaxInt64 {
+			return int64(u), err
+		}
+		return u, err
+	case 0xd0:
+		u, err := r.uint(1)
+		return int64(int8(u)), err
+	case 0xd1:
+		u, err := r.uint(2)
+		return int64(int16(u)), err
+	case 0xd2:
+		u, err := r.uint(4)
+		return int64(int32(u)), err
+	case 0xd3:
+		u, err := r.uint(8)
+		return int64(u), err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return r.readExt(1 << (code - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := r.length(1<<(code-0xd9), 1)
+		if err != nil {
+			return nil, err
+		}
+		return r.readString(n)
+	case 0xdc, 0xdd:
+		n, err := r.length(2<<(code-0xdc), 1)
+		if err != nil {
+			return nil, err
+		}
+		return r.readArray(n, depth)
+	case 0xde, 0xdf:
+		n, err := r.length(2<<(code-0xde), 2)
+		if err != nil {
+			return nil, err
+		}
+		return r.readMap(n, depth)
+	}
+	return nil, fmt.Errorf("msgpack: unknown format 0x%x", code)
+}
+
+func (r *msgpackReader) readString(n int) (interface{}, error) {
+	b, err := r.next(n)
+	return string(b), err
+}
+
+// msgpackMaxPresize limits the capacity allocated for the lengths read from the data
+const msgpackMaxPresize = 64
+
+func (r *msgpackReader) readArray(n int, depth int) (interface{}, error) {
+	size := n
+	if size > msgpackMaxPresize {
+		size = msgpackMaxPresize
+	}
+	items := make([]interface{}, 0, size)
+	for i := 0; i < n; i++ {
+		item, err := r.read(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (r *msgpackReader) readMap(n int, depth int) (interface{}, error) {
+	m := make(map[string]interface{})
+	for i := 0; i < n; i++ {
+		key, err := r.read(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		value, err := r.read(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := key.(string); ok {
+			m[s] = value
+		} else {
+			m[fmt.Sprint(key)] = value
+		}
+	}
+	return m, nil
+}
+
+// readExt reads the data of an ext of n bytes, only the timestamp type -1 is known
+func (r *msgpackReader) readExt(n int) (interface{}, error) {
+	typ, err := r.next(1)
+	if err != nil {
+		return nil, err
+	}
+	data, err := r.next(n)
+	if err != nil {
+		return nil, err
+	}
+	if int8(typ[0]) != -1 {
+		return nil, fmt.Errorf("msgpack: unknown ext type %d", int8(typ[0]))
+	}
+	switch n {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0).UTC(), nil
+	case 8:
+		u := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(u&(1<<34-1)), int64(u>>34)).UTC(), nil
+	case 12:
+		return time.Unix(int64(binary.BigEndian.Uint64(data[4:])), int64(binary.BigEndian.Uint32(data))).UTC(), nil
+	}
+	return nil, fmt.Errorf("msgpack: timestamp of %d bytes", n)
+}
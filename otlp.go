@@ -0,0 +1,190 @@
+package hiweb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OTLPExporter sends the spans to an OpenTelemetry collector by OTLP/HTTP with the JSON encoding
+type OTLPExporter struct {
+	// Endpoint is the url of the traces, e.g. http://localhost:4318/v1/traces
+	Endpoint string
+	// ServiceName is the service.name of the resource
+	ServiceName string
+	// Headers are added to the export requests, e.g. the api key of the backend
+	Headers map[string]string
+	// Client sends the requests, a client with a 10s timeout when nil
+	Client *http.Client
+}
+
+// NewOTLPExporter creates an exporter sending to the collector at endpoint, e.g. http://localhost:4318,
+// the path /v1/traces is added when endpoint has no path
+func NewOTLPExporter(endpoint, serviceName string) *OTLPExporter {
+	endpoint = strings.TrimRight(endpoint, "/")
+	if i := strings.Index(endpoint, "://"); i >= 0 && !strings.Contains(endpoint[i+3:], "/") {
+		endpoint += "/v1/traces"
+	}
+	return &OTLPExporter{Endpoint: endpoint, ServiceName: serviceName}
+}
+
+// the OTLP JSON messages, the ids are hex and the 64 bit integers are strings
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	TraceState        string         `json:"traceState,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    StatusCode `json:"code,omitempty"`
+	Message string     `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func otlpValue(value interface{}) otlpAnyValue {
+	var v otlpAnyValue
+	switch x := value.(type) {
+	case string:
+		v.StringValue = &x
+	case bool:
+		v.BoolValue = &x
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32:
+		s := fmt.Sprint(x)
+		v.IntValue = &s
+	case float32:
+		f := float64(x)
+		v.DoubleValue = &f
+	case float64:
+		v.DoubleValue = &x
+	default:
+		s := fmt.Sprint(x)
+		v.StringValue = &s
+	}
+	return v
+}
+
+func otlpTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func otlpSpanOf(span *Span) otlpSpan {
+	span.mu.Lock()
+	defer span.mu.Unlock()
+	s := otlpSpan{
+		TraceID:           span.SpanContext.TraceID.String(),
+		SpanID:            span.SpanContext.SpanID.String(),
+		TraceState:        span.SpanContext.TraceState,
+		Name:              span.Name,
+		Kind:              span.Kind,
+		StartTimeUnixNano: otlpTime(span.StartTime),
+		EndTimeUnixNano:   otlpTime(span.EndTime),
+		Status:            otlpStatus{Code: span.Status, Message: span.StatusMsg},
+	}
+	if span.ParentSpanID.IsValid() {
+		s.ParentSpanID = span.ParentSpanID.String()
+	}
+	keys := make([]string, 0, len(span.Attributes))
+	for key := range span.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s.Attributes = append(s.Attributes, otlpKeyValue{Key: key, Value: otlpValue(span.Attributes[key])})
+	}
+	return s
+}
+
+// ExportSpans posts the spans to Endpoint
+func (e *OTLPExporter) ExportSpans(ctx context.Context, spans []*Span) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	scope := otlpScopeSpans{Scope: otlpScope{Name: "github.com/autumnzw/hiweb"}}
+	for _, span := range spans {
+		scope.Spans = append(scope.Spans, otlpSpanOf(span))
+	}
+	content, err := json.Marshal(otlpTraces{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpKeyValue{{Key: "service.name", Value: otlpValue(e.ServiceName)}}},
+		ScopeSpans: []otlpScopeSpans{scope},
+	}}})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.Endpoint, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.Headers {
+		req.Header.Set(key, value)
+	}
+	client := e.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("otlp: %s returns %s %s", e.Endpoint, resp.Status, bytes.TrimSpace(body))
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
+
+// Shutdown does nothing, the exporter keeps no connections of its own
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,330 @@
+package hiweb
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate is a number of requests allowed in a period
+type Rate struct {
+	Requests int
+	Period   time.Duration
+}
+
+var rateUnits = map[string]time.Duration{
+	"s": time.Second, "sec": time.Second, "second": time.Second,
+	"m": time.Minute, "min": time.Minute, "minute": time.Minute,
+	"h": time.Hour, "hour": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour,
+}
+
+// ParseRate parses a rate like 100/m, 10/s, 5000/h, 20/d or 100/10m
+func ParseRate(s string) (Rate, error) {
+	parts := strings.SplitN(strings.TrimSpace(s), "/", 2)
+	if len(parts) != 2 {
+		return Rate{}, fmt.Errorf("rate %q is not requests/period", s)
+	}
+	requests, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || requests <= 0 {
+		return Rate{}, fmt.Errorf("rate %q needs a positive number of requests", s)
+	}
+	unit := strings.ToLower(strings.TrimSpace(parts[1]))
+	period, has := rateUnits[unit]
+	if !has {
+		if period, err = time.ParseDuration(unit); err != nil || period <= 0 {
+			return Rate{}, fmt.Errorf("rate %q has an unknown period", s)
+		}
+	}
+	return Rate{Requests: requests, Period: period}, nil
+}
+
+func (r Rate) String() string {
+	return fmt.Sprintf("%d/%s", r.Requests, r.Period)
+}
+
+// RateLimitAlgorithm is how a RateLimitStore counts the requests
+type RateLimitAlgorithm string
+
+const (
+	// TokenBucket refills Requests tokens every Period, a full bucket allows a burst of Requests
+	TokenBucket RateLimitAlgorithm = "token_bucket"
+	// SlidingWindow counts the requests of the last Period, weighting the previous fixed window by its overlap
+	SlidingWindow RateLimitAlgorithm = "sliding_window"
+)
+
+// RateLimitResult is the decision of a store for a request
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is when the limit is fully available again
+	Reset time.Duration
+	// RetryAfter is when the next request is allowed, it is 0 for the allowed requests
+	RetryAfter time.Duration
+}
+
+// RateLimitStore counts the requests of the keys, MemoryRateLimitStore keeps them in the process,
+// a store shared by the instances of a service, e.g. on Redis, implements the algorithms atomically
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, algorithm RateLimitAlgorithm, rate Rate, now time.Time) (RateLimitResult, error)
+}
+
+// MemoryRateLimitStore keeps the counters in memory, the idle keys are removed as the store grows
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*rateState
+	sweep   int
+}
+
+// rateState is the bucket or the windows of a key
+type rateState struct {
+	tokens   float64
+	updated  time.Time
+	window   time.Time
+	current  int
+	previous int
+	period   time.Duration
+}
+
+// NewMemoryRateLimitStore creates an empty MemoryRateLimitStore
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]*rateState)}
+}
+
+// Len returns the number of the keys counted
+func (s *MemoryRateLimitStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.buckets)
+}
+
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, algorithm RateLimitAlgorithm, rate Rate, now time.Time) (RateLimitResult, error) {
+	if rate.Requests <= 0 || rate.Period <= 0 {
+		return RateLimitResult{}, fmt.Errorf("rate %s is not valid", rate)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.buckets == nil {
+		s.buckets = make(map[string]*rateState)
+	}
+	s.removeIdle(now)
+	key = string(algorithm) + ":" + key
+	state, has := s.buckets[key]
+	if !has {
+		state = &rateState{tokens: float64(rate.Requests), updated: now, window: now.Truncate(rate.Period)}
+		s.buckets[key] = state
+	}
+	state.period = rate.Period
+	switch algorithm {
+	case TokenBucket, "":
+		return state.takeToken(rate, now), nil
+	case SlidingWindow:
+		return state.takeWindow(rate, now), nil
+	}
+	return RateLimitResult{}, fmt.Errorf("rate limit algorithm %s is not supported", algorithm)
+}
+
+// removeIdle removes the keys idle for two periods, once every 1024 takes
+func (s *MemoryRateLimitStore) removeIdle(now time.Time) {
+	s.sweep++
+	if s.sweep < 1024 {
+		return
+	}
+	s.sweep = 0
+	for key, state := range s.buckets {
+		if now.Sub(state.updated) > 2*state.period {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func (b *rateState) takeToken(rate Rate, now time.Time) RateLimitResult {
+	perToken := float64(rate.Period) / float64(rate.Requests)
+	capacity := float64(rate.Requests)
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+float64(elapsed)/perToken)
+	}
+	b.updated = now
+	result := RateLimitResult{Limit: rate.Requests}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - b.tokens) * perToken)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = time.Duration((capacity - b.tokens) * perToken)
+	return result
+}
+
+func (b *rateState) takeWindow(rate Rate, now time.Time) RateLimitResult {
+	start := now.Truncate(rate.Period)
+	switch {
+	case start.Sub(b.window) == rate.Period:
+		b.previous, b.current = b.current, 0
+	case start.Sub(b.window) > rate.Period:
+		b.previous, b.current = 0, 0
+	}
+	b.window, b.updated = start, now
+	elapsed := now.Sub(start)
+	weight := 1 - float64(elapsed)/float64(rate.Period)
+	count := float64(b.previous)*weight + float64(b.current)
+	limit := float64(rate.Requests)
+	result := RateLimitResult{Limit: rate.Requests, Reset: rate.Period - elapsed}
+	if count+1 <= limit {
+		b.current++
+		result.Allowed = true
+		result.Remaining = int(limit - math.Ceil(count+1))
+		if result.Remaining < 0 {
+			result.Remaining = 0
+		}
+		return result
+	}
+	// the next request fits when the weight of the previous window is low enough
+	if b.current+1 <= rate.Requests && b.previous > 0 {
+		allowedWeight := (limit - float64(b.current) - 1) / float64(b.previous)
+		result.RetryAfter = time.Duration((1-allowedWeight)*float64(rate.Period)) - elapsed
+	} else {
+		// in the next window the current one is the previous one with a full weight
+		allowedWeight := (limit - 1) / float64(b.current)
+		result.RetryAfter = rate.Period - elapsed + time.Duration((1-allowedWeight)*float64(rate.Period))
+	}
+	if result.RetryAfter < 0 {
+		result.RetryAfter = 0
+	}
+	return result
+}
+
+// RateLimiter limits the requests of a key to Rate
+type RateLimiter struct {
+	Rate Rate
+	// Algorithm is TokenBucket when empty
+	Algorithm RateLimitAlgorithm
+	// Key returns the key of a request, RateLimitByIP when nil
+	Key func(ctx *WebContext) string
+	// Store counts the requests, a MemoryRateLimitStore of the app when nil
+	Store RateLimitStore
+}
+
+// RateLimitByIP keys the requests by the remote ip
+func RateLimitByIP(ctx *WebContext) string {
+	return "ip:" + ctx.RemoteIP()
+}
+
+// RateLimitBySubject keys the requests by the sub claim of the authenticated user, the remote ip otherwise.
+// Use it for the @RateLimit routes, which are limited after their auth.
+func RateLimitBySubject(ctx *WebContext) string {
+	if sub, ok := ctx.Claims()["sub"]; ok {
+		return "sub:" + fmt.Sprint(sub)
+	}
+	return RateLimitByIP(ctx)
+}
+
+// RateLimitByAPIKey keys the requests by the api key of header, X-API-Key when empty, the remote ip otherwise
+func RateLimitByAPIKey(header string) func(ctx *WebContext) string {
+	if header == "" {
+		header = "X-API-Key"
+	}
+	return func(ctx *WebContext) string {
+		if key := ctx.GetHeader(header); key != "" {
+			return "key:" + key
+		}
+		return RateLimitByIP(ctx)
+	}
+}
+
+// RateLimit limits every request passing it, e.g. app.Use(hiweb.RateLimit(hiweb.RateLimiter{Rate: hiweb.Rate{Requests: 100, Period: time.Minute}})).
+// The requests beyond the rate get 429 with Retry-After, all responses get the RateLimit-* headers.
+func RateLimit(limiter RateLimiter) Middleware {
+	return rateLimitMiddleware(limiter, "global")
+}
+
+func rateLimitMiddleware(limiter RateLimiter, scope string) Middleware {
+	return func(next Next) Next {
+		return func(ctx *WebContext) error {
+			if err := limiter.take(ctx, scope); err != nil {
+				return err
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// take counts the request in the scope, a failing store lets the request pass
+func (l RateLimiter) take(ctx *WebContext, scope string) error {
+	keyFunc, store := l.Key, l.Store
+	if keyFunc == nil {
+		keyFunc = RateLimitByIP
+	}
+	if store == nil {
+		store = ctx.App().rateLimitStore()
+	}
+	result, err := store.Take(ctx.Context(), scope+"|"+keyFunc(ctx), l.Algorithm, l.Rate, time.Now())
+	if err != nil {
+		ctx.Logger().Warning("rate limit err:%s", err)
+		return nil
+	}
+	headers := ctx.ResponseWriter.Header()
+	headers.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	headers.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	headers.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+	headers.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", l.Rate.Requests, ceilSeconds(l.Rate.Period)))
+	if result.Allowed {
+		return nil
+	}
+	retryAfter := ceilSeconds(result.RetryAfter)
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	headers.Set("Retry-After", strconv.Itoa(retryAfter))
+	return &HTTPError{
+		Status:  http.StatusTooManyRequests,
+		Code:    "rate_limited",
+		Message: fmt.Sprintf("rate limit %d/%s is exceeded, retry after %ds", l.Rate.Requests, l.Rate.Period, retryAfter),
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// rateLimitStore returns the MemoryRateLimitStore shared by the limiters of the app without a Store
+func (app *App) rateLimitStore() RateLimitStore {
+	app.rateLimitMu.Lock()
+	defer app.rateLimitMu.Unlock()
+	if app.rateLimits == nil {
+		app.rateLimits = NewMemoryRateLimitStore()
+	}
+	return app.rateLimits
+}
+
+// routeRateLimit applies RouteOption.RateLimit with the Key, Algorithm and Store of Config.RateLimiter,
+// every route counts its requests apart
+func routeRateLimit(method, pattern string, option RouteOption) Middleware {
+	return func(next Next) Next {
+		if option.RateLimit == "" {
+			return next
+		}
+		rate, err := ParseRate(option.RateLimit)
+		if err != nil {
+			panic(err)
+		}
+		return func(ctx *WebContext) error {
+			var limiter RateLimiter
+			if config := ctx.Config().RateLimiter; config != nil {
+				limiter = *config
+			}
+			limiter.Rate = rate
+			if err := limiter.take(ctx, method+" "+pattern); err != nil {
+				return err
+			}
+			return next(ctx)
+		}
+	}
+}
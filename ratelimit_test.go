@@ -0,0 +1,131 @@
+package hiweb
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type rateLimitTestController struct {
+	Controller
+}
+
+func (c *rateLimitTestController) Get() (string, error) {
+	return "ok", nil
+}
+
+func TestParseRate(t *testing.T) {
+	cases := map[string]Rate{
+		"100/m":     {100, time.Minute},
+		"10 / s":    {10, time.Second},
+		"5000/hour": {5000, time.Hour},
+		"20/d":      {20, 24 * time.Hour},
+		"100/10m":   {100, 10 * time.Minute},
+	}
+	for s, want := range cases {
+		if rate, err := ParseRate(s); err != nil || rate != want {
+			t.Errorf("rate %s got %v %v", s, rate, err)
+		}
+	}
+	for _, s := range []string{"", "100", "0/m", "-1/m", "x/m", "100/w", "100/-1m"} {
+		if _, err := ParseRate(s); err == nil {
+			t.Errorf("invalid rate %q is parsed", s)
+		}
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	rate := Rate{Requests: 3, Period: 3 * time.Second}
+	now := time.Unix(1000, 0)
+	for i := 0; i < 3; i++ {
+		result, _ := store.Take(context.Background(), "k", TokenBucket, rate, now)
+		if !result.Allowed || result.Remaining != 2-i {
+			t.Fatalf("take %d got %+v", i, result)
+		}
+	}
+	result, _ := store.Take(context.Background(), "k", TokenBucket, rate, now)
+	if result.Allowed || result.RetryAfter != time.Second || result.Reset != 3*time.Second {
+		t.Errorf("empty bucket got %+v", result)
+	}
+	if result, _ = store.Take(context.Background(), "k", TokenBucket, rate, now.Add(time.Second)); !result.Allowed || result.Remaining != 0 {
+		t.Errorf("refilled bucket got %+v", result)
+	}
+	if result, _ = store.Take(context.Background(), "other", TokenBucket, rate, now); !result.Allowed {
+		t.Errorf("another key got %+v", result)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	rate := Rate{Requests: 4, Period: 10 * time.Second}
+	start := time.Unix(1000, 0)
+	for i := 0; i < 4; i++ {
+		if result, _ := store.Take(context.Background(), "k", SlidingWindow, rate, start.Add(time.Second)); !result.Allowed {
+			t.Fatalf("take %d got %+v", i, result)
+		}
+	}
+	result, _ := store.Take(context.Background(), "k", SlidingWindow, rate, start.Add(2*time.Second))
+	if result.Allowed || result.RetryAfter != 10500*time.Millisecond {
+		t.Errorf("full window got %+v", result)
+	}
+	// a quarter into the next window the previous 4 requests weigh 3
+	result, _ = store.Take(context.Background(), "k", SlidingWindow, rate, start.Add(12500*time.Millisecond))
+	if !result.Allowed || result.Remaining != 0 {
+		t.Errorf("sliding window got %+v", result)
+	}
+	if result, _ = store.Take(context.Background(), "k", SlidingWindow, rate, start.Add(13*time.Second)); result.Allowed {
+		t.Errorf("sliding window got %+v", result)
+	}
+	if result, _ = store.Take(context.Background(), "k", SlidingWindow, rate, start.Add(35*time.Second)); !result.Allowed || result.Remaining != 3 {
+		t.Errorf("idle window got %+v", result)
+	}
+}
+
+func TestRateLimitRoute(t *testing.T) {
+	app := NewApp(func(c *Config) {
+		c.RateLimiter = &RateLimiter{Key: RateLimitByAPIKey("")}
+	})
+	app.Route("/limited", &rateLimitTestController{}, "", "get:Get", RouteOption{RateLimit: "2/m"})
+	app.Route("/free", &rateLimitTestController{}, "", "get:Get", RouteOption{})
+	get := func(path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w
+	}
+	for i := 0; i < 2; i++ {
+		if w := get("/limited", "k1"); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "2" {
+			t.Fatalf("request %d got %d %v", i, w.Code, w.Header())
+		}
+	}
+	w := get("/limited", "k1")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "30" || w.Header().Get("RateLimit-Remaining") != "0" ||
+		w.Header().Get("RateLimit-Policy") != "2;w=60" || w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("limited request got %d %v %s", w.Code, w.Header(), w.Body.String())
+	}
+	if w := get("/limited", "k2"); w.Code != http.StatusOK {
+		t.Errorf("another key got %d", w.Code)
+	}
+	if w := get("/free", "k1"); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+		t.Errorf("route without limit got %d %v", w.Code, w.Header())
+	}
+
+	app.Use(RateLimit(RateLimiter{Rate: Rate{Requests: 1, Period: time.Hour}, Algorithm: SlidingWindow}))
+	if w := get("/free", "k3"); w.Code != http.StatusOK {
+		t.Errorf("global limit got %d", w.Code)
+	}
+	if w := get("/free", "k4"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("global limit by ip got %d", w.Code)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("invalid rate limit is registered")
+		}
+	}()
+	app.Route("/invalid", &rateLimitTestController{}, "", "get:Get", RouteOption{RateLimit: "2/w"})
+}
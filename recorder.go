@@ -0,0 +1,78 @@
+package hiweb
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// ResponseRecorder is the http.ResponseWriter of the requests served by an App,
+// it records the status and the size of the response
+type ResponseRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (w *ResponseRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *ResponseRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// Status returns the status written, 200 when nothing is written yet
+func (w *ResponseRecorder) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Size returns the bytes of the body written
+func (w *ResponseRecorder) Size() int64 {
+	return w.size
+}
+
+// Written reports whether the header is written
+func (w *ResponseRecorder) Written() bool {
+	return w.status != 0
+}
+
+func (w *ResponseRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *ResponseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("response writer is not a http.Hijacker")
+}
+
+// Unwrap returns the writer of the server for http.ResponseController
+func (w *ResponseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// responseWritten reports whether the header of the response is written, false without a recorder
+func (c *WebContext) responseWritten() bool {
+	return c.recorder != nil && c.recorder.Written()
+}
+
+// Response returns the recorder of the response, it is nil when the context is not created by App.ServeHTTP
+func (c *WebContext) Response() *ResponseRecorder {
+	return c.recorder
+}
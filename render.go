@@ -0,0 +1,185 @@
+package hiweb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StatusCoder is implemented by the errors carrying the http status of the response
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// ErrorStatus returns the http status of an error returned by a controller method
+func ErrorStatus(err error) int {
+	var sc StatusCoder
+	if errors.As(err, &sc) {
+		return sc.StatusCode()
+	}
+	switch {
+	case errors.Is(err, os.ErrNotExist), errors.Is(err, sql.ErrNoRows):
+		return http.StatusNotFound
+	case errors.Is(err, os.ErrPermission):
+		return http.StatusForbidden
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+type acceptRange struct {
+	mediaType string
+	q         float64
+	index     int
+}
+
+// parseAccept returns the media ranges of an Accept header ordered by preference
+func parseAccept(accept string) []acceptRange {
+	ranges := make([]acceptRange, 0)
+	for i, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(fields[0]))
+		if mediaType == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType: mediaType, q: q, index: i})
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		// the more specific range wins
+		return strings.Count(ranges[i].mediaType, "*") < strings.Count(ranges[j].mediaType, "*")
+	})
+	return ranges
+}
+
+func (r acceptRange) match(mediaType string) bool {
+	if r.mediaType == "*/*" || r.mediaType == mediaType {
+		return true
+	}
+	if strings.HasSuffix(r.mediaType, "/*") {
+		return strings.HasPrefix(mediaType, r.mediaType[:len(r.mediaType)-1])
+	}
+	return false
+}
+
+// Negotiate returns the offered media type preferred by the Accept header,
+// the first offer when the header is empty and "" when no offer is acceptable.
+func Negotiate(accept string, offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+	for _, r := range parseAccept(accept) {
+		if r.q <= 0 {
+			continue
+		}
+		for _, offer := range offers {
+			if r.match(offer) {
+				return offer
+			}
+		}
+	}
+	return ""
+}
+
+// Render writes v with the media type negotiated by the Accept header of the request among the codecs,
+// see Config.Codecs and RouteOption.Produces. A nil v writes 204 No Content, []byte is written as
+// application/octet-stream and strings can be written as text/plain.
+func (c *WebContext) Render(status int, v interface{}) error {
+	if isNil(v) {
+		c.ResponseWriter.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	headers := c.ResponseWriter.Header()
+	accept := c.GetHeader("Accept")
+	switch t := v.(type) {
+	case []byte:
+		headers.Set("Content-Type", "application/octet-stream")
+		return c.ServeBody(status, t)
+	case string:
+		if Negotiate(accept, "application/json", "text/plain") == "text/plain" {
+			headers.Set("Content-Type", "text/plain; charset=utf-8")
+			return c.ServeBody(status, []byte(t))
+		}
+	}
+	mediaType, codec := c.negotiateCodec()
+	content, err := codec.Marshal(v)
+	if errors.Is(err, ErrUnsupportedType) {
+		return &HTTPError{Status: http.StatusNotAcceptable, Code: "not_acceptable", Message: fmt.Sprintf("the result can not be written as %s", mediaType), Cause: err}
+	}
+	if err != nil {
+		return err
+	}
+	headers.Set("Content-Type", contentTypeOf(mediaType))
+	headers.Add("Vary", "Accept")
+	return c.ServeBody(status, content)
+}
+
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// renderResults renders the results of a controller method, a non nil error result is returned,
+// otherwise the first result is rendered with status 200.
+func renderResults(ctx *WebContext, results []reflect.Value) error {
+	var value *reflect.Value
+	for i := range results {
+		if results[i].Type() == errorType {
+			if !results[i].IsNil() {
+				return results[i].Interface().(error)
+			}
+			continue
+		}
+		if value == nil {
+			value = &results[i]
+		}
+	}
+	if value == nil {
+		return nil
+	}
+	return RenderResult(ctx, value.Interface(), nil)
+}
+
+// RenderResult writes the results of a controller method like the routes called by reflection,
+// err is returned when not nil, otherwise v is rendered with status 200
+func RenderResult(ctx *WebContext, v interface{}, err error) error {
+	if err != nil {
+		return err
+	}
+	// the result of a method done after the route timeout is not written, the route answers 503
+	if err := ctx.Context().Err(); err == context.DeadlineExceeded {
+		return err
+	}
+	return ctx.Render(http.StatusOK, v)
+}
@@ -0,0 +1,74 @@
+package hiweb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		offers []string
+		want   string
+	}{
+		{"", []string{"application/json", "text/plain"}, "application/json"},
+		{"text/plain", []string{"application/json", "text/plain"}, "text/plain"},
+		{"text/*;q=0.5, application/json", []string{"text/plain", "application/json"}, "application/json"},
+		{"*/*;q=0.1, text/plain;q=0.8", []string{"application/json", "text/plain"}, "text/plain"},
+		{"application/json;q=0", []string{"application/json"}, ""},
+		{"image/png", []string{"application/json"}, ""},
+	}
+	for _, tt := range tests {
+		if got := Negotiate(tt.accept, tt.offers...); got != tt.want {
+			t.Errorf("Negotiate(%q, %v) = %q want %q", tt.accept, tt.offers, got, tt.want)
+		}
+	}
+}
+
+type renderTestUser struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type renderTestController struct {
+	Controller
+}
+
+func (c *renderTestController) Get(id int) (*renderTestUser, error) {
+	if id == 0 {
+		return nil, os.ErrNotExist
+	}
+	return &renderTestUser{ID: id, Name: "u"}, nil
+}
+
+func (c *renderTestController) Name() string {
+	return "hiweb"
+}
+
+func TestRouteResults(t *testing.T) {
+	app := NewApp()
+	app.Route("/users/{id:int}", &renderTestController{}, "id", "get:Get", RouteOption{})
+	app.Route("/name", &renderTestController{}, "", "get:Name", RouteOption{})
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	if body, status := appTestGet(t, server.URL+"/users/3"); status != http.StatusOK || body != `{"id":3,"name":"u"}` {
+		t.Errorf("get user got %d %s", status, body)
+	}
+	if _, status := appTestGet(t, server.URL+"/users/0"); status != http.StatusNotFound {
+		t.Errorf("get missing user got %d", status)
+	}
+	if body, status := appTestGet(t, server.URL+"/name"); status != http.StatusOK || body != `"hiweb"` {
+		t.Errorf("get name got %d %s", status, body)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/name", nil)
+	req.Header.Set("Accept", "text/plain")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Body.String() != "hiweb" || w.Header().Get("Content-Type") != "text/plain; charset=utf-8" {
+		t.Errorf("get text name got %s %v", w.Body.String(), w.Header())
+	}
+}
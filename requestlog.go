@@ -0,0 +1,57 @@
+package hiweb
+
+import (
+	"net/http"
+	"time"
+)
+
+// RequestIDHeader is the header of the request id, the id of the request is kept when it is valid,
+// otherwise a new one is generated, and it is sent back in the response
+const RequestIDHeader = "X-Request-ID"
+
+// requestID returns the valid request id of r or a new one
+func requestID(r *http.Request) string {
+	id := r.Header.Get(RequestIDHeader)
+	if id == "" || len(id) > 128 {
+		return UUID32()
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] >= 0x7f {
+			return UUID32()
+		}
+	}
+	return id
+}
+
+// RequestID returns the id of the request, see RequestIDHeader
+func (c *WebContext) RequestID() string {
+	return c.requestID
+}
+
+// Logger returns the logger of the app with the fields of the request: request_id, method, route and remote_ip
+func (c *WebContext) Logger() FieldLogger {
+	if c.logger == nil {
+		c.logger = WithFields(c.App().Logger(), "request_id", c.requestID, "method", c.Request.Method, "route", c.route, "remote_ip", c.RemoteIP())
+	}
+	return c.logger
+}
+
+// OnDone calls fn after the request is served and its error is written, e.g. to log the status of the response
+func (c *WebContext) OnDone(fn func(ctx *WebContext)) {
+	c.done = append(c.done, fn)
+}
+
+// finishRequest calls the OnDone functions in reverse order, records the metrics and logs the request
+func (app *App) finishRequest(ctx *WebContext, start time.Time) {
+	for i := len(ctx.done) - 1; i >= 0; i-- {
+		ctx.done[i](ctx)
+	}
+	metrics := app.serverMetrics()
+	metrics.inFlight.Add(-1)
+	metrics.observeRequest(ctx, time.Since(start).Seconds())
+	logger := ctx.Logger()
+	if !logger.Enabled(LevelInfo) {
+		return
+	}
+	logger.Log(LevelInfo, "request", "path", ctx.Request.URL.Path, "status", ctx.recorder.Status(), "latency", time.Since(start), "size", ctx.recorder.Size())
+}
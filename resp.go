@@ -0,0 +1,265 @@
+package hiweb
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RESPSessionStore keeps the sessions in a server speaking the Redis protocol (RESP),
+// so the sessions survive restarts and are shared by the instances of the app.
+// The session values are stored as json strings which expire by PEXPIRE.
+type RESPSessionStore struct {
+	// Addr is the host:port of the server
+	Addr string
+	// Password is sent by AUTH when it is not empty
+	Password string
+	// DB is selected by SELECT when it is not 0
+	DB int
+	// Prefix is prepended to the session ids, default hiweb:session:
+	Prefix string
+	// Timeout is the dial, read and write timeout, default 5s
+	Timeout time.Duration
+	// MaxIdle is the number of idle connections kept, default 4
+	MaxIdle int
+
+	mu   sync.Mutex
+	idle []*respConn
+}
+
+// NewRESPSessionStore creates a RESPSessionStore of the server at addr
+func NewRESPSessionStore(addr string) *RESPSessionStore {
+	return &RESPSessionStore{Addr: addr, Prefix: "hiweb:session:", Timeout: 5 * time.Second, MaxIdle: 4}
+}
+
+// errNil is the RESP null reply
+var errNil = errors.New("resp: nil")
+
+// respError is an error reply of the server
+type respError string
+
+func (e respError) Error() string {
+	return "resp: " + string(e)
+}
+
+type respConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+func (s *RESPSessionStore) timeout() time.Duration {
+	if s.Timeout <= 0 {
+		return 5 * time.Second
+	}
+	return s.Timeout
+}
+
+func (s *RESPSessionStore) dial() (*respConn, error) {
+	conn, err := net.DialTimeout("tcp", s.Addr, s.timeout())
+	if err != nil {
+		return nil, err
+	}
+	c := &respConn{conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+	if s.Password != "" {
+		if _, err := c.do(s.timeout(), "AUTH", s.Password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if s.DB != 0 {
+		if _, err := c.do(s.timeout(), "SELECT", strconv.Itoa(s.DB)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// do sends a command on an idle or new connection and reads the reply
+func (s *RESPSessionStore) do(args ...string) (interface{}, error) {
+	s.mu.Lock()
+	var c *respConn
+	if n := len(s.idle); n > 0 {
+		c, s.idle = s.idle[n-1], s.idle[:n-1]
+	}
+	s.mu.Unlock()
+	if c == nil {
+		var err error
+		if c, err = s.dial(); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := c.do(s.timeout(), args...)
+	var re respError
+	if err != nil && err != errNil && !errors.As(err, &re) {
+		// the connection is broken
+		c.conn.Close()
+		return nil, err
+	}
+	maxIdle := s.MaxIdle
+	if maxIdle <= 0 {
+		maxIdle = 4
+	}
+	s.mu.Lock()
+	if len(s.idle) < maxIdle {
+		s.idle = append(s.idle, c)
+		c = nil
+	}
+	s.mu.Unlock()
+	if c != nil {
+		c.conn.Close()
+	}
+	return reply, err
+}
+
+// Close closes the idle connections
+func (s *RESPSessionStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.idle {
+		c.conn.Close()
+	}
+	s.idle = nil
+	return nil
+}
+
+func (c *respConn) do(timeout time.Duration, args ...string) (interface{}, error) {
+	if err := c.conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+	return readRESP(c.r)
+}
+
+// readRESP reads a reply: simple string, error, integer, bulk string or array
+func readRESP(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("resp: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, respError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, errNil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, errNil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readRESP(r); err != nil && err != errNil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("resp: unknown reply %q", line)
+}
+
+func (s *RESPSessionStore) key(sid string) string {
+	return s.Prefix + sid
+}
+
+func (s *RESPSessionStore) Get(sid string) (map[string]interface{}, bool, error) {
+	reply, err := s.do("GET", s.key(sid))
+	if err == errNil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	content, ok := reply.(string)
+	if !ok {
+		return nil, false, fmt.Errorf("resp: unexpected reply %v", reply)
+	}
+	values := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(content), &values); err != nil {
+		return nil, false, err
+	}
+	return values, true, nil
+}
+
+func (s *RESPSessionStore) Set(sid string, values map[string]interface{}, ttl time.Duration) error {
+	content, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	_, err = s.do("SET", s.key(sid), string(content), "PX", strconv.FormatInt(ttlMillis(ttl), 10))
+	return err
+}
+
+func (s *RESPSessionStore) Delete(sid string) error {
+	_, err := s.do("DEL", s.key(sid))
+	return err
+}
+
+func (s *RESPSessionStore) Touch(sid string, ttl time.Duration) error {
+	reply, err := s.do("PEXPIRE", s.key(sid), strconv.FormatInt(ttlMillis(ttl), 10))
+	if err != nil {
+		return err
+	}
+	if n, _ := reply.(int64); n == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (s *RESPSessionStore) TTL(sid string) (time.Duration, error) {
+	reply, err := s.do("PTTL", s.key(sid))
+	if err != nil {
+		return 0, err
+	}
+	ms, _ := reply.(int64)
+	if ms < 0 {
+		// -2 is a missing key, -1 a key without expiry which is not written by this store
+		return 0, ErrSessionNotFound
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// ttlMillis rounds ttl up to milliseconds, the server rejects a 0 expiry
+func ttlMillis(ttl time.Duration) int64 {
+	ms := int64((ttl + time.Millisecond - 1) / time.Millisecond)
+	if ms < 1 {
+		ms = 1
+	}
+	return ms
+}
@@ -0,0 +1,137 @@
+package hiweb
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotateTimeLayout is the suffix of the rotated files, e.g. access.log.20240501-100000.000
+const rotateTimeLayout = "20060102-150405.000"
+
+// RotatingFile is an io.WriteCloser appending to Filename, the file is renamed with a time suffix
+// and a new one is started when it passes MaxSize or becomes older than MaxAge.
+type RotatingFile struct {
+	// Filename is the file written, its directory is created when missing
+	Filename string
+	// MaxSize is the largest size in bytes of the file, 0 is unlimited
+	MaxSize int64
+	// MaxAge is how long the file is written before it is rotated, 0 is unlimited
+	MaxAge time.Duration
+	// MaxBackups is how many rotated files are kept, 0 keeps all
+	MaxBackups int
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	created time.Time
+}
+
+// Write appends p to the file, rotating it first when p does not fit in MaxSize or the file is older than MaxAge
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+	if f.size > 0 && ((f.MaxSize > 0 && f.size+int64(len(p)) > f.MaxSize) || (f.MaxAge > 0 && time.Since(f.created) >= f.MaxAge)) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Rotate starts a new file now
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return err
+		}
+	}
+	return f.rotate()
+}
+
+// Close closes the file, the next Write opens it again
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// open opens the file for appending, the age of an existing file starts from its modification time
+func (f *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.Filename), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size, f.created = file, info.Size(), time.Now()
+	if info.Size() > 0 {
+		f.created = info.ModTime()
+	}
+	return nil
+}
+
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+	backup, now := "", time.Now()
+	for {
+		backup = f.Filename + "." + now.Format(rotateTimeLayout)
+		if _, err := os.Stat(backup); os.IsNotExist(err) {
+			break
+		}
+		now = now.Add(time.Millisecond)
+	}
+	if err := os.Rename(f.Filename, backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	f.removeBackups()
+	return f.open()
+}
+
+// removeBackups removes the oldest rotated files beyond MaxBackups
+func (f *RotatingFile) removeBackups() {
+	if f.MaxBackups <= 0 {
+		return
+	}
+	matches, err := filepath.Glob(f.Filename + ".*")
+	if err != nil {
+		return
+	}
+	backups := matches[:0]
+	for _, name := range matches {
+		if _, err := time.Parse(rotateTimeLayout, strings.TrimPrefix(name, f.Filename+".")); err == nil {
+			backups = append(backups, name)
+		}
+	}
+	sort.Strings(backups)
+	for len(backups) > f.MaxBackups {
+		os.Remove(backups[0])
+		backups = backups[1:]
+	}
+}
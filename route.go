@@ -1,218 +1,251 @@
 package hiweb
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"reflect"
-	"runtime/debug"
-	"strconv"
 	"strings"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/dgrijalva/jwt-go/request"
 )
 
+// ParamSource is where the value of a method argument is read from
+type ParamSource string
+
+const (
+	// SourceAuto reads the path params, then the query, form or json body by the Content-Type
+	SourceAuto   ParamSource = ""
+	SourcePath   ParamSource = "path"
+	SourceQuery  ParamSource = "query"
+	SourceHeader ParamSource = "header"
+	SourceCookie ParamSource = "cookie"
+	// SourceBody reads a key of the json or urlencoded body
+	SourceBody ParamSource = "body"
+	// SourceForm reads a field of the urlencoded or multipart form body
+	SourceForm ParamSource = "form"
+)
+
+// ParamSpec describes how a method argument is bound, it is generated from the @Param annotation
+type ParamSpec struct {
+	In       ParamSource
+	Required bool
+}
+
 type RouteOption struct {
 	IsAuth bool
+	// AuthSchemes are the names of the schemes accepted by the route, tried in order, e.g. @Auth apikey|bearer.
+	// Without them IsAuth uses Config.AuthHandler or the CheckAuth of the controller.
+	AuthSchemes []string
+	// Roles are the roles of which the claims need one, Scopes are the scopes the claims need all of,
+	// they are checked by Config.Authorizer after the auth, e.g. @Auth roles=admin,ops scopes=orders:write
+	Roles  []string
+	Scopes []string
+	// IPAccess is the name of the IPAccess registered by App.RegisterIPAccess which replaces Config.IPAccess
+	// for the route, e.g. @IPAccess internal
+	IPAccess string
+	// Middlewares are the names of the middlewares registered by App.RegisterMiddleware
+	Middlewares []string
+	// Timeout is the deadline of the request context (WebContext.Context) of the route, 0 is Config.RequestTimeout
+	// and a negative value is none, e.g. @Timeout 5s
+	Timeout time.Duration
+	// MaxBody is the limit of the request body in bytes, 0 is Config.MaxBodySize and a negative value is unlimited,
+	// e.g. @MaxBody 10MB
+	MaxBody int64
+	// RateLimit is the rate of the requests of a key to the route, e.g. @RateLimit 100/m, see ParseRate.
+	// The route counts its requests apart, with the Key, Algorithm and Store of Config.RateLimiter.
+	RateLimit string
+	// Consumes are the media types of the request bodies accepted by the route, others get 415, e.g. @Accept json,xml.
+	// Produces are the media types the responses are negotiated among, e.g. @Produce json,csv. Empty is all the codecs.
+	Consumes []string
+	Produces []string
+	// Params are the sources of the method arguments by name, SourceAuto for the missing names
+	Params map[string]ParamSpec
+	// New creates the controller of a request, reflect.New of the route controller type when nil
+	New func() ControllerInterface
+	// Invoke binds the arguments and calls the method on WebContext.Controller, webcmd generates it
+	// for every route so requests are served without reflection. The method is called by reflection when nil.
+	Invoke Next
 }
 
+// Route registers the method of obj on the default app, see App.Route
 func Route(rootpath string, obj ControllerInterface, paramNames string, mappingMethod string, option RouteOption) {
+	defaultApp.Route(rootpath, obj, paramNames, mappingMethod, option)
+}
+
+// urlParamsKey is the catch-all placeholder of the legacy routes ending in '/',
+// their segments are bound to the method arguments by position
+const urlParamsKey = "urlParams"
+
+// Route registers the controller method mappingMethod(httpMethod:funcMethod) of obj at rootpath,
+// paramNames are the method argument names separated by ';'.
+// The placeholders of rootpath, e.g. /orders/{orderId}/items/{itemId:int}, are bound to the arguments with the same name.
+func (app *App) Route(rootpath string, obj ControllerInterface, paramNames string, mappingMethod string, option RouteOption) {
 	t := reflect.TypeOf(obj)
 	params := strings.Split(paramNames, ";")
 	fms := strings.Split(mappingMethod, ":")
-	//httpMethod := fms[0]
+	httpMethod := strings.ToUpper(fms[0])
 	funcMethod := fms[1]
 	isUrlParam := false
+	pattern := rootpath
 	if strings.HasSuffix(rootpath, "/") {
 		isUrlParam = true
+		pattern = rootpath + "{*" + urlParamsKey + "}"
 	}
-	http.HandleFunc(rootpath, func(writer http.ResponseWriter, req *http.Request) {
-		defer func() {
-			if e := recover(); e != nil {
-				WebConfig.Logger.Error("recover err:%s stack:%s", e, debug.Stack())
-				writer.WriteHeader(http.StatusBadRequest)
-				fmt.Fprint(writer, "err param")
+	invoke := option.Invoke
+	if invoke == nil {
+		invoke = func(context *WebContext) error {
+			m := reflect.ValueOf(context.controller).MethodByName(funcMethod)
+			parameters, err := genParameters(context, m, params, m.Type().NumIn())
+			if err != nil {
+				return bindError(err)
 			}
-		}()
-
-		headers := writer.Header()
-		headers.Set("Access-Control-Allow-Origin", "*")
-		headers.Set("Access-Control-Allow-Headers", "*")
-		headers.Set("Access-Control-Allow-Method", "*")
-		headers.Set("Access-Control-Expose-Headers", "Content-Disposition")
-		headers.Set("Access-Control-Allow-Credentials", "true")
-		if strings.ToLower(req.Method) == "options" {
-			writer.WriteHeader(http.StatusOK)
-			return
+			return renderResults(context, m.Call(parameters))
 		}
-		if strings.ToLower(req.Method) != strings.ToLower(fms[0]) && fms[0] != "*" {
-			writer.WriteHeader(http.StatusNotFound)
-			fmt.Fprint(writer, "not found")
-			WebConfig.Logger.Error("%s not found route url:%s", req.Method, req.RequestURI)
-			return
+	}
+	traced := invoke
+	spanName := "invoke " + t.Elem().Name() + "." + funcMethod
+	invoke = func(context *WebContext) error {
+		return traceStep(context, spanName, func() error {
+			return traced(context)
+		})
+	}
+	newController := option.New
+	if newController == nil {
+		newController = func() ControllerInterface {
+			execController, ok := reflect.New(t.Elem()).Interface().(ControllerInterface)
+			if !ok {
+				panic("controller is not ControllerInterface")
+			}
+			return execController
 		}
-
-		vc := reflect.New(t.Elem())
-		execController, ok := vc.Interface().(ControllerInterface)
-		if !ok {
-			panic("controller is not ControllerInterface")
+	}
+	chain := Chain(app.routeChain(t, option.Middlewares, invoke), routeLimits(option), IPFilter(), routeAuth(option), routeRateLimit(httpMethod, pattern, option))
+	app.handle(httpMethod, pattern, func(context *WebContext) error {
+		if isUrlParam {
+			context.params = positionalParams(params, context.PathParam(urlParamsKey))
 		}
-		context := WebContext{req, writer, []byte{}}
-		remoteAddr := context.GetRemoteAddr()
-		if remoteAddr != "" && remoteAddr != "127.0.0.1" {
-			if _, ipHas := WebConfig.FilterIpMap[remoteAddr]; ipHas {
-				writer.WriteHeader(http.StatusNotFound)
-				fmt.Fprint(writer, "not found")
-				WebConfig.Logger.Error("filter ip:%s", remoteAddr)
-				return
-			}
+		context.option = &option
+		execController := newController()
+		execController.Init(context)
+		context.controller = execController
+		return chain(context)
+	})
+}
+
+// routeAuth checks the auth of the route by Config.AuthHandler or the CheckAuth of the controller
+func routeAuth(option RouteOption) Middleware {
+	return func(next Next) Next {
+		if !option.IsAuth && len(option.AuthSchemes) == 0 && len(option.Roles) == 0 && len(option.Scopes) == 0 {
+			return next
 		}
-		execController.Init(&context)
-		ct := context.GetHeader("Content-Type")
-		if option.IsAuth {
-			if WebConfig.AuthHandler != nil {
-				if err := WebConfig.AuthHandler(&context); err != nil {
-					writer.WriteHeader(http.StatusUnauthorized)
-					fmt.Fprint(writer, err.Error())
-					WebConfig.Logger.Error("%s no auth url:%s ip:%s ct:%s", req.Method, req.RequestURI, remoteAddr, ct)
-					return
+		return func(context *WebContext) error {
+			config := context.Config()
+			if len(option.AuthSchemes) > 0 {
+				if err := context.App().authenticate(context, option.AuthSchemes); err != nil {
+					return err
+				}
+			} else if config.AuthHandler != nil {
+				if err := config.AuthHandler(context); err != nil {
+					return WrapHTTPError(http.StatusUnauthorized, "unauthorized", err)
 				}
 			} else {
-				if valid, err := execController.CheckAuth(); err != nil && !valid {
-					writer.WriteHeader(http.StatusUnauthorized)
-					fmt.Fprint(writer, err.Error())
-					WebConfig.Logger.Error("%s no auth url:%s ip:%s ct:%s", req.Method, req.RequestURI, remoteAddr, ct)
-					return
+				if valid, err := context.controller.CheckAuth(); err != nil && !valid {
+					return WrapHTTPError(http.StatusUnauthorized, "unauthorized", err)
 				}
 			}
-			WebConfig.Logger.Info("%s auth url:%s ip:%s ct:%s", req.Method, req.RequestURI, remoteAddr, ct)
-		} else {
-			WebConfig.Logger.Info("%s url:%s ip:%s ct:%s", req.Method, req.RequestURI, remoteAddr, ct)
+			if err := routeAuthorize(context, option); err != nil {
+				return err
+			}
+			return next(context)
 		}
+	}
+}
 
-		m := vc.MethodByName(funcMethod)
-		paramLen := m.Type().NumIn()
-		var parameters []reflect.Value
-		var err error
-		if isUrlParam {
-			tactions := strings.Split(req.RequestURI, "/")
-			paramIn := make([]string, 0)
-			for i, ta := range tactions {
-				if ta == "" {
-					continue
-				}
-				if i > 2 {
-					paramIn = append(paramIn, ta)
-				}
-			}
-			parameters, err = genParameters(m, params, paramLen, execController, paramIn)
-		} else {
-			parameters, err = genParameters(m, params, paramLen, execController, []string{})
+// positionalParams names the segments of urlParams by the position of params
+func positionalParams(params []string, urlParams string) map[string]string {
+	pathParams := map[string]string{urlParamsKey: urlParams}
+	i := 0
+	for _, segment := range strings.Split(urlParams, "/") {
+		if segment == "" {
+			continue
 		}
-		if err != nil {
-			writer.WriteHeader(http.StatusBadRequest)
-			fmt.Fprint(writer, "参数错误")
-			WebConfig.Logger.Error("%s url:%s param err:%s", req.Method, req.RequestURI, err)
-			return
+		if i < len(params) {
+			pathParams[params[i]] = segment
 		}
-		m.Call(parameters)
-	})
+		i++
+	}
+	return pathParams
 }
 
-func genParameters(m reflect.Value, params []string, paramLen int, execController ControllerInterface, paramIn []string) ([]reflect.Value, error) {
+// contextType is the type of the context.Context arguments filled with WebContext.Context
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// genParameters binds the request values named params to the arguments of m.
+// Structs and maps are parsed from the body, the other kinds from the path, query, form or json body values,
+// see bindArg.
+func genParameters(ctx *WebContext, m reflect.Value, params []string, paramLen int) ([]reflect.Value, error) {
 	parameters := make([]reflect.Value, 0, paramLen)
+	p := 0
 	for i := 0; i < paramLen; i++ {
 		arg := m.Type().In(i)
-		param := params[i]
-		var paramVal interface{} = nil
-		var err error
-		if len(paramIn) > i {
-			paramVal = paramIn[i]
+		if arg == contextType {
+			// the context arguments are not in params
+			parameters = append(parameters, reflect.ValueOf(ctx.Context()))
+			continue
 		}
-		//fmt.Printf("argument %d is %s[%s] type \n", i, arg.Name(), param)
-		switch arg.Kind() {
-		case reflect.Int:
-			if paramVal == nil {
-				paramVal, err = execController.Query(param)
-				if err != nil {
-					return parameters, fmt.Errorf("query err:%s", err)
-				}
-			}
-			switch paramVal.(type) {
-			case string:
-				if paramVal == "" {
-					parameters = append(parameters, reflect.ValueOf(0))
-				} else {
-					v, err := strconv.Atoi(paramVal.(string)) //strconv.ParseInt(paramVal.(string), 10, 32)
-					if err != nil {
-						return parameters, fmt.Errorf("argument %d %s convert int failed, %v \n", i, paramVal, err)
-					}
-					parameters = append(parameters, reflect.ValueOf(v))
-				}
-			case int:
-				parameters = append(parameters, reflect.ValueOf(paramVal))
-			case int64:
-				parameters = append(parameters, reflect.ValueOf(int(paramVal.(int64))))
-			case float32:
-				parameters = append(parameters, reflect.ValueOf(int(paramVal.(float32))))
-			case float64:
-				parameters = append(parameters, reflect.ValueOf(int(paramVal.(float64))))
-			case nil:
-				parameters = append(parameters, reflect.ValueOf(0))
-			default:
-				return parameters, fmt.Errorf("key:%s val:%v not supoort", param, paramVal)
-			}
-
-		case reflect.String:
-			if paramVal == nil {
-				paramVal, err = execController.Query(param)
-				if err != nil {
-					return parameters, fmt.Errorf("query err:%s", err)
-				}
-			}
-			switch paramVal.(type) {
-			case string:
-				parameters = append(parameters, reflect.ValueOf(paramVal))
-			case int:
-				v := strconv.Itoa(paramVal.(int))
-				parameters = append(parameters, reflect.ValueOf(v))
-			case nil:
-				parameters = append(parameters, reflect.ValueOf(""))
-			}
-		case reflect.Struct:
+		param := ""
+		if p < len(params) {
+			param = params[p]
+		}
+		p++
+		if isBodyType(arg) {
 			argObj := reflect.New(arg)
-			err := execController.ParseValid(argObj.Interface())
-			if err != nil {
-				return parameters, fmt.Errorf("parse err:%s", err)
+			if arg.Kind() == reflect.Ptr {
+				argObj = reflect.New(arg.Elem())
 			}
-			parameters = append(parameters, argObj.Elem())
-		case reflect.Ptr:
-			argObj := reflect.New(arg.Elem())
-			err := execController.ParseValid(argObj.Interface())
+			err := parseBody(ctx, argObj.Interface())
 			if err != nil {
-				return parameters, fmt.Errorf("parse err:%s", err)
+				return parameters, fmt.Errorf("parse err:%w", err)
+			}
+			if arg.Kind() == reflect.Ptr {
+				parameters = append(parameters, argObj)
+			} else {
+				parameters = append(parameters, argObj.Elem())
 			}
-			parameters = append(parameters, argObj)
-		default:
-			WebConfig.Logger.Error("unsupport type %s[%s] \n", arg.Kind(), param)
-			return parameters, fmt.Errorf("unsupport type %s[%s] \n", arg.Kind(), param)
+			continue
+		}
+		values, raw, err := requestValues(ctx, param, arg.Kind() == reflect.Ptr)
+		if err != nil {
+			return parameters, err
+		}
+		v, err := bindArg(arg, values, raw, ctx.Config().TimeLayouts)
+		if err != nil {
+			return parameters, fmt.Errorf("argument %d %s convert %s failed: %w", i, param, arg, err)
 		}
+		parameters = append(parameters, v)
 	}
 	return parameters, nil
 }
 
+// RouteFiles serves the files of dir at route on the default app
 func RouteFiles(route, dir string) {
-	handler := http.FileServer(http.Dir(dir))
-	http.Handle(route, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		context := WebContext{r, w, []byte{}}
-		start := time.Now()
-		WebConfig.Logger.Info("Started %s %s ip:%s", r.Method, r.URL.Path, context.GetRemoteAddr())
-		handler.ServeHTTP(w, r)
-		WebConfig.Logger.Info("Comleted %s in %v", r.URL.Path, time.Since(start))
-	}))
+	defaultApp.RouteFiles(route, dir)
+}
+
+// RouteFiles serves the files of dir at route
+func (app *App) RouteFiles(route, dir string) {
+	app.Handle(route, http.FileServer(http.Dir(dir)))
 }
+
+// Map registers all methods of obj on the default app, see App.Map
 func Map(obj ControllerInterface) error {
+	return defaultApp.Map(obj)
+}
+
+// Map registers all methods of obj at /{struct name}/{method name}
+func (app *App) Map(obj ControllerInterface) error {
 	methodNames := make([]string, 0)
 	t := reflect.TypeOf(obj)
 	v := reflect.ValueOf(obj)
@@ -227,21 +260,27 @@ func Map(obj ControllerInterface) error {
 
 	for _, methodName := range methodNames {
 		routeName := fmt.Sprintf("/%s/%s", structName, methodName)
-		http.HandleFunc(routeName, func(writer http.ResponseWriter, request *http.Request) {
+		app.handle(anyMethod, routeName, func(context *WebContext) error {
 			vc := reflect.New(t.Elem())
 			execController, ok := vc.Interface().(ControllerInterface)
 			if !ok {
 				panic("controller is not ControllerInterface")
 			}
-			context := WebContext{request, writer, []byte{}}
-			execController.Init(&context)
+			execController.Init(context)
 			vc.MethodByName(methodName).Call([]reflect.Value{})
+			return nil
 		})
 	}
 	return nil
 }
 
+// JwtMap registers all methods of obj on the default app, see App.JwtMap
 func JwtMap(obj ControllerInterface) error {
+	return defaultApp.JwtMap(obj)
+}
+
+// JwtMap registers all methods of obj at /{struct name}/{method name}, requests must carry a valid jwt token
+func (app *App) JwtMap(obj ControllerInterface) error {
 	methodNames := make([]string, 0)
 	t := reflect.TypeOf(obj)
 	v := reflect.ValueOf(obj)
@@ -256,30 +295,22 @@ func JwtMap(obj ControllerInterface) error {
 
 	for _, methodName := range methodNames {
 		routeName := fmt.Sprintf("/%s/%s", structName, methodName)
-		http.HandleFunc(routeName, func(writer http.ResponseWriter, req *http.Request) {
-			token, err := request.ParseFromRequest(req, request.AuthorizationHeaderExtractor,
-				func(token *jwt.Token) (interface{}, error) {
-					return []byte(WebConfig.SecretKey), nil
-				})
-			if err == nil {
-				if token.Valid {
-					vc := reflect.New(t.Elem())
-					execController, ok := vc.Interface().(ControllerInterface)
-					if !ok {
-						panic("controller is not ControllerInterface")
-					}
-					context := WebContext{req, writer, []byte{}}
-					execController.Init(&context)
-					vc.MethodByName(methodName).Call([]reflect.Value{})
-				} else {
-					writer.WriteHeader(http.StatusUnauthorized)
-					fmt.Fprint(writer, "Token is not valid")
-				}
-			} else {
-				writer.WriteHeader(http.StatusUnauthorized)
-				fmt.Fprint(writer, "Unauthorized access to this resource")
+		app.handle(anyMethod, routeName, func(context *WebContext) error {
+			tokenString, err := request.AuthorizationHeaderExtractor.ExtractToken(context.Request)
+			if err != nil {
+				return WrapHTTPError(http.StatusUnauthorized, "unauthorized", fmt.Errorf("Unauthorized access to this resource: %w", err))
 			}
-
+			if _, err := app.JwtClaims(tokenString); err != nil {
+				return WrapHTTPError(http.StatusUnauthorized, "unauthorized", err)
+			}
+			vc := reflect.New(t.Elem())
+			execController, ok := vc.Interface().(ControllerInterface)
+			if !ok {
+				panic("controller is not ControllerInterface")
+			}
+			execController.Init(context)
+			vc.MethodByName(methodName).Call([]reflect.Value{})
+			return nil
 		})
 	}
 
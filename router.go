@@ -0,0 +1,229 @@
+package hiweb
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// anyMethod registers a route for every http method
+const anyMethod = "*"
+
+// paramMatchers are the typed placeholders of a route path, e.g. /orders/{orderId:int}.
+// Any other type is used as a regular expression, e.g. {name:[a-z]+}
+var paramMatchers = map[string]func(string) bool{
+	"string": func(s string) bool { return s != "" },
+	"int": func(s string) bool {
+		_, err := strconv.ParseInt(s, 10, 64)
+		return err == nil
+	},
+	"uint": func(s string) bool {
+		_, err := strconv.ParseUint(s, 10, 64)
+		return err == nil
+	},
+	"float": func(s string) bool {
+		_, err := strconv.ParseFloat(s, 64)
+		return err == nil
+	},
+	"bool": func(s string) bool {
+		_, err := strconv.ParseBool(s)
+		return err == nil
+	},
+	"uuid": regexp.MustCompile(`^[0-9a-fA-F]{8}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{12}$`).MatchString,
+}
+
+type route struct {
+	method  string
+	pattern string
+	handler Next
+}
+
+// node is a path segment of the router trie.
+// Static children are preferred over placeholders, typed placeholders over untyped ones
+// and the catch-all placeholder {*name} matches the rest of the path.
+type node struct {
+	key      string
+	name     string
+	typed    bool
+	match    func(string) bool
+	static   map[string]*node
+	params   []*node
+	catchAll *node
+	routes   map[string]*route
+}
+
+type router struct {
+	root *node
+}
+
+func newRouter() *router {
+	return &router{root: &node{}}
+}
+
+type pathParam struct {
+	key   string
+	value string
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "/"), "/")
+}
+
+func parsePlaceholder(segment string) (name string, typ string, ok bool) {
+	if len(segment) < 3 || segment[0] != '{' || segment[len(segment)-1] != '}' {
+		return "", "", false
+	}
+	name = segment[1 : len(segment)-1]
+	if i := strings.Index(name, ":"); i >= 0 {
+		name, typ = name[:i], name[i+1:]
+	}
+	return name, typ, name != ""
+}
+
+func (r *router) add(method, pattern string, handler Next) error {
+	if !strings.HasPrefix(pattern, "/") {
+		return fmt.Errorf("route %s must begin with '/'", pattern)
+	}
+	n := r.root
+	segments := splitPath(pattern)
+	for i, segment := range segments {
+		name, typ, ok := parsePlaceholder(segment)
+		if !ok {
+			if n.static == nil {
+				n.static = make(map[string]*node)
+			}
+			child, has := n.static[segment]
+			if !has {
+				child = &node{key: segment}
+				n.static[segment] = child
+			}
+			n = child
+			continue
+		}
+		if strings.HasPrefix(name, "*") {
+			if i != len(segments)-1 {
+				return fmt.Errorf("route %s catch-all %s must be the last segment", pattern, segment)
+			}
+			if n.catchAll == nil {
+				n.catchAll = &node{key: segment, name: name[1:]}
+			} else if n.catchAll.key != segment {
+				return fmt.Errorf("route %s catch-all %s conflicts with %s", pattern, segment, n.catchAll.key)
+			}
+			n = n.catchAll
+			continue
+		}
+		child, err := n.paramChild(segment, name, typ)
+		if err != nil {
+			return fmt.Errorf("route %s %s", pattern, err)
+		}
+		n = child
+	}
+	if n.routes == nil {
+		n.routes = make(map[string]*route)
+	}
+	if _, has := n.routes[method]; has {
+		return fmt.Errorf("multiple registrations for %s %s", method, pattern)
+	}
+	n.routes[method] = &route{method: method, pattern: pattern, handler: handler}
+	return nil
+}
+
+func (n *node) paramChild(segment, name, typ string) (*node, error) {
+	for _, child := range n.params {
+		if child.key == segment {
+			return child, nil
+		}
+	}
+	child := &node{key: segment, name: name, match: paramMatchers["string"]}
+	if typ != "" {
+		child.typed = true
+		if m, has := paramMatchers[typ]; has {
+			child.match = m
+		} else {
+			re, err := regexp.Compile("^(?:" + typ + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("placeholder %s err:%s", segment, err)
+			}
+			child.match = re.MatchString
+		}
+	}
+	n.params = append(n.params, child)
+	sort.SliceStable(n.params, func(i, j int) bool {
+		return n.params[i].typed && !n.params[j].typed
+	})
+	return child, nil
+}
+
+// lookup returns the first node matching the segments which is accepted, the static segments are tried first,
+// then the placeholders and the catch-all
+func (n *node) lookup(segments []string, params []pathParam, accept func(*node) bool) (*node, []pathParam) {
+	if len(segments) == 0 {
+		if accept(n) {
+			return n, params
+		}
+		return nil, params
+	}
+	segment := segments[0]
+	if child, has := n.static[segment]; has {
+		if found, ps := child.lookup(segments[1:], params, accept); found != nil {
+			return found, ps
+		}
+	}
+	for _, child := range n.params {
+		if !child.match(segment) {
+			continue
+		}
+		if found, ps := child.lookup(segments[1:], append(params, pathParam{child.name, segment}), accept); found != nil {
+			return found, ps
+		}
+	}
+	if n.catchAll != nil && accept(n.catchAll) {
+		return n.catchAll, append(params, pathParam{n.catchAll.name, strings.Join(segments, "/")})
+	}
+	return nil, params
+}
+
+// methodRoute returns the route of method in the node, HEAD uses GET and every method uses anyMethod
+func (n *node) methodRoute(method string) *route {
+	if rt, has := n.routes[method]; has {
+		return rt
+	}
+	if method == http.MethodHead {
+		if rt, has := n.routes[http.MethodGet]; has {
+			return rt
+		}
+	}
+	return n.routes[anyMethod]
+}
+
+// find returns the route of method and path with its path params,
+// when only the method does not match the allowed methods are returned.
+func (r *router) find(method, path string) (*route, map[string]string, []string) {
+	segments := splitPath(path)
+	// a path matching a node without the method goes on to the placeholders and the catch-all
+	n, ps := r.root.lookup(segments, make([]pathParam, 0, 4), func(n *node) bool { return n.methodRoute(method) != nil })
+	if n != nil {
+		params := make(map[string]string, len(ps))
+		for _, p := range ps {
+			params[p.key] = p.value
+		}
+		return n.methodRoute(method), params, nil
+	}
+	n, ps = r.root.lookup(segments, make([]pathParam, 0, 4), func(n *node) bool { return len(n.routes) > 0 })
+	if n == nil {
+		return nil, nil, nil
+	}
+	params := make(map[string]string, len(ps))
+	for _, p := range ps {
+		params[p.key] = p.value
+	}
+	allowed := make([]string, 0, len(n.routes))
+	for m := range n.routes {
+		allowed = append(allowed, m)
+	}
+	sort.Strings(allowed)
+	return nil, params, allowed
+}
@@ -0,0 +1,110 @@
+package hiweb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouterFind(t *testing.T) {
+	r := newRouter()
+	routes := []struct {
+		method  string
+		pattern string
+	}{
+		{"GET", "/orders/{orderId}/items/{itemId:int}"},
+		{"GET", "/orders/{orderId}/items/{name}"},
+		{"GET", "/orders/latest/items/{itemId:int}"},
+		{"POST", "/orders/{orderId}"},
+		{"POST", "/orders/latest"},
+		{"GET", "/orders/{orderId}"},
+		{anyMethod, "/swag/{*path}"},
+		{anyMethod, "/{*path}"},
+	}
+	for _, rt := range routes {
+		if err := r.add(rt.method, rt.pattern, func(ctx *WebContext) error { return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.add("GET", "/orders/{orderId}/items/{itemId:int}", func(ctx *WebContext) error { return nil }); err == nil {
+		t.Error("duplicate route registered")
+	}
+
+	tests := []struct {
+		method  string
+		path    string
+		pattern string
+		params  map[string]string
+	}{
+		{"GET", "/orders/a1/items/12", "/orders/{orderId}/items/{itemId:int}", map[string]string{"orderId": "a1", "itemId": "12"}},
+		{"GET", "/orders/a1/items/book", "/orders/{orderId}/items/{name}", map[string]string{"orderId": "a1", "name": "book"}},
+		{"GET", "/orders/latest/items/3", "/orders/latest/items/{itemId:int}", map[string]string{"itemId": "3"}},
+		{"GET", "/orders/latest/items/x", "/orders/{orderId}/items/{name}", map[string]string{"orderId": "latest", "name": "x"}},
+		{"POST", "/orders/7", "/orders/{orderId}", map[string]string{"orderId": "7"}},
+		{"POST", "/orders/latest", "/orders/latest", nil},
+		{"GET", "/orders/latest", "/orders/{orderId}", map[string]string{"orderId": "latest"}},
+		{"GET", "/swag/index.html", "/swag/{*path}", map[string]string{"path": "index.html"}},
+		{"GET", "/dist/js/app.js", "/{*path}", map[string]string{"path": "dist/js/app.js"}},
+		{"GET", "/", "/{*path}", map[string]string{"path": ""}},
+	}
+	for _, tt := range tests {
+		rt, params, _ := r.find(tt.method, tt.path)
+		if rt == nil {
+			t.Errorf("%s %s not found", tt.method, tt.path)
+			continue
+		}
+		if rt.pattern != tt.pattern {
+			t.Errorf("%s %s matched %s want %s", tt.method, tt.path, rt.pattern, tt.pattern)
+		}
+		for k, v := range tt.params {
+			if params[k] != v {
+				t.Errorf("%s %s param %s=%q want %q", tt.method, tt.path, k, params[k], v)
+			}
+		}
+	}
+
+	r = newRouter()
+	for _, method := range []string{"POST", "GET"} {
+		pattern := map[string]string{"POST": "/orders/latest", "GET": "/orders/{orderId}"}[method]
+		if err := r.add(method, pattern, func(ctx *WebContext) error { return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if rt, _, _ := r.find("GET", "/orders/latest"); rt == nil || rt.pattern != "/orders/{orderId}" {
+		t.Errorf("GET /orders/latest got route %v", rt)
+	}
+	if rt, _, allowed := r.find("PUT", "/orders/latest"); rt != nil || len(allowed) != 1 || allowed[0] != "POST" {
+		t.Errorf("PUT /orders/latest got route %v allowed %v", rt, allowed)
+	}
+}
+
+type routerTestController struct {
+	Controller
+}
+
+func (c *routerTestController) Item(orderId string, itemId int) {
+	_ = c.ServeJSON(http.StatusOK, map[string]interface{}{"orderId": orderId, "itemId": itemId})
+}
+
+func (c *routerTestController) Get(key string) {
+	_ = c.ServeBody(http.StatusOK, []byte(key))
+}
+
+func TestRoutePathParams(t *testing.T) {
+	app := NewApp()
+	app.Route("/orders/{orderId}/items/{itemId:int}", &routerTestController{}, "orderId;itemId", "get:Item", RouteOption{})
+	app.Route("/legacy/Get/", &routerTestController{}, "key", "get:Get", RouteOption{})
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	body, status := appTestGet(t, server.URL+"/orders/o-1/items/42")
+	if status != http.StatusOK || body != "{\n  \"itemId\": 42,\n  \"orderId\": \"o-1\"\n}" {
+		t.Errorf("item got %d %s", status, body)
+	}
+	if _, status = appTestGet(t, server.URL+"/orders/o-1/items/x"); status != http.StatusNotFound {
+		t.Errorf("typed placeholder matched a string, status %d", status)
+	}
+	if body, status = appTestGet(t, server.URL+"/legacy/Get/abc"); status != http.StatusOK || body != "abc" {
+		t.Errorf("legacy url param got %d %s", status, body)
+	}
+}
@@ -0,0 +1,198 @@
+package hiweb
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Hook is a function run when the server of an app starts or shuts down
+type Hook func(ctx context.Context) error
+
+// OnStart registers a hook run by App.Run before the server accepts requests, an error stops Run
+func (app *App) OnStart(hook Hook) {
+	app.serverMu.Lock()
+	defer app.serverMu.Unlock()
+	app.startHooks = append(app.startHooks, hook)
+}
+
+// OnShutdown registers a hook run after the in-flight requests are drained,
+// the hooks run in the reverse order of registration.
+func (app *App) OnShutdown(hook Hook) {
+	app.serverMu.Lock()
+	defer app.serverMu.Unlock()
+	app.shutdownHooks = append(app.shutdownHooks, hook)
+}
+
+// Run serves the default app on addr, see App.Run
+func Run(addr string) error {
+	return defaultApp.Run(addr)
+}
+
+// RunTLS serves the default app on addr with TLS, see App.RunTLS
+func RunTLS(addr, certFile, keyFile string) error {
+	return defaultApp.RunTLS(addr, certFile, keyFile)
+}
+
+// Run serves the app on addr until SIGINT or SIGTERM, then shuts it down gracefully.
+// It returns nil after a graceful shutdown.
+func (app *App) Run(addr string) error {
+	return app.listenAndServe(addr, "", "")
+}
+
+// RunTLS is Run with the TLS certificate and key files
+func (app *App) RunTLS(addr, certFile, keyFile string) error {
+	return app.listenAndServe(addr, certFile, keyFile)
+}
+
+func (app *App) listenAndServe(addr, certFile, keyFile string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	// done ends the signal goroutine when the app is shut down another way
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case sig := <-signals:
+			app.Logger().Info("receive signal %s, shutdown", sig)
+			ctx, cancel := context.WithTimeout(context.Background(), app.Config.ShutdownTimeout)
+			defer cancel()
+			if err := app.Shutdown(ctx); err != nil {
+				app.Logger().Error("shutdown err:%s", err)
+			}
+		case <-done:
+		}
+	}()
+	return app.serve(ln, certFile, keyFile)
+}
+
+// serve runs the start hooks and serves ln until Shutdown
+func (app *App) serve(ln net.Listener, certFile, keyFile string) error {
+	if err := app.checkSecret(); err != nil {
+		ln.Close()
+		return err
+	}
+	app.registerHealth()
+	var handler http.Handler = app
+	if app.isDefault {
+		// the routes registered by http.Handle are served too
+		handler = http.DefaultServeMux
+	}
+	server := &http.Server{
+		Handler:           handler,
+		ReadTimeout:       app.Config.ReadTimeout,
+		ReadHeaderTimeout: app.Config.ReadHeaderTimeout,
+		WriteTimeout:      app.Config.WriteTimeout,
+		IdleTimeout:       app.Config.IdleTimeout,
+	}
+	app.serverMu.Lock()
+	if app.server != nil {
+		app.serverMu.Unlock()
+		ln.Close()
+		return errors.New("app is already running")
+	}
+	stopped := make(chan struct{})
+	app.server = server
+	app.stopped = stopped
+	startHooks := append([]Hook(nil), app.startHooks...)
+	app.serverMu.Unlock()
+
+	for _, hook := range startHooks {
+		if err := hook(context.Background()); err != nil {
+			ln.Close()
+			app.serverMu.Lock()
+			app.server, app.stopped = nil, nil
+			app.serverMu.Unlock()
+			return err
+		}
+	}
+	app.setReady(true)
+	app.Logger().Info("start web %s", ln.Addr())
+
+	var err error
+	if certFile != "" || keyFile != "" {
+		err = server.ServeTLS(ln, certFile, keyFile)
+	} else {
+		err = server.Serve(ln)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		// Serve returns at the beginning of Shutdown, wait for the requests and the hooks
+		<-stopped
+		return nil
+	}
+	return err
+}
+
+// Shutdown stops accepting requests, waits for the in-flight requests until ctx is done,
+// then runs the shutdown hooks. The readiness endpoint fails from the beginning of the shutdown.
+func (app *App) Shutdown(ctx context.Context) error {
+	app.setReady(false)
+	app.serverMu.Lock()
+	server, stopped := app.server, app.stopped
+	app.server, app.stopped = nil, nil
+	hooks := append([]Hook(nil), app.shutdownHooks...)
+	app.serverMu.Unlock()
+
+	var err error
+	if server != nil {
+		err = server.Shutdown(ctx)
+	}
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if hookErr := hooks[i](ctx); hookErr != nil && err == nil {
+			err = hookErr
+		}
+	}
+	if stopped != nil {
+		close(stopped)
+	}
+	return err
+}
+
+func (app *App) setReady(ready bool) {
+	app.serverMu.Lock()
+	defer app.serverMu.Unlock()
+	app.ready = ready
+}
+
+// Ready reports whether the app is running and not shutting down
+func (app *App) Ready() bool {
+	app.serverMu.Lock()
+	defer app.serverMu.Unlock()
+	return app.ready
+}
+
+// registerHealth registers Config.HealthPath and Config.ReadyPath unless the app has these routes
+func (app *App) registerHealth() {
+	app.healthOnce.Do(func() {
+		if path := app.Config.HealthPath; path != "" && !app.hasRoute(http.MethodGet, path) {
+			app.handle(http.MethodGet, path, func(ctx *WebContext) error {
+				return ctx.Render(http.StatusOK, map[string]string{"status": "ok"})
+			})
+		}
+		if path := app.Config.ReadyPath; path != "" && !app.hasRoute(http.MethodGet, path) {
+			app.handle(http.MethodGet, path, func(ctx *WebContext) error {
+				if !app.Ready() {
+					return NewHTTPError(http.StatusServiceUnavailable, "not_ready", "not ready")
+				}
+				return ctx.Render(http.StatusOK, map[string]string{"status": "ready"})
+			})
+		}
+		app.registerJWKS()
+		app.registerMetrics()
+	})
+}
+
+// hasRoute reports whether path is registered for method, routes with placeholders do not count
+func (app *App) hasRoute(method, path string) bool {
+	rt, _, _ := app.router.find(method, path)
+	return rt != nil && rt.pattern == path
+}
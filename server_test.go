@@ -0,0 +1,118 @@
+package hiweb
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"runtime"
+	"testing"
+	"time"
+)
+
+type serverTestController struct {
+	Controller
+}
+
+func (c *serverTestController) Slow() string {
+	time.Sleep(200 * time.Millisecond)
+	return "done"
+}
+
+func TestServerLifecycle(t *testing.T) {
+	app := NewApp(func(config *Config) { config.SecretKey = "server test secret" })
+	app.Route("/slow", &serverTestController{}, "", "get:Slow", RouteOption{})
+	var events []string
+	app.OnStart(func(ctx context.Context) error {
+		events = append(events, "start")
+		return nil
+	})
+	app.OnShutdown(func(ctx context.Context) error {
+		events = append(events, "shutdown1")
+		return nil
+	})
+	app.OnShutdown(func(ctx context.Context) error {
+		events = append(events, "shutdown2")
+		return nil
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- app.serve(ln, "", "")
+	}()
+	url := "http://" + ln.Addr().String()
+	for i := 0; !app.Ready() && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if body, status := appTestGet(t, url+"/healthz"); status != http.StatusOK || body != `{"status":"ok"}` {
+		t.Errorf("healthz got %d %s", status, body)
+	}
+	if _, status := appTestGet(t, url+"/readyz"); status != http.StatusOK {
+		t.Errorf("readyz got %d", status)
+	}
+
+	slow := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url + "/slow")
+		if err != nil {
+			slow <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		slow <- string(body)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if err := app.Shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown err:%s", err)
+	}
+	if body := <-slow; body != `"done"` {
+		t.Errorf("in-flight request got %s", body)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("serve err:%s", err)
+	}
+	if app.Ready() {
+		t.Error("app is ready after shutdown")
+	}
+	if len(events) != 3 || events[0] != "start" || events[1] != "shutdown2" || events[2] != "shutdown1" {
+		t.Errorf("hooks got %v", events)
+	}
+}
+
+func TestRunShutdownWithoutSignal(t *testing.T) {
+	app := NewApp(func(config *Config) { config.SecretKey = "server test secret" })
+	goroutines := 0
+	for i := 0; i < 4; i++ {
+		if i == 1 {
+			// the first run starts the signal handling of os/signal
+			goroutines = runtime.NumGoroutine()
+		}
+		served := make(chan error, 1)
+		go func() {
+			served <- app.Run("127.0.0.1:0")
+		}()
+		for j := 0; !app.Ready() && j < 100; j++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if err := app.Shutdown(context.Background()); err != nil {
+			t.Fatalf("shutdown err:%s", err)
+		}
+		if err := <-served; err != nil {
+			t.Fatalf("run err:%s", err)
+		}
+	}
+	// the signal goroutines of the runs are done
+	n := runtime.NumGoroutine()
+	for i := 0; n > goroutines && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		n = runtime.NumGoroutine()
+	}
+	if n > goroutines {
+		t.Errorf("goroutines grew from %d to %d", goroutines, n)
+	}
+}
@@ -0,0 +1,429 @@
+package hiweb
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrSessionNotFound is returned by SessionStore.Touch and SessionStore.TTL for missing or expired sessions
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionStore stores the values of the sessions by id, every session expires after its ttl.
+type SessionStore interface {
+	// Get returns the values of sid, false when the session is missing or expired
+	Get(sid string) (map[string]interface{}, bool, error)
+	// Set stores the values of sid which expire after ttl
+	Set(sid string, values map[string]interface{}, ttl time.Duration) error
+	Delete(sid string) error
+	// Touch resets the ttl of sid
+	Touch(sid string, ttl time.Duration) error
+	// TTL returns the remaining time of sid
+	TTL(sid string) (time.Duration, error)
+}
+
+// sessionCleaner is a SessionStore which removes the expired sessions by a janitor
+type sessionCleaner interface {
+	Cleanup() error
+}
+
+type memorySession struct {
+	values  map[string]interface{}
+	expires time.Time
+}
+
+// MemorySessionStore keeps the sessions in memory, they are lost on restart.
+type MemorySessionStore struct {
+	mu       sync.RWMutex
+	sessions map[string]memorySession
+}
+
+// NewMemorySessionStore creates an empty MemorySessionStore
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[string]memorySession)}
+}
+
+func (s *MemorySessionStore) Get(sid string) (map[string]interface{}, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	session, has := s.sessions[sid]
+	if !has || time.Now().After(session.expires) {
+		return nil, false, nil
+	}
+	return session.values, true, nil
+}
+
+func (s *MemorySessionStore) Set(sid string, values map[string]interface{}, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[sid] = memorySession{values: values, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *MemorySessionStore) Delete(sid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, sid)
+	return nil
+}
+
+func (s *MemorySessionStore) Touch(sid string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, has := s.sessions[sid]
+	if !has || time.Now().After(session.expires) {
+		return ErrSessionNotFound
+	}
+	session.expires = time.Now().Add(ttl)
+	s.sessions[sid] = session
+	return nil
+}
+
+func (s *MemorySessionStore) TTL(sid string) (time.Duration, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	session, has := s.sessions[sid]
+	if !has || time.Now().After(session.expires) {
+		return 0, ErrSessionNotFound
+	}
+	return time.Until(session.expires), nil
+}
+
+// Len returns the number of the stored sessions, including the expired ones not cleaned up yet
+func (s *MemorySessionStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.sessions)
+}
+
+// Cleanup removes the expired sessions
+func (s *MemorySessionStore) Cleanup() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for sid, session := range s.sessions {
+		if now.After(session.expires) {
+			delete(s.sessions, sid)
+		}
+	}
+	return nil
+}
+
+// FileSessionStore keeps every session in a json file of Dir, so the sessions survive restarts.
+type FileSessionStore struct {
+	Dir string
+	mu  sync.Mutex
+}
+
+type fileSession struct {
+	Expires int64                  `json:"expires"`
+	Values  map[string]interface{} `json:"values"`
+}
+
+var sessionIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// NewFileSessionStore creates a FileSessionStore, dir is created if it does not exist
+func NewFileSessionStore(dir string) (*FileSessionStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileSessionStore{Dir: dir}, nil
+}
+
+func (s *FileSessionStore) file(sid string) (string, error) {
+	if !sessionIDPattern.MatchString(sid) {
+		return "", fmt.Errorf("invalid session id:%q", sid)
+	}
+	return filepath.Join(s.Dir, sid+".json"), nil
+}
+
+func (s *FileSessionStore) read(sid string) (*fileSession, error) {
+	file, err := s.file(sid)
+	if err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	session := &fileSession{}
+	if err := json.Unmarshal(content, session); err != nil {
+		return nil, err
+	}
+	if time.Now().UnixNano() > session.Expires {
+		return nil, nil
+	}
+	return session, nil
+}
+
+func (s *FileSessionStore) write(sid string, session *fileSession) error {
+	file, err := s.file(sid)
+	if err != nil {
+		return err
+	}
+	content, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	// write a temporary file and rename it, so readers never see a partial session
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+func (s *FileSessionStore) Get(sid string) (map[string]interface{}, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, err := s.read(sid)
+	if err != nil || session == nil {
+		return nil, false, err
+	}
+	return session.Values, true, nil
+}
+
+func (s *FileSessionStore) Set(sid string, values map[string]interface{}, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write(sid, &fileSession{Expires: time.Now().Add(ttl).UnixNano(), Values: values})
+}
+
+func (s *FileSessionStore) Delete(sid string) error {
+	file, err := s.file(sid)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *FileSessionStore) Touch(sid string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, err := s.read(sid)
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		return ErrSessionNotFound
+	}
+	session.Expires = time.Now().Add(ttl).UnixNano()
+	return s.write(sid, session)
+}
+
+func (s *FileSessionStore) TTL(sid string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, err := s.read(sid)
+	if err != nil {
+		return 0, err
+	}
+	if session == nil {
+		return 0, ErrSessionNotFound
+	}
+	return time.Until(time.Unix(0, session.Expires)), nil
+}
+
+// Len returns the number of the session files, including the expired ones not cleaned up yet
+func (s *FileSessionStore) Len() int {
+	files, _ := filepath.Glob(filepath.Join(s.Dir, "*.json"))
+	return len(files)
+}
+
+// Cleanup removes the files of the expired sessions
+func (s *FileSessionStore) Cleanup() error {
+	files, err := filepath.Glob(filepath.Join(s.Dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, file := range files {
+		sid := strings.TrimSuffix(filepath.Base(file), ".json")
+		session, err := s.read(sid)
+		if err == nil && session == nil {
+			if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SessionStore returns Config.SessionStore, a MemorySessionStore is created when it is nil
+func (app *App) SessionStore() SessionStore {
+	app.sessionMu.Lock()
+	defer app.sessionMu.Unlock()
+	if app.Config.SessionStore == nil {
+		app.Config.SessionStore = NewMemorySessionStore()
+	}
+	return app.Config.SessionStore
+}
+
+// sessionCreatedKey is the value keeping the creation time of a session in the store, in unix seconds
+const sessionCreatedKey = "_hiweb_created"
+
+// newSessionID returns 32 hex digits from crypto/rand, the ids must not be guessable
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// NewSession stores values in a new session and returns its id
+func (app *App) NewSession(values map[string]interface{}) (string, error) {
+	sid, err := newSessionID()
+	if err != nil {
+		return "", err
+	}
+	if err := app.saveSession(sid, values, time.Now()); err != nil {
+		return "", err
+	}
+	return sid, nil
+}
+
+// LoadSession returns the values of sid and refreshes its idle timeout.
+// Sessions idle longer than Config.SessionTTL or older than Config.SessionAbsoluteTimeout are not returned.
+func (app *App) LoadSession(sid string) (map[string]interface{}, bool, error) {
+	values, _, has, err := app.loadSession(sid)
+	return values, has, err
+}
+
+func (app *App) loadSession(sid string) (map[string]interface{}, time.Time, bool, error) {
+	if sid == "" {
+		return nil, time.Time{}, false, nil
+	}
+	store := app.SessionStore()
+	stored, has, err := store.Get(sid)
+	if err != nil || !has {
+		return nil, time.Time{}, false, err
+	}
+	created, hasCreated := sessionCreated(stored)
+	ttl := app.sessionTTL(created)
+	if ttl <= 0 {
+		return nil, time.Time{}, false, store.Delete(sid)
+	}
+	if !hasCreated {
+		// a session stored before the creation time was kept, its absolute timeout starts now.
+		// the stored map may be shared by the store, it is copied before the creation time is added
+		persisted := make(map[string]interface{}, len(stored)+1)
+		for k, v := range stored {
+			persisted[k] = v
+		}
+		persisted[sessionCreatedKey] = created.Unix()
+		if err := store.Set(sid, persisted, ttl); err != nil {
+			return nil, time.Time{}, false, err
+		}
+	} else if err := store.Touch(sid, ttl); err != nil {
+		if err == ErrSessionNotFound {
+			return nil, time.Time{}, false, nil
+		}
+		return nil, time.Time{}, false, err
+	}
+	values := make(map[string]interface{}, len(stored))
+	for k, v := range stored {
+		if k != sessionCreatedKey {
+			values[k] = v
+		}
+	}
+	return values, created, true, nil
+}
+
+// UpdateSession replaces the values of sid, the creation time of an existing session is kept
+func (app *App) UpdateSession(sid string, values map[string]interface{}) error {
+	if sid == "" {
+		return fmt.Errorf("sid is blank")
+	}
+	created := time.Now()
+	if stored, has, err := app.SessionStore().Get(sid); err != nil {
+		return err
+	} else if has {
+		created, _ = sessionCreated(stored)
+	}
+	return app.saveSession(sid, values, created)
+}
+
+func (app *App) saveSession(sid string, values map[string]interface{}, created time.Time) error {
+	ttl := app.sessionTTL(created)
+	if ttl <= 0 {
+		return app.SessionStore().Delete(sid)
+	}
+	stored := make(map[string]interface{}, len(values)+1)
+	for k, v := range values {
+		stored[k] = v
+	}
+	stored[sessionCreatedKey] = created.Unix()
+	return app.SessionStore().Set(sid, stored, ttl)
+}
+
+// sessionTTL is the idle timeout, shortened to the rest of the absolute timeout
+func (app *App) sessionTTL(created time.Time) time.Duration {
+	ttl := app.Config.SessionTTL
+	if absolute := app.Config.SessionAbsoluteTimeout; absolute > 0 {
+		if rest := time.Until(created.Add(absolute)); rest < ttl {
+			ttl = rest
+		}
+	}
+	return ttl
+}
+
+// sessionCreated returns the creation time of the stored values, numbers are float64 after a json round trip.
+// has is false for the sessions stored without it, the time is then now.
+func sessionCreated(stored map[string]interface{}) (created time.Time, has bool) {
+	switch v := stored[sessionCreatedKey].(type) {
+	case int64:
+		return time.Unix(v, 0), true
+	case float64:
+		return time.Unix(int64(v), 0), true
+	}
+	return time.Now(), false
+}
+
+// startSessionJanitor removes the expired sessions every interval until the app shuts down
+func (app *App) startSessionJanitor(interval time.Duration) {
+	cleaner, ok := app.SessionStore().(sessionCleaner)
+	if !ok {
+		// the store expires the sessions itself
+		return
+	}
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	app.OnShutdown(func(ctx context.Context) error {
+		stopOnce.Do(func() { close(stop) })
+		return nil
+	})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := cleaner.Cleanup(); err != nil {
+					app.Logger().Error("session cleanup err:%s", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
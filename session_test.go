@@ -0,0 +1,354 @@
+package hiweb
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sessionTestRESPServer is a stand-in for a Redis server with the commands used by RESPSessionStore
+type sessionTestRESPServer struct {
+	ln       net.Listener
+	password string
+	mu       sync.Mutex
+	values   map[string]string
+	expires  map[string]time.Time
+}
+
+func newSessionTestRESPServer(t *testing.T, password string) *sessionTestRESPServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &sessionTestRESPServer{ln: ln, password: password, values: map[string]string{}, expires: map[string]time.Time{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *sessionTestRESPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := s.password == ""
+	for {
+		reply, err := readRESP(r)
+		if err != nil {
+			return
+		}
+		items, _ := reply.([]interface{})
+		args := make([]string, len(items))
+		for i, item := range items {
+			args[i], _ = item.(string)
+		}
+		if len(args) == 0 {
+			return
+		}
+		cmd := strings.ToUpper(args[0])
+		if cmd == "AUTH" {
+			if args[1] != s.password {
+				fmt.Fprint(conn, "-WRONGPASS invalid password\r\n")
+				continue
+			}
+			authed = true
+			fmt.Fprint(conn, "+OK\r\n")
+			continue
+		}
+		if !authed {
+			fmt.Fprint(conn, "-NOAUTH Authentication required.\r\n")
+			continue
+		}
+		fmt.Fprint(conn, s.exec(cmd, args[1:]))
+	}
+}
+
+func (s *sessionTestRESPServer) exec(cmd string, args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(args) > 0 {
+		if expires, has := s.expires[args[0]]; has && time.Now().After(expires) {
+			delete(s.values, args[0])
+			delete(s.expires, args[0])
+		}
+	}
+	switch cmd {
+	case "SELECT":
+		return "+OK\r\n"
+	case "SET":
+		s.values[args[0]] = args[1]
+		delete(s.expires, args[0])
+		if len(args) == 4 && strings.ToUpper(args[2]) == "PX" {
+			ms, _ := strconv.Atoi(args[3])
+			s.expires[args[0]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		}
+		return "+OK\r\n"
+	case "GET":
+		v, has := s.values[args[0]]
+		if !has {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+	case "DEL":
+		_, has := s.values[args[0]]
+		delete(s.values, args[0])
+		delete(s.expires, args[0])
+		if has {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	case "PEXPIRE":
+		if _, has := s.values[args[0]]; !has {
+			return ":0\r\n"
+		}
+		ms, _ := strconv.Atoi(args[1])
+		s.expires[args[0]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		return ":1\r\n"
+	case "PTTL":
+		if _, has := s.values[args[0]]; !has {
+			return ":-2\r\n"
+		}
+		expires, has := s.expires[args[0]]
+		if !has {
+			return ":-1\r\n"
+		}
+		return fmt.Sprintf(":%d\r\n", time.Until(expires).Milliseconds())
+	}
+	return "-ERR unknown command '" + cmd + "'\r\n"
+}
+
+func sessionStoreTest(t *testing.T, name string, store SessionStore) {
+	if err := store.Set("s1", map[string]interface{}{"user": "u1"}, time.Minute); err != nil {
+		t.Fatalf("%s set err:%s", name, err)
+	}
+	values, has, err := store.Get("s1")
+	if err != nil || !has || values["user"] != "u1" {
+		t.Errorf("%s get got %v %v %v", name, values, has, err)
+	}
+	if ttl, err := store.TTL("s1"); err != nil || ttl <= 50*time.Second || ttl > time.Minute {
+		t.Errorf("%s ttl got %s %v", name, ttl, err)
+	}
+	if err := store.Touch("s1", time.Hour); err != nil {
+		t.Errorf("%s touch err:%s", name, err)
+	}
+	if ttl, _ := store.TTL("s1"); ttl <= time.Minute {
+		t.Errorf("%s ttl after touch got %s", name, ttl)
+	}
+	if err := store.Delete("s1"); err != nil {
+		t.Errorf("%s delete err:%s", name, err)
+	}
+	if _, has, _ := store.Get("s1"); has {
+		t.Errorf("%s get deleted session", name)
+	}
+	if err := store.Touch("s1", time.Hour); err != ErrSessionNotFound {
+		t.Errorf("%s touch deleted session got %v", name, err)
+	}
+	if _, err := store.TTL("s1"); err != ErrSessionNotFound {
+		t.Errorf("%s ttl deleted session got %v", name, err)
+	}
+
+	if err := store.Set("s2", map[string]interface{}{"n": 1}, 20*time.Millisecond); err != nil {
+		t.Fatalf("%s set err:%s", name, err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, has, _ := store.Get("s2"); has {
+		t.Errorf("%s get expired session", name)
+	}
+	if cleaner, ok := store.(sessionCleaner); ok {
+		if err := cleaner.Cleanup(); err != nil {
+			t.Errorf("%s cleanup err:%s", name, err)
+		}
+		if n := store.(interface{ Len() int }).Len(); n != 0 {
+			t.Errorf("%s len after cleanup got %d", name, n)
+		}
+	}
+}
+
+func TestSessionStores(t *testing.T) {
+	sessionStoreTest(t, "memory", NewMemorySessionStore())
+
+	dir, err := ioutil.TempDir("", "hiweb-session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fileStore, err := NewFileSessionStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionStoreTest(t, "file", fileStore)
+	if _, _, err := fileStore.Get("../escape"); err == nil {
+		t.Error("file store accepts a path as session id")
+	}
+
+	server := newSessionTestRESPServer(t, "secret")
+	defer server.ln.Close()
+	respStore := NewRESPSessionStore(server.ln.Addr().String())
+	respStore.Password = "secret"
+	respStore.DB = 1
+	defer respStore.Close()
+	sessionStoreTest(t, "resp", respStore)
+
+	wrong := NewRESPSessionStore(server.ln.Addr().String())
+	if _, _, err := wrong.Get("s1"); err == nil || !strings.Contains(err.Error(), "NOAUTH") {
+		t.Errorf("resp store without password got %v", err)
+	}
+}
+
+func TestSessionFunctions(t *testing.T) {
+	old := defaultApp.Config.SessionStore
+	defer func() { defaultApp.Config.SessionStore = old }()
+	defaultApp.Config.SessionStore = NewMemorySessionStore()
+
+	sid := SessionToken(map[string]interface{}{"user": "u1"})
+	if info, has := SessionGetVal(sid); !has || info["user"] != "u1" {
+		t.Errorf("session get got %v %v", info, has)
+	}
+	if err := SessionUpdateVal(sid, map[string]interface{}{"user": "u2"}); err != nil {
+		t.Error(err)
+	}
+	if info, _ := SessionGetVal(sid); info["user"] != "u2" {
+		t.Errorf("session update got %v", info)
+	}
+	SessionDelKey(sid)
+	if _, has := SessionGetVal(sid); has {
+		t.Error("session is not deleted")
+	}
+}
+
+func TestSessionTimeouts(t *testing.T) {
+	app := NewApp(func(config *Config) {
+		config.SessionTTL = 60 * time.Millisecond
+		config.SessionAbsoluteTimeout = 2 * time.Second
+	})
+	sid, err := app.NewSession(map[string]interface{}{"user": "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		time.Sleep(40 * time.Millisecond)
+		if values, has, _ := app.LoadSession(sid); !has || values["user"] != "u1" || values[sessionCreatedKey] != nil {
+			t.Fatalf("read %d within the idle timeout got %v %v", i, values, has)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, has, _ := app.LoadSession(sid); has {
+		t.Error("idle session is returned")
+	}
+
+	app.Config.SessionTTL = time.Hour
+	app.Config.SessionAbsoluteTimeout = time.Hour
+	sid, _ = app.NewSession(map[string]interface{}{})
+	if err := app.saveSession(sid, map[string]interface{}{}, time.Now().Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, has, _ := app.LoadSession(sid); has {
+		t.Error("session older than the absolute timeout is returned")
+	}
+
+	// a session stored without the creation time gets it on the first load and keeps it
+	legacy := map[string]interface{}{"user": "u1"}
+	app.SessionStore().Set("legacy", legacy, time.Hour)
+	if _, has, _ := app.LoadSession("legacy"); !has {
+		t.Fatal("legacy session is not returned")
+	}
+	if _, has := legacy[sessionCreatedKey]; has {
+		t.Error("the map held by the store is modified")
+	}
+	stored, _, _ := app.SessionStore().Get("legacy")
+	created, hasCreated := sessionCreated(stored)
+	if !hasCreated || stored["user"] != "u1" {
+		t.Fatalf("legacy session is stored as %v", stored)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	app.LoadSession("legacy")
+	stored, _, _ = app.SessionStore().Get("legacy")
+	if again, _ := sessionCreated(stored); !again.Equal(created) {
+		t.Errorf("legacy session creation time moved from %s to %s", created, again)
+	}
+}
+
+type sessionTestController struct {
+	Controller
+}
+
+func (c *sessionTestController) Login(user string) (string, error) {
+	session, err := c.Session()
+	if err != nil {
+		return "", err
+	}
+	session.Set("user", user)
+	if err := session.Rotate(); err != nil {
+		return "", err
+	}
+	return session.Token(), nil
+}
+
+func (c *sessionTestController) Me() (interface{}, error) {
+	session, err := c.Session()
+	if err != nil {
+		return nil, err
+	}
+	return session.Get("user"), nil
+}
+
+func TestControllerSession(t *testing.T) {
+	app := NewApp()
+	app.Route("/login", &sessionTestController{}, "user", "post:Login", RouteOption{})
+	app.Route("/me", &sessionTestController{}, "", "get:Me", RouteOption{})
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w
+	}
+
+	// a session planted before the login
+	planted, _ := app.NewSession(map[string]interface{}{})
+	req := httptest.NewRequest(http.MethodPost, "/login?user=u1", nil)
+	req.AddCookie(&http.Cookie{Name: "hiweb_session", Value: signSessionID(app.Config.SecretKey, planted)})
+	w := serve(req)
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly || cookies[0].SameSite != http.SameSiteLaxMode {
+		t.Fatalf("login cookies got %v", cookies)
+	}
+	if sid, _ := verifySessionToken(app.Config.SecretKey, cookies[0].Value); sid == "" || sid == planted {
+		t.Errorf("session id is not rotated: %s", cookies[0].Value)
+	}
+	if _, has, _ := app.LoadSession(planted); has {
+		t.Error("planted session is not deleted")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.AddCookie(cookies[0])
+	if w := serve(req); w.Body.String() != `"u1"` {
+		t.Errorf("me by cookie got %s", w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("Authorization", "Session "+cookies[0].Value)
+	if w := serve(req); w.Body.String() != `"u1"` {
+		t.Errorf("me by header got %s", w.Body.String())
+	}
+
+	forged := cookies[0].Value[:strings.LastIndex(cookies[0].Value, ".")] + ".forged"
+	req = httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("Authorization", "Session "+forged)
+	if w := serve(req); w.Code != http.StatusNoContent {
+		t.Errorf("me by forged token got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,169 @@
+package hiweb
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SessionCookieConfig is the cookie carrying the session id, the cookie is always HttpOnly
+type SessionCookieConfig struct {
+	Name     string
+	Path     string
+	Domain   string
+	Secure   bool
+	SameSite http.SameSite
+}
+
+// DefaultSessionCookieConfig is the cookie hiweb_session of path / with SameSite=Lax
+func DefaultSessionCookieConfig() SessionCookieConfig {
+	return SessionCookieConfig{Name: "hiweb_session", Path: "/", SameSite: http.SameSiteLaxMode}
+}
+
+// sessionAuthScheme is the scheme of the Authorization header carrying a session token
+const sessionAuthScheme = "Session"
+
+// Session is the session of a request, see WebContext.Session.
+// The changes are stored by Save, the id is sent back in the session cookie.
+type Session struct {
+	// ID is the session id, empty for a new session which is not saved yet
+	ID     string
+	Values map[string]interface{}
+
+	ctx     *WebContext
+	created time.Time
+}
+
+// Get returns the value of key
+func (s *Session) Get(key string) interface{} {
+	return s.Values[key]
+}
+
+// Set sets the value of key, it is stored by Save
+func (s *Session) Set(key string, value interface{}) {
+	s.Values[key] = value
+}
+
+// Delete removes the value of key, it is stored by Save
+func (s *Session) Delete(key string) {
+	delete(s.Values, key)
+}
+
+// IsNew reports whether the session is not stored yet
+func (s *Session) IsNew() bool {
+	return s.ID == ""
+}
+
+// Token returns the signed session id sent in the cookie, clients without cookies send it as
+// the header Authorization: Session <token>
+func (s *Session) Token() string {
+	return signSessionID(s.ctx.Config().SecretKey, s.ID)
+}
+
+// Save stores the values and sets the session cookie, a new session gets an id
+func (s *Session) Save() error {
+	app := s.ctx.App()
+	if s.ID == "" {
+		sid, err := newSessionID()
+		if err != nil {
+			return err
+		}
+		s.ID, s.created = sid, time.Now()
+	}
+	if err := app.saveSession(s.ID, s.Values, s.created); err != nil {
+		return err
+	}
+	s.setCookie(s.Token(), 0)
+	return nil
+}
+
+// Rotate moves the values to a new session id and a new absolute timeout, the old id is deleted.
+// Call it after login, so an id planted before the login (session fixation) is useless.
+func (s *Session) Rotate() error {
+	old := s.ID
+	s.ID = ""
+	if err := s.Save(); err != nil {
+		return err
+	}
+	if old != "" {
+		return s.ctx.App().SessionStore().Delete(old)
+	}
+	return nil
+}
+
+// Destroy deletes the session and expires the cookie, e.g. on logout
+func (s *Session) Destroy() error {
+	old := s.ID
+	s.ID, s.Values = "", make(map[string]interface{})
+	s.setCookie("", -1)
+	if old != "" {
+		return s.ctx.App().SessionStore().Delete(old)
+	}
+	return nil
+}
+
+func (s *Session) setCookie(value string, maxAge int) {
+	config := s.ctx.Config().SessionCookie
+	http.SetCookie(s.ctx.ResponseWriter, &http.Cookie{
+		Name:     config.Name,
+		Value:    value,
+		Path:     config.Path,
+		Domain:   config.Domain,
+		MaxAge:   maxAge,
+		Secure:   config.Secure,
+		HttpOnly: true,
+		SameSite: config.SameSite,
+	})
+}
+
+// Session returns the session of the request from the header Authorization: Session <token>
+// or the session cookie. A new empty session is returned when the token is missing, forged or expired.
+func (c *WebContext) Session() (*Session, error) {
+	if c.session != nil {
+		return c.session, nil
+	}
+	session := &Session{Values: make(map[string]interface{}), ctx: c}
+	if sid, ok := verifySessionToken(c.Config().SecretKey, c.sessionToken()); ok {
+		values, created, has, err := c.App().loadSession(sid)
+		if err != nil {
+			return nil, err
+		}
+		if has {
+			session.ID, session.Values, session.created = sid, values, created
+		}
+	}
+	c.session = session
+	return session, nil
+}
+
+func (c *WebContext) sessionToken() string {
+	if auth := c.GetHeader("Authorization"); len(auth) > len(sessionAuthScheme) && strings.EqualFold(auth[:len(sessionAuthScheme)+1], sessionAuthScheme+" ") {
+		return strings.TrimSpace(auth[len(sessionAuthScheme)+1:])
+	}
+	if cookie, err := c.Request.Cookie(c.Config().SessionCookie.Name); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// signSessionID returns sid.signature, the signature is the HMAC-SHA256 of sid with secret
+func signSessionID(secret, sid string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(sid))
+	return sid + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func verifySessionToken(secret, token string) (string, bool) {
+	i := strings.LastIndexByte(token, '.')
+	if i <= 0 {
+		return "", false
+	}
+	sid := token[:i]
+	if !hmac.Equal([]byte(signSessionID(secret, sid)), []byte(token)) {
+		return "", false
+	}
+	return sid, true
+}
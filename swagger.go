@@ -5,7 +5,6 @@ import (
 	"html/template"
 	"net/http"
 	"regexp"
-	"sync"
 
 	"github.com/autumnzw/hiweb/swaggerFiles"
 )
@@ -52,8 +51,13 @@ func DomID(domID string) func(c *SwaggerConfig) {
 	}
 }
 
-// Handler wraps `http.Handler` into `http.HandlerFunc`.
+// Handler wraps `http.Handler` into `http.HandlerFunc`, the document is read from the default app.
 func Handler(configFns ...func(*SwaggerConfig)) http.HandlerFunc {
+	return defaultApp.SwaggerHandler(configFns...)
+}
+
+// SwaggerHandler returns the swagger ui handler of the document registered on the app.
+func (app *App) SwaggerHandler(configFns ...func(*SwaggerConfig)) http.HandlerFunc {
 	config := &SwaggerConfig{
 		URL:          "doc.json",
 		DeepLinking:  true,
@@ -72,12 +76,6 @@ func Handler(configFns ...func(*SwaggerConfig)) http.HandlerFunc {
 	var re = regexp.MustCompile(`^(.*/)([^?].*)?[?|.]*$`)
 
 	return func(w http.ResponseWriter, r *http.Request) {
-		headers := w.Header()
-		headers.Set("Access-Control-Allow-Origin", "*")
-		headers.Set("Access-Control-Allow-Headers", "*")
-		headers.Set("Access-Control-Allow-Method", "*")
-		headers.Set("Access-Control-Allow-Credentials", "true")
-
 		matches := re.FindStringSubmatch(r.RequestURI)
 		path := matches[2]
 		prefix := matches[1]
@@ -89,7 +87,7 @@ func Handler(configFns ...func(*SwaggerConfig)) http.HandlerFunc {
 		case "index.html":
 			_ = index.Execute(w, config)
 		case "swagger.json":
-			doc, err := SwaggerReadDoc()
+			doc, err := app.SwaggerReadDoc()
 			if err != nil {
 				panic(err)
 			}
@@ -209,11 +207,6 @@ const indexTempl = `<!-- HTML for static distribution bundle build -->
 </html>
 `
 
-var (
-	swaggerMu sync.RWMutex
-	swag      Swagger
-)
-
 // Swagger is a interface to read swagger document.
 type Swagger interface {
 	ReadDoc() string
@@ -221,22 +214,34 @@ type Swagger interface {
 
 // Register registers swagger for given name.
 func SwaggerRegister(swagger Swagger) {
-	swaggerMu.Lock()
-	defer swaggerMu.Unlock()
+	defaultApp.SwaggerRegister(swagger)
+}
+
+// ReadDoc reads swagger document.
+func SwaggerReadDoc() (string, error) {
+	return defaultApp.SwaggerReadDoc()
+}
+
+// SwaggerRegister registers the swagger document of the app.
+func (app *App) SwaggerRegister(swagger Swagger) {
+	app.swaggerMu.Lock()
+	defer app.swaggerMu.Unlock()
 	if swagger == nil {
 		panic("swagger is nil")
 	}
 
-	if swag != nil {
+	if app.swag != nil {
 		panic("Register called twice for swag")
 	}
-	swag = swagger
+	app.swag = swagger
 }
 
-// ReadDoc reads swagger document.
-func SwaggerReadDoc() (string, error) {
-	if swag != nil {
-		return swag.ReadDoc(), nil
+// SwaggerReadDoc reads the swagger document of the app.
+func (app *App) SwaggerReadDoc() (string, error) {
+	app.swaggerMu.RLock()
+	defer app.swaggerMu.RUnlock()
+	if app.swag != nil {
+		return app.swag.ReadDoc(), nil
 	}
 	return "", errors.New("not yet registered swag")
 }
@@ -1,76 +1,64 @@
 package hiweb
 
 import (
-	"fmt"
-	"sync"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 )
 
+// JwtToken signs infos with the JWTConfig of the default app
 func JwtToken(infos map[string]interface{}) (string, error) {
-	token := jwt.New(jwt.SigningMethodHS256)
-	claims := make(jwt.MapClaims)
-
-	for k, v := range infos {
-		claims[k] = v
-	}
-	token.Claims = claims
+	return defaultApp.JwtToken(infos)
+}
 
-	return token.SignedString([]byte(WebConfig.SecretKey))
+// JwtClaims parses and validates an access token with the JWTConfig of the default app
+func JwtClaims(tokenString string) (jwt.MapClaims, error) {
+	return defaultApp.JwtClaims(tokenString)
 }
 
-var Session *sync.Map
+// JwtToken signs infos as an access token by the signing key of Config.JWT, or HS256 with Config.SecretKey
+// when Config.JWT is nil. The token expires after JWTConfig.TTL unless infos has an exp.
+func (app *App) JwtToken(infos map[string]interface{}) (string, error) {
+	config := app.jwtConfig()
+	return config.issue(infos, config.ttl(), "")
+}
 
-type sessionInfo struct {
-	createTime time.Time
-	info       map[string]interface{}
+// JwtClaims verifies the signature of an access token by its kid and alg, and validates exp, nbf, iat, iss and aud
+func (app *App) JwtClaims(tokenString string) (jwt.MapClaims, error) {
+	return app.jwtConfig().parse(tokenString, "")
 }
 
-//InitSession 删除session时间按秒记
+//InitSession 设置session空闲过期时间(按秒记), 并启动清理过期session的协程, 协程在默认app关闭时停止
 func InitSession(deleteTime int64) { //60*60 //1小时删除
-	Session = &sync.Map{}
-
-	go func() {
-		for true {
-			Session.Range(func(sid, value interface{}) bool {
-				if value.(sessionInfo).createTime.Unix()+deleteTime < time.Now().Unix() {
-					Session.Delete(sid)
-				}
-				return true
-			})
-			time.Sleep(10 * time.Minute)
-		}
-	}()
+	defaultApp.Config.SessionTTL = time.Duration(deleteTime) * time.Second
+	defaultApp.startSessionJanitor(10 * time.Minute)
 }
 
+// SessionToken stores infos in a new session of the default app and returns its id
 func SessionToken(infos map[string]interface{}) string {
-	sid := UUID32()
-	Session.Store(sid, sessionInfo{
-		createTime: time.Now(),
-		info:       infos,
-	})
+	sid, err := defaultApp.NewSession(infos)
+	if err != nil {
+		defaultApp.Logger().Error("session set err:%s", err)
+	}
 	return sid
 }
 
 func SessionDelKey(sid string) {
-	Session.Delete(sid)
+	if err := defaultApp.SessionStore().Delete(sid); err != nil {
+		defaultApp.Logger().Error("session delete err:%s", err)
+	}
 }
 
+// SessionGetVal returns the values of the session sid and refreshes its idle timeout
 func SessionGetVal(sid string) (map[string]interface{}, bool) {
-	if si, has := Session.Load(sid); has {
-		return si.(sessionInfo).info, has
+	info, has, err := defaultApp.LoadSession(sid)
+	if err != nil {
+		defaultApp.Logger().Error("session get err:%s", err)
+		return nil, false
 	}
-	return nil, false
+	return info, has
 }
 
 func SessionUpdateVal(sid string, info map[string]interface{}) error {
-	if sid == "" {
-		return fmt.Errorf("sid is blank")
-	}
-	Session.Store(sid, sessionInfo{
-		createTime: time.Now(),
-		info:       info,
-	})
-	return nil
+	return defaultApp.UpdateSession(sid, info)
 }
@@ -1,8 +1,21 @@
 package hiweb
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
 )
 
 func TestJwtToken(t *testing.T) {
@@ -20,3 +33,173 @@ func TestJwtToken(t *testing.T) {
 	}
 	fmt.Println(m)
 }
+
+func TestJWTAlgorithms(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	keys := []JWTKey{
+		{ID: "hs", Algorithm: "HS256", Secret: []byte("jwt test secret")},
+		{ID: "rs", Algorithm: "RS256", PrivateKey: rsaKey},
+		{ID: "ps", Algorithm: "PS384", PrivateKey: rsaKey},
+		{ID: "es", Algorithm: "ES256", PrivateKey: ecKey},
+		{ID: "ed", Algorithm: "EdDSA", PrivateKey: edKey},
+	}
+	for _, key := range keys {
+		set, err := NewJWTKeySet(key)
+		if err != nil {
+			t.Fatalf("%s key err:%s", key.ID, err)
+		}
+		app := NewApp(func(config *Config) { config.JWT = NewJWTConfig(set) })
+		tokenString, err := app.JwtToken(map[string]interface{}{"sub": "u1"})
+		if err != nil {
+			t.Fatalf("%s sign err:%s", key.ID, err)
+		}
+		if claims, err := app.JwtClaims(tokenString); err != nil || claims["sub"] != "u1" {
+			t.Errorf("%s claims got %v %v", key.ID, claims, err)
+		}
+		// a verify-only key of the public part
+		verifier := JWTKey{ID: key.ID, Algorithm: key.Algorithm, Secret: key.Secret, PublicKey: key.verifyKey()}
+		verifySet, err := NewJWTKeySet(verifier)
+		if err != nil {
+			t.Fatalf("%s verifier err:%s", key.ID, err)
+		}
+		if _, err := NewJWTConfig(verifySet).parse(tokenString, ""); err != nil {
+			t.Errorf("%s verify by public key err:%s", key.ID, err)
+		}
+	}
+
+	if _, err := NewJWTKeySet(JWTKey{ID: "bad", Algorithm: "ES256", PrivateKey: rsaKey}); err == nil {
+		t.Error("rsa key is accepted for ES256")
+	}
+	der, _ := x509.MarshalPKIXPublicKey(edKey.Public())
+	key, err := ParseJWTKeyPEM("pem", "EdDSA", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil || key.PublicKey == nil {
+		t.Errorf("parse pem got %v %v", key, err)
+	}
+
+	// a token signed with the rsa public key as HMAC secret is not accepted by the RS256 key
+	rsSet, _ := NewJWTKeySet(JWTKey{ID: "rs", Algorithm: "RS256", PrivateKey: rsaKey})
+	publicDER, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"exp": time.Now().Add(time.Hour).Unix()})
+	forged.Header["kid"] = "rs"
+	forgedString, _ := forged.SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+	if _, err := NewJWTConfig(rsSet).parse(forgedString, ""); !errors.Is(err, ErrTokenInvalid) {
+		t.Errorf("alg confusion got %v", err)
+	}
+	none, _ := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if _, err := NewJWTConfig(rsSet).parse(none, ""); !errors.Is(err, ErrTokenInvalid) {
+		t.Errorf("alg none got %v", err)
+	}
+}
+
+func TestJWTClaimsValidation(t *testing.T) {
+	set, _ := NewJWTKeySet(JWTKey{ID: "k1", Algorithm: "HS256", Secret: []byte("jwt test secret")})
+	config := NewJWTConfig(set)
+	config.Issuer = "hiweb"
+	config.Audience = []string{"api", "admin"}
+	app := NewApp(func(c *Config) { c.JWT = config })
+
+	now := time.Now()
+	cases := []struct {
+		claims map[string]interface{}
+		err    error
+	}{
+		{map[string]interface{}{}, nil},
+		{map[string]interface{}{"exp": now.Add(-30 * time.Second).Unix()}, nil},
+		{map[string]interface{}{"exp": now.Add(-2 * time.Minute).Unix()}, ErrTokenExpired},
+		{map[string]interface{}{"nbf": now.Add(30 * time.Second).Unix()}, nil},
+		{map[string]interface{}{"nbf": now.Add(2 * time.Minute).Unix()}, ErrTokenNotValidYet},
+		{map[string]interface{}{"iss": "other"}, ErrTokenIssuer},
+		{map[string]interface{}{"aud": []string{"web", "admin"}}, nil},
+		{map[string]interface{}{"aud": "web"}, ErrTokenAudience},
+	}
+	for _, c := range cases {
+		tokenString, err := app.JwtToken(c.claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := app.JwtClaims(tokenString); !errors.Is(err, c.err) {
+			t.Errorf("claims %v got %v want %v", c.claims, err, c.err)
+		}
+	}
+}
+
+func TestJWTKeyRotation(t *testing.T) {
+	set, _ := NewJWTKeySet(JWTKey{ID: "k1", Algorithm: "HS256", Secret: []byte("jwt secret 1")})
+	app := NewApp(func(c *Config) { c.JWT = NewJWTConfig(set) })
+	old, _ := app.JwtToken(map[string]interface{}{"sub": "u1"})
+	if err := set.Rotate(JWTKey{ID: "k2", Algorithm: "HS256", Secret: []byte("jwt secret 2")}); err != nil {
+		t.Fatal(err)
+	}
+	current, _ := app.JwtToken(map[string]interface{}{"sub": "u1"})
+	if token, _, _ := new(jwt.Parser).ParseUnverified(current, jwt.MapClaims{}); token.Header["kid"] != "k2" {
+		t.Errorf("kid after rotation got %v", token.Header["kid"])
+	}
+	if _, err := app.JwtClaims(old); err != nil {
+		t.Errorf("token of the old key err:%s", err)
+	}
+	set.Remove("k1")
+	if _, err := app.JwtClaims(old); !errors.Is(err, ErrTokenInvalid) {
+		t.Errorf("token of the removed key got %v", err)
+	}
+	if _, err := app.JwtClaims(current); err != nil {
+		t.Errorf("token of the new key err:%s", err)
+	}
+}
+
+func TestJWTRefresh(t *testing.T) {
+	revoked := make(map[string]bool)
+	var mu sync.Mutex
+	set, _ := NewJWTKeySet(JWTKey{ID: "k1", Algorithm: "HS256", Secret: []byte("jwt test secret")})
+	config := NewJWTConfig(set)
+	config.Revoke = func(claims jwt.MapClaims) error {
+		mu.Lock()
+		defer mu.Unlock()
+		revoked[claims["jti"].(string)] = true
+		return nil
+	}
+	config.IsRevoked = func(claims jwt.MapClaims) (bool, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return revoked[claims["jti"].(string)], nil
+	}
+	app := NewApp(func(c *Config) { c.JWT = config })
+
+	refresh, err := app.JwtRefreshToken(map[string]interface{}{"sub": "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.JwtClaims(refresh); !errors.Is(err, ErrTokenType) {
+		t.Errorf("refresh token as access token got %v", err)
+	}
+	access, next, err := app.JwtRefresh(refresh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims, err := app.JwtClaims(access); err != nil || claims["sub"] != "u1" {
+		t.Errorf("refreshed access token got %v %v", claims, err)
+	}
+	if _, _, err := app.JwtRefresh(access); !errors.Is(err, ErrTokenType) {
+		t.Errorf("refresh by access token got %v", err)
+	}
+	if _, _, err := app.JwtRefresh(refresh); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("reused refresh token got %v", err)
+	}
+	if err := app.JwtRevoke(access); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.JwtClaims(access); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("revoked access token got %v", err)
+	}
+	if _, _, err := app.JwtRefresh(next); err != nil {
+		t.Errorf("next refresh token err:%s", err)
+	}
+}
+
+func TestDefaultSecret(t *testing.T) {
+	app := NewApp()
+	if err := app.Run("127.0.0.1:0"); err == nil || !strings.Contains(err.Error(), "default secret") {
+		t.Errorf("run with the default secret got %v", err)
+	}
+}
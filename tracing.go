@@ -0,0 +1,454 @@
+package hiweb
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// W3C trace context headers
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// TraceID is the id of a trace, SpanID is the id of a span
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether the id is not all zeros
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether the id is not all zeros
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// FlagSampled is the trace flag of the sampled traces
+const FlagSampled byte = 1
+
+// SpanContext is the part of a span propagated to other services
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Flags      byte
+	TraceState string
+	// Remote is true for a span context parsed from the headers of a request
+	Remote bool
+}
+
+// Sampled reports whether the trace is recorded
+func (sc SpanContext) Sampled() bool {
+	return sc.Flags&FlagSampled != 0
+}
+
+// IsValid reports whether the trace and span ids are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Traceparent formats the traceparent header of version 00
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.Flags)
+}
+
+// ParseTraceparent parses a traceparent header, the versions after 00 are accepted with their extra fields ignored
+func ParseTraceparent(header string) (SpanContext, error) {
+	var sc SpanContext
+	header = strings.TrimSpace(header)
+	parts := strings.Split(header, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, fmt.Errorf("traceparent %q is not valid", header)
+	}
+	version, err := hex.DecodeString(parts[0])
+	if err != nil || version[0] == 0xff || (version[0] == 0 && len(parts) != 4) {
+		return sc, fmt.Errorf("traceparent version %q is not valid", parts[0])
+	}
+	for _, part := range parts[:4] {
+		if strings.ToLower(part) != part {
+			return sc, fmt.Errorf("traceparent %q is not lower case hex", header)
+		}
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil || !sc.TraceID.IsValid() {
+		return sc, fmt.Errorf("trace id %q is not valid", parts[1])
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil || !sc.SpanID.IsValid() {
+		return sc, fmt.Errorf("parent id %q is not valid", parts[2])
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return sc, fmt.Errorf("trace flags %q are not valid", parts[3])
+	}
+	sc.Flags = flags[0]
+	sc.Remote = true
+	return sc, nil
+}
+
+// SpanKind is the role of a span, the values are those of OpenTelemetry
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// StatusCode is the status of a span, the values are those of OpenTelemetry
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = 0
+	StatusOK    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+// Span is an operation of a trace, it is exported by the Tracer when it ends
+type Span struct {
+	Name         string
+	Kind         SpanKind
+	SpanContext  SpanContext
+	ParentSpanID SpanID
+	StartTime    time.Time
+	EndTime      time.Time
+	Attributes   map[string]interface{}
+	Status       StatusCode
+	StatusMsg    string
+
+	tracer *Tracer
+	mu     sync.Mutex
+	ended  bool
+}
+
+// SetAttribute sets an attribute of a string, bool, integer or float value
+func (s *Span) SetAttribute(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Attributes == nil {
+		s.Attributes = make(map[string]interface{})
+	}
+	s.Attributes[key] = value
+}
+
+// SetStatus sets the status of the span
+func (s *Span) SetStatus(code StatusCode, msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Status, s.StatusMsg = code, msg
+}
+
+// RecordError marks the span failed by err, nil is ignored
+func (s *Span) RecordError(err error) {
+	if err != nil {
+		s.SetStatus(StatusError, err.Error())
+	}
+}
+
+// End ends the span and hands it to the exporter of the tracer, a span is ended once
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.EndTime = time.Now()
+	s.mu.Unlock()
+	if s.tracer != nil && s.SpanContext.Sampled() {
+		s.tracer.enqueue(s)
+	}
+}
+
+type spanKey struct{}
+
+// ContextWithSpan returns ctx carrying span
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span of ctx, nil when there is none
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// InjectTraceparent sets the traceparent and tracestate headers of the span of ctx,
+// e.g. on the requests to other services
+func InjectTraceparent(ctx context.Context, header http.Header) {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+	header.Set(TraceparentHeader, span.SpanContext.Traceparent())
+	if span.SpanContext.TraceState != "" {
+		header.Set(TracestateHeader, span.SpanContext.TraceState)
+	}
+}
+
+// SpanExporter sends the finished spans to a tracing backend
+type SpanExporter interface {
+	ExportSpans(ctx context.Context, spans []*Span) error
+	Shutdown(ctx context.Context) error
+}
+
+// Tracer starts the spans and exports the sampled ones in batches
+type Tracer struct {
+	// Exporter receives the finished spans, they are dropped when it is nil
+	Exporter SpanExporter
+	// SampleRatio is the part of the new traces sampled, the traces of remote parents follow their flag
+	SampleRatio float64
+	// BatchSize is how many spans are exported together, default 512
+	BatchSize int
+	// FlushInterval is how often the spans are exported, default 5s
+	FlushInterval time.Duration
+	// MaxQueueSize is how many spans wait for the export, the spans finished while it is full are dropped, default 2048
+	MaxQueueSize int
+	// OnError is called with the errors of the exporter, they are dropped when it is nil
+	OnError func(err error)
+
+	mu        sync.Mutex
+	pending   []*Span
+	dropped   uint64
+	exportMu  sync.Mutex
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+	flush     chan struct{}
+	closed    bool
+}
+
+// NewTracer creates a Tracer sampling every trace
+func NewTracer(exporter SpanExporter) *Tracer {
+	return &Tracer{Exporter: exporter, SampleRatio: 1, BatchSize: 512, FlushInterval: 5 * time.Second, MaxQueueSize: 2048}
+}
+
+// Dropped returns the number of the spans dropped because the queue was full
+func (t *Tracer) Dropped() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.dropped
+}
+
+// Start starts a span, a child of the span of ctx if any, and returns ctx carrying it
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	var parent SpanContext
+	if span := SpanFromContext(ctx); span != nil {
+		parent = span.SpanContext
+	}
+	return t.start(ctx, name, kind, parent)
+}
+
+func (t *Tracer) start(ctx context.Context, name string, kind SpanKind, parent SpanContext) (context.Context, *Span) {
+	span := &Span{Name: name, Kind: kind, StartTime: time.Now(), tracer: t}
+	if parent.IsValid() {
+		span.SpanContext.TraceID = parent.TraceID
+		span.SpanContext.Flags = parent.Flags
+		span.SpanContext.TraceState = parent.TraceState
+		span.ParentSpanID = parent.SpanID
+	} else {
+		rand.Read(span.SpanContext.TraceID[:])
+		if t.sample(span.SpanContext.TraceID) {
+			span.SpanContext.Flags = FlagSampled
+		}
+	}
+	for !span.SpanContext.SpanID.IsValid() {
+		rand.Read(span.SpanContext.SpanID[:])
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+// sample decides by the random low bytes of the trace id
+func (t *Tracer) sample(id TraceID) bool {
+	if t.SampleRatio >= 1 {
+		return true
+	}
+	if t.SampleRatio <= 0 {
+		return false
+	}
+	return float64(binary.BigEndian.Uint64(id[8:])>>11)/(1<<53) < t.SampleRatio
+}
+
+func (t *Tracer) enqueue(span *Span) {
+	if t.Exporter == nil {
+		return
+	}
+	t.startOnce.Do(t.startLoop)
+	batchSize := t.BatchSize
+	if batchSize <= 0 {
+		batchSize = 512
+	}
+	maxQueueSize := t.MaxQueueSize
+	if maxQueueSize <= 0 {
+		maxQueueSize = 2048
+	}
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return
+	}
+	if len(t.pending) >= maxQueueSize {
+		t.dropped++
+		t.mu.Unlock()
+		return
+	}
+	t.pending = append(t.pending, span)
+	full := len(t.pending) >= batchSize
+	t.mu.Unlock()
+	if full {
+		// wakes the export loop, a signal already waiting covers this batch too
+		select {
+		case t.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (t *Tracer) stopChan() chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stop == nil {
+		t.stop = make(chan struct{})
+	}
+	return t.stop
+}
+
+// startLoop exports the pending spans every FlushInterval and when a batch is full, one export at a time
+func (t *Tracer) startLoop() {
+	interval := t.FlushInterval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	stop := t.stopChan()
+	t.flush = make(chan struct{}, 1)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				t.Flush(context.Background())
+			case <-t.flush:
+				t.Flush(context.Background())
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Flush exports the pending spans now
+func (t *Tracer) Flush(ctx context.Context) error {
+	t.exportMu.Lock()
+	defer t.exportMu.Unlock()
+	t.mu.Lock()
+	spans := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+	if len(spans) == 0 || t.Exporter == nil {
+		return nil
+	}
+	err := t.Exporter.ExportSpans(ctx, spans)
+	if err != nil && t.OnError != nil {
+		t.OnError(err)
+	}
+	return err
+}
+
+// Shutdown stops the background exports, exports the pending spans and shuts the exporter down
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	t.stopOnce.Do(func() {
+		// the loop is not started after the shutdown
+		t.startOnce.Do(func() {})
+		close(t.stopChan())
+		t.mu.Lock()
+		t.closed = true
+		t.mu.Unlock()
+	})
+	err := t.Flush(ctx)
+	if t.Exporter != nil {
+		if sErr := t.Exporter.Shutdown(ctx); err == nil {
+			err = sErr
+		}
+	}
+	return err
+}
+
+// Tracing starts a server span for every request, the parent is taken from the traceparent and tracestate headers.
+// The span is in WebContext.Context, see WebContext.Span, and the traceparent of the span is sent in the response.
+// The routes add the spans of the controller invocation and the body binding.
+func Tracing(tracer *Tracer) Middleware {
+	return func(next Next) Next {
+		return func(ctx *WebContext) error {
+			req := ctx.Request
+			parent, err := ParseTraceparent(req.Header.Get(TraceparentHeader))
+			if err == nil {
+				parent.TraceState = strings.TrimSpace(req.Header.Get(TracestateHeader))
+			}
+			name := req.Method + " " + ctx.route
+			if ctx.route == "" {
+				name = req.Method
+			}
+			c, span := tracer.start(ctx.Context(), name, SpanKindServer, parent)
+			ctx.SetContext(c)
+			span.SetAttribute("http.request.method", req.Method)
+			span.SetAttribute("url.path", req.URL.Path)
+			span.SetAttribute("client.address", ctx.RemoteIP())
+			if ctx.route != "" {
+				span.SetAttribute("http.route", ctx.route)
+			}
+			ctx.ResponseWriter.Header().Set(TraceparentHeader, span.SpanContext.Traceparent())
+			ctx.OnDone(func(ctx *WebContext) {
+				status := http.StatusOK
+				if recorder := ctx.Response(); recorder != nil {
+					status = recorder.Status()
+				}
+				span.SetAttribute("http.response.status_code", status)
+				if status >= http.StatusInternalServerError {
+					span.SetStatus(StatusError, http.StatusText(status))
+				}
+				span.End()
+			})
+			return next(ctx)
+		}
+	}
+}
+
+// Span returns the span of the request, nil when the Tracing middleware is not used
+func (c *WebContext) Span() *Span {
+	return SpanFromContext(c.Context())
+}
+
+// traceStep runs fn in a child span of the request span, the request context carries the child span meanwhile
+func traceStep(ctx *WebContext, name string, fn func() error) error {
+	parent := ctx.Span()
+	if parent == nil || parent.tracer == nil {
+		return fn()
+	}
+	saved := ctx.Context()
+	c, span := parent.tracer.Start(saved, name, SpanKindInternal)
+	ctx.SetContext(c)
+	err := fn()
+	ctx.SetContext(saved)
+	span.RecordError(err)
+	span.End()
+	return err
+}
+
+// parseBody parses the body argument dst of a route in the span bind body
+func parseBody(ctx *WebContext, dst interface{}) error {
+	if err := ctx.checkConsumes(); err != nil {
+		return err
+	}
+	return traceStep(ctx, "bind body", func() error {
+		return ctx.controller.ParseValid(dst)
+	})
+}
@@ -0,0 +1,186 @@
+package hiweb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type tracingTestOrder struct {
+	Name string `json:"name"`
+}
+
+type tracingTestController struct {
+	Controller
+}
+
+func (c *tracingTestController) Create(order tracingTestOrder) (string, error) {
+	header := http.Header{}
+	InjectTraceparent(c.Ctx.Context(), header)
+	return header.Get(TraceparentHeader), nil
+}
+
+func TestParseTraceparent(t *testing.T) {
+	sc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil || sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" || !sc.Sampled() {
+		t.Fatalf("traceparent got %+v %v", sc, err)
+	}
+	if sc.Traceparent() != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Errorf("formatted traceparent got %s", sc.Traceparent())
+	}
+	if _, err := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra"); err != nil {
+		t.Errorf("future version err:%s", err)
+	}
+	for _, header := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",
+	} {
+		if _, err := ParseTraceparent(header); err == nil {
+			t.Errorf("invalid traceparent %q is parsed", header)
+		}
+	}
+}
+
+func TestTracingOTLP(t *testing.T) {
+	var mu sync.Mutex
+	var spans []otlpSpan
+	var service string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var traces otlpTraces
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" || r.Header.Get("X-Api-Key") != "k1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&traces); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, rs := range traces.ResourceSpans {
+			service = *rs.Resource.Attributes[0].Value.StringValue
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer collector.Close()
+
+	exporter := NewOTLPExporter(collector.URL, "shop")
+	exporter.Headers = map[string]string{"X-Api-Key": "k1"}
+	tracer := NewTracer(exporter)
+	app := NewApp()
+	app.Use(Tracing(tracer))
+	app.Route("/orders", &tracingTestController{}, "order", "post:Create", RouteOption{})
+
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{"name":"o1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set(TracestateHeader, "vendor=abc")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	responseParent, err := ParseTraceparent(w.Header().Get(TraceparentHeader))
+	if err != nil || responseParent.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("response traceparent got %q %v", w.Header().Get(TraceparentHeader), err)
+	}
+	// the controller sees the invoke span as the parent of its calls
+	var injected string
+	json.Unmarshal(w.Body.Bytes(), &injected)
+	if !strings.HasPrefix(injected, "00-4bf92f3577b34da6a3ce929d0e0e4736-") || injected == w.Header().Get(TraceparentHeader) {
+		t.Errorf("injected traceparent got %q", injected)
+	}
+
+	// a request without traceparent starts a trace
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if sc, err := ParseTraceparent(w.Header().Get(TraceparentHeader)); err != nil || sc.TraceID.String() == "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("new trace got %q %v", w.Header().Get(TraceparentHeader), err)
+	}
+
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	byName := map[string]otlpSpan{}
+	for _, span := range spans {
+		byName[span.Name] = span
+	}
+	server, invoke, bind := byName["POST /orders"], byName["invoke tracingTestController.Create"], byName["bind body"]
+	if service != "shop" || len(spans) != 4 || byName["GET"].Status.Code != StatusUnset {
+		t.Fatalf("service %s spans %+v", service, spans)
+	}
+	if server.ParentSpanID != "00f067aa0ba902b7" || server.Kind != SpanKindServer || server.TraceState != "vendor=abc" || server.SpanID != responseParent.SpanID.String() {
+		t.Errorf("server span got %+v", server)
+	}
+	if invoke.ParentSpanID != server.SpanID || bind.ParentSpanID != invoke.SpanID || bind.TraceID != server.TraceID {
+		t.Errorf("child spans got %+v %+v", invoke, bind)
+	}
+	var status string
+	for _, attr := range server.Attributes {
+		if attr.Key == "http.response.status_code" {
+			status = *attr.Value.IntValue
+		}
+	}
+	if status != "200" {
+		t.Errorf("server span attributes got %+v", server.Attributes)
+	}
+}
+
+type tracingTestBlockedExporter struct {
+	release  chan struct{}
+	mu       sync.Mutex
+	exported int
+}
+
+func (e *tracingTestBlockedExporter) ExportSpans(ctx context.Context, spans []*Span) error {
+	<-e.release
+	e.mu.Lock()
+	e.exported += len(spans)
+	e.mu.Unlock()
+	return nil
+}
+
+func (e *tracingTestBlockedExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func TestTracerQueueFull(t *testing.T) {
+	exporter := &tracingTestBlockedExporter{release: make(chan struct{})}
+	tracer := NewTracer(exporter)
+	tracer.BatchSize, tracer.MaxQueueSize, tracer.FlushInterval = 2, 4, time.Hour
+	goroutines := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		_, span := tracer.Start(context.Background(), "job", SpanKindInternal)
+		span.End()
+	}
+	// a hung exporter holds one batch, the queue keeps MaxQueueSize spans and one goroutine exports
+	if dropped := tracer.Dropped(); dropped < 94 {
+		t.Errorf("dropped spans got %d", dropped)
+	}
+	if n := runtime.NumGoroutine(); n > goroutines+2 {
+		t.Errorf("goroutines grew from %d to %d", goroutines, n)
+	}
+	close(exporter.release)
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	exporter.mu.Lock()
+	defer exporter.mu.Unlock()
+	if exporter.exported+int(tracer.Dropped()) != 100 {
+		t.Errorf("exported %d dropped %d", exporter.exported, tracer.Dropped())
+	}
+}
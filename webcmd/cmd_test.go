@@ -2,6 +2,9 @@ package webcmd
 
 import (
 	"fmt"
+	"go/ast"
+	goparser "go/parser"
+	"go/token"
 	"testing"
 )
 
@@ -9,3 +12,76 @@ func TestCreateRoute(t *testing.T) {
 	err := CreateRoute("./controllers", "hiweb", "http://localhost:8080", "./controllers/api.js")
 	fmt.Printf("err:%s", err)
 }
+
+func TestParseParamComment(t *testing.T) {
+	cases := []struct {
+		line, in, description string
+		required              bool
+	}{
+		{`traceId header string true "trace id"`, "header", "trace id", true},
+		{`sid cookie string false`, "cookie", "", false},
+		{`name body of the message`, "query", "body of the message", false},
+		{`page path index of the list`, "query", "path index of the list", false},
+		{`remark form string maybe`, "query", "form string maybe", false},
+	}
+	for _, c := range cases {
+		operation := NewOperation()
+		if err := operation.ParseParamComment(c.line, "query", nil); err != nil {
+			t.Fatal(err)
+		}
+		param := operation.Params[0]
+		if param.In != c.in || param.Description != c.description || param.Required != c.required {
+			t.Errorf("@Param %s got %+v", c.line, param)
+		}
+	}
+}
+
+func TestTypeSchema(t *testing.T) {
+	src := `package p
+type Status string
+type IDs []int64
+type Order struct {
+	ID int
+}
+type Orders []Order
+var (
+	status Status
+	ids    IDs
+	order  Order
+	orders Orders
+)`
+	file, err := goparser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm := &SwaggerComponent{Schema: map[string]SwaggerComponentStruct{}}
+	schemas := map[string]*SwaggerSchemaRef{}
+	for _, name := range []string{"status", "ids", "order", "orders"} {
+		spec := file.Scope.Lookup(name).Decl.(*ast.ValueSpec)
+		schemas[name] = typeSchema(cm, spec.Type)
+	}
+	if s := schemas["status"]; s.Ref != "" || s.Type != "string" {
+		t.Errorf("Status got %+v", s)
+	}
+	if s := schemas["ids"]; s.Type != "array" || s.Items == nil || s.Items.Ref != "" || s.Items.Type != "integer" {
+		t.Errorf("IDs got %+v", s)
+	}
+	if s := schemas["order"]; s.Ref != "#/components/schemas/Order" {
+		t.Errorf("Order got %+v", s)
+	}
+	if s := schemas["orders"]; s.Type != "array" || s.Items == nil || s.Items.Ref != "#/components/schemas/Order" {
+		t.Errorf("Orders got %+v", s)
+	}
+	if len(cm.Schema) != 1 {
+		t.Errorf("components %v want only Order", cm.Schema)
+	}
+}
+
+func TestSessionSecurityScheme(t *testing.T) {
+	if s := securityScheme("session", ""); s.Name != "hiweb_session" || s.In != "cookie" {
+		t.Errorf("default session scheme got %+v", s)
+	}
+	if s := securityScheme("session", "sid"); s.Name != "sid" || s.In != "cookie" {
+		t.Errorf("session scheme of cookie sid got %+v", s)
+	}
+}
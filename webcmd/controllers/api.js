@@ -2,17 +2,15 @@
 import BAPI from './bapi'
 
 
-function AuthLogin(username,password,test){
+function AuthLogin(password,username){
 
 	let tmpUrl = "/Auth/Login";
 
 	
 		
-		tmpUrl = BAPI.AppendParam(tmpUrl, 'username', username) 
-		
 		tmpUrl = BAPI.AppendParam(tmpUrl, 'password', password) 
 		
-		tmpUrl = BAPI.AppendParam(tmpUrl, 'test', test) 
+		tmpUrl = BAPI.AppendParam(tmpUrl, 'username', username) 
 			
 		
 	
@@ -20,24 +18,50 @@ function AuthLogin(username,password,test){
 		url: tmpUrl,
 		method: 'get',
 	
+	
 	}).then((data) => {
 		return data
 	})
 
 }
 
-function AuthLogin(username,password,test){
+function AuthLoginPost(password,username){
 
 	let tmpUrl = "/Auth/Login";
 
 	
 		let inparam={
 		
+			"password":password,
+		
 			"username":username,
 		
+		}
+		
+	
+	return BAPI.Xhr({
+		url: tmpUrl,
+		method: 'post',
+	
+	
+		body:inparam,
+	
+	}).then((data) => {
+		return data
+	})
+
+}
+
+function ServiceAuthLogin(password,username){
+
+	let tmpUrl = "/Service/Auth/Login";
+
+	
+		let inparam={
+		
 			"password":password,
 		
-			"test":test,
+			"username":username,
 		
 		}
 		
@@ -46,6 +70,7 @@ function AuthLogin(username,password,test){
 		url: tmpUrl,
 		method: 'post',
 	
+	
 		body:inparam,
 	
 	}).then((data) => {
@@ -54,9 +79,34 @@ function AuthLogin(username,password,test){
 
 }
 
-function TokenUpload(){
+function TokenCancel(orderId){
 
-	let tmpUrl = "/Token/Upload";
+	let tmpUrl = "/Token/Cancel";
+
+	
+		let inparam={
+		
+			"orderId":orderId,
+		
+		}
+		
+	
+	return BAPI.Xhr({
+		url: tmpUrl,
+		method: 'post',
+	
+	
+		body:inparam,
+	
+	}).then((data) => {
+		return data
+	})
+
+}
+
+function TokenGet(key){
+
+	let tmpUrl = "/Token/Get/" + encodeURIComponent(key);
 
 	
 			
@@ -66,24 +116,48 @@ function TokenUpload(){
 		url: tmpUrl,
 		method: 'get',
 	
+	
 	}).then((data) => {
 		return data
 	})
 
 }
 
-function TokenLogin(username,password,test){
+function TokenImport(name){
 
-	let tmpUrl = "/Token/Login";
+	let tmpUrl = "/Token/Import";
 
 	
 		let inparam={
 		
-			"username":username,
+			"name":name,
+		
+		}
+		
+	
+	return BAPI.Xhr({
+		url: tmpUrl,
+		method: 'post',
+	
+	
+		body:inparam,
+	
+	}).then((data) => {
+		return data
+	})
+
+}
+
+function TokenLogin(password,username){
+
+	let tmpUrl = "/Token/Login";
+
+	
+		let inparam={
 		
 			"password":password,
 		
-			"test":test,
+			"username":username,
 		
 		}
 		
@@ -92,6 +166,7 @@ function TokenLogin(username,password,test){
 		url: tmpUrl,
 		method: 'post',
 	
+	
 		body:inparam,
 	
 	}).then((data) => {
@@ -100,13 +175,13 @@ function TokenLogin(username,password,test){
 
 }
 
-function TokenGet(key){
+function TokenOrders(page){
 
-	let tmpUrl = "/Token/Get";
+	let tmpUrl = "/Token/Orders";
 
 	
 		
-		tmpUrl = BAPI.AppendParam(tmpUrl, 'key', key) 
+		tmpUrl = BAPI.AppendParam(tmpUrl, 'page', page) 
 			
 		
 	
@@ -114,22 +189,92 @@ function TokenGet(key){
 		url: tmpUrl,
 		method: 'get',
 	
+	
 	}).then((data) => {
 		return data
 	})
 
 }
 
-function AuthLogin(username,password){
+function TokenOrdersItems(orderId,itemId){
 
-	let tmpUrl = "/Service/Auth";
+	let tmpUrl = "/Token/Orders/" + encodeURIComponent(orderId) + "/Items/" + encodeURIComponent(itemId);
 
 	
-		let inparam={
+			
 		
-			"username":username,
+	
+	return BAPI.Xhr({
+		url: tmpUrl,
+		method: 'get',
+	
+	
+	}).then((data) => {
+		return data
+	})
+
+}
+
+function TokenProfile(name){
+
+	let tmpUrl = "/Token/Profile";
+
+	
 		
-			"password":password,
+		tmpUrl = BAPI.AppendParam(tmpUrl, 'name', name) 
+			
+		
+	
+	return BAPI.Xhr({
+		url: tmpUrl,
+		method: 'get',
+	
+	
+	}).then((data) => {
+		return data
+	})
+
+}
+
+function TokenSearch(active,page,score,since,ids,limit){
+
+	let tmpUrl = "/Token/Search";
+
+	
+		
+		tmpUrl = BAPI.AppendParam(tmpUrl, 'active', active) 
+		
+		tmpUrl = BAPI.AppendParam(tmpUrl, 'page', page) 
+		
+		tmpUrl = BAPI.AppendParam(tmpUrl, 'score', score) 
+		
+		tmpUrl = BAPI.AppendParam(tmpUrl, 'since', since) 
+		
+		tmpUrl = BAPI.AppendParam(tmpUrl, 'ids', ids) 
+		
+		tmpUrl = BAPI.AppendParam(tmpUrl, 'limit', limit) 
+			
+		
+	
+	return BAPI.Xhr({
+		url: tmpUrl,
+		method: 'get',
+	
+	
+	}).then((data) => {
+		return data
+	})
+
+}
+
+function TokenTrace(traceId,remark){
+
+	let tmpUrl = "/Token/Trace";
+
+	
+		let inparam={
+		
+			"remark":remark,
 		
 		}
 		
@@ -138,6 +283,13 @@ function AuthLogin(username,password){
 		url: tmpUrl,
 		method: 'post',
 	
+		headers: {
+		
+			"traceId":traceId,
+		
+		},
+	
+	
 		body:inparam,
 	
 	}).then((data) => {
@@ -145,18 +297,97 @@ function AuthLogin(username,password){
 	})
 
 }
+
+function TokenUpload(){
+
+	let tmpUrl = "/Token/Upload";
+
+	
+			
+		
+	
+	return BAPI.Xhr({
+		url: tmpUrl,
+		method: 'get',
+	
+	
+	}).then((data) => {
+		return data
+	})
+
+}
+
+function TokenUsers(page){
+
+	let tmpUrl = "/Token/Users";
+
 	
+		
+		tmpUrl = BAPI.AppendParam(tmpUrl, 'page', page) 
+			
+		
+	
+	return BAPI.Xhr({
+		url: tmpUrl,
+		method: 'get',
+	
+	
+	}).then((data) => {
+		return data
+	})
 
+}
+
+function TokenWait(seconds){
+
+	let tmpUrl = "/Token/Wait";
+
+	
+		
+		tmpUrl = BAPI.AppendParam(tmpUrl, 'seconds', seconds) 
+			
+		
+	
+	return BAPI.Xhr({
+		url: tmpUrl,
+		method: 'get',
+	
+	
+	}).then((data) => {
+		return data
+	})
+
+}
+	
 
-export{ AuthLogin }
 
 export{ AuthLogin }
 
-export{ TokenUpload }
+export{ AuthLoginPost }
 
-export{ TokenLogin }
+export{ ServiceAuthLogin }
+
+export{ TokenCancel }
 
 export{ TokenGet }
 
-export{ ServiceAuth }
+export{ TokenImport }
+
+export{ TokenLogin }
+
+export{ TokenOrders }
+
+export{ TokenOrdersItems }
+
+export{ TokenProfile }
+
+export{ TokenSearch }
+
+export{ TokenTrace }
+
+export{ TokenUpload }
+
+export{ TokenUsers }
+
+export{ TokenWait }
 	
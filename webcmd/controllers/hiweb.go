@@ -7,17 +7,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"github.com/autumnzw/hiweb"
-	"net/http"
+
+	"time"
 
 	"github.com/alecthomas/template"
 )
 
-func init() {
-	http.HandleFunc("/swag/", hiweb.Handler(
-		hiweb.URL("./swagger.json", "hiweb"), //The url pointing to API definition"
-	))
-}
-
 var doc = `{
     "openapi": "3.0.1",
     "info": {
@@ -48,6 +43,21 @@ var doc = `{
                                 "$ref": "#/components/schemas/UserCredentials"
                             }
                         },
+                        "application/msgpack": {
+                            "schema": {
+                                "$ref": "#/components/schemas/UserCredentials"
+                            }
+                        },
+                        "application/xml": {
+                            "schema": {
+                                "$ref": "#/components/schemas/UserCredentials"
+                            }
+                        },
+                        "application/yaml": {
+                            "schema": {
+                                "$ref": "#/components/schemas/UserCredentials"
+                            }
+                        },
                         "text/json": {
                             "schema": {
                                 "$ref": "#/components/schemas/UserCredentials"
@@ -59,11 +69,55 @@ var doc = `{
                     "200": {
                         "description": "Success"
                     },
+                    "400": {
+                        "description": "Bad Request",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
                     "401": {
-                        "description": "Unauthorized"
+                        "description": "Unauthorized",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
                     },
                     "403": {
-                        "description": "Forbidden"
+                        "description": "Forbidden",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
                     }
                 }
             },
@@ -89,6 +143,21 @@ var doc = `{
                                 "$ref": "#/components/schemas/UserCredentials"
                             }
                         },
+                        "application/msgpack": {
+                            "schema": {
+                                "$ref": "#/components/schemas/UserCredentials"
+                            }
+                        },
+                        "application/xml": {
+                            "schema": {
+                                "$ref": "#/components/schemas/UserCredentials"
+                            }
+                        },
+                        "application/yaml": {
+                            "schema": {
+                                "$ref": "#/components/schemas/UserCredentials"
+                            }
+                        },
                         "text/json": {
                             "schema": {
                                 "$ref": "#/components/schemas/UserCredentials"
@@ -100,11 +169,55 @@ var doc = `{
                     "200": {
                         "description": "Success"
                     },
+                    "400": {
+                        "description": "Bad Request",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
                     "401": {
-                        "description": "Unauthorized"
+                        "description": "Unauthorized",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
                     },
                     "403": {
-                        "description": "Forbidden"
+                        "description": "Forbidden",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
                     }
                 }
             }
@@ -117,22 +230,12 @@ var doc = `{
                 "summary": "",
                 "requestBody": {
                     "content": {
-                        "application/*+json": {
-                            "schema": {
-                                "$ref": "#/components/schemas/UserCredentials"
-                            }
-                        },
                         "application/json": {
                             "schema": {
                                 "$ref": "#/components/schemas/UserCredentials"
                             }
                         },
-                        "application/json-patch+json": {
-                            "schema": {
-                                "$ref": "#/components/schemas/UserCredentials"
-                            }
-                        },
-                        "text/json": {
+                        "application/yaml": {
                             "schema": {
                                 "$ref": "#/components/schemas/UserCredentials"
                             }
@@ -143,15 +246,141 @@ var doc = `{
                     "200": {
                         "description": "Success"
                     },
+                    "400": {
+                        "description": "Bad Request",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
                     "401": {
-                        "description": "Unauthorized"
+                        "description": "Unauthorized",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
                     },
                     "403": {
-                        "description": "Forbidden"
+                        "description": "Forbidden",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
                     }
                 }
             }
         },
+        "/Token/Cancel": {
+            "post": {
+                "tags": [
+                    "Token"
+                ],
+                "summary": "",
+                "parameters": [
+                    {
+                        "name": "orderId",
+                        "in": "query",
+                        "description": "",
+                        "required": false,
+                        "schema": {
+                            "type": "string",
+                            "items": {}
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "oauth2": [
+                            "orders:write"
+                        ]
+                    }
+                ]
+            }
+        },
         "/Token/Get/{key}": {
             "get": {
                 "tags": [
@@ -163,7 +392,7 @@ var doc = `{
                         "name": "key",
                         "in": "path",
                         "description": "",
-                        "required": false,
+                        "required": true,
                         "schema": {
                             "type": "string",
                             "items": {}
@@ -174,11 +403,152 @@ var doc = `{
                     "200": {
                         "description": "Success"
                     },
+                    "400": {
+                        "description": "Bad Request",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/Token/Import": {
+            "post": {
+                "tags": [
+                    "Token"
+                ],
+                "summary": "",
+                "parameters": [
+                    {
+                        "name": "name",
+                        "in": "query",
+                        "description": "",
+                        "required": false,
+                        "schema": {
+                            "type": "string",
+                            "items": {}
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "content": {
+                            "application/json": {
+                                "schema": {
+                                    "type": "string"
+                                }
+                            },
+                            "application/msgpack": {
+                                "schema": {
+                                    "type": "string"
+                                }
+                            },
+                            "application/xml": {
+                                "schema": {
+                                    "type": "string"
+                                }
+                            },
+                            "application/yaml": {
+                                "schema": {
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
                     "401": {
-                        "description": "Unauthorized"
+                        "description": "Unauthorized",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
                     },
                     "403": {
-                        "description": "Forbidden"
+                        "description": "Forbidden",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
                     }
                 }
             }
@@ -206,6 +576,21 @@ var doc = `{
                                 "$ref": "#/components/schemas/UserCredentials"
                             }
                         },
+                        "application/msgpack": {
+                            "schema": {
+                                "$ref": "#/components/schemas/UserCredentials"
+                            }
+                        },
+                        "application/xml": {
+                            "schema": {
+                                "$ref": "#/components/schemas/UserCredentials"
+                            }
+                        },
+                        "application/yaml": {
+                            "schema": {
+                                "$ref": "#/components/schemas/UserCredentials"
+                            }
+                        },
                         "text/json": {
                             "schema": {
                                 "$ref": "#/components/schemas/UserCredentials"
@@ -217,46 +602,839 @@ var doc = `{
                     "200": {
                         "description": "Success"
                     },
+                    "400": {
+                        "description": "Bad Request",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
                     "401": {
-                        "description": "Unauthorized"
+                        "description": "Unauthorized",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
                     },
                     "403": {
-                        "description": "Forbidden"
+                        "description": "Forbidden",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
                     }
                 }
             }
         },
-        "/Token/Upload": {
+        "/Token/Orders": {
             "get": {
                 "tags": [
                     "Token"
                 ],
                 "summary": "",
-                "requestBody": {
-                    "content": {
-                        "multipart/form-data": {
-                            "schema": {
-                                "type": "object",
-                                "properties": {
-                                    "file": {
-                                        "type": "string",
-                                        "items": {},
-                                        "format": "binary"
-                                    }
-                                }
-                            }
-                        }
-                    }
-                },
+                "parameters": [
+                    {
+                        "name": "page",
+                        "in": "query",
+                        "description": "",
+                        "required": false,
+                        "schema": {
+                            "type": "integer",
+                            "items": {},
+                            "format": "int32"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "apikey": [
+                            "orders:read"
+                        ]
+                    },
+                    {
+                        "bearer": [
+                            "orders:read"
+                        ]
+                    }
+                ]
+            }
+        },
+        "/Token/Orders/{orderId}/Items/{itemId}": {
+            "get": {
+                "tags": [
+                    "Token"
+                ],
+                "summary": "",
+                "parameters": [
+                    {
+                        "name": "orderId",
+                        "in": "path",
+                        "description": "",
+                        "required": true,
+                        "schema": {
+                            "type": "string",
+                            "items": {}
+                        }
+                    },
+                    {
+                        "name": "itemId",
+                        "in": "path",
+                        "description": "",
+                        "required": true,
+                        "schema": {
+                            "type": "integer",
+                            "items": {},
+                            "format": "int32"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/Token/Profile": {
+            "get": {
+                "tags": [
+                    "Token"
+                ],
+                "summary": "",
+                "parameters": [
+                    {
+                        "name": "name",
+                        "in": "query",
+                        "description": "",
+                        "required": false,
+                        "schema": {
+                            "type": "string",
+                            "items": {}
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "content": {
+                            "application/json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/UserCredentials"
+                                }
+                            },
+                            "application/msgpack": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/UserCredentials"
+                                }
+                            },
+                            "application/xml": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/UserCredentials"
+                                }
+                            },
+                            "application/yaml": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/UserCredentials"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/Token/Search": {
+            "get": {
+                "tags": [
+                    "Token"
+                ],
+                "summary": "",
+                "parameters": [
+                    {
+                        "name": "active",
+                        "in": "query",
+                        "description": "",
+                        "required": false,
+                        "schema": {
+                            "type": "boolean",
+                            "items": {}
+                        }
+                    },
+                    {
+                        "name": "page",
+                        "in": "query",
+                        "description": "",
+                        "required": false,
+                        "schema": {
+                            "type": "integer",
+                            "items": {},
+                            "format": "int64"
+                        }
+                    },
+                    {
+                        "name": "score",
+                        "in": "query",
+                        "description": "",
+                        "required": false,
+                        "schema": {
+                            "type": "number",
+                            "items": {},
+                            "format": "double"
+                        }
+                    },
+                    {
+                        "name": "since",
+                        "in": "query",
+                        "description": "",
+                        "required": false,
+                        "schema": {
+                            "type": "string",
+                            "items": {},
+                            "format": "date-time"
+                        }
+                    },
+                    {
+                        "name": "ids",
+                        "in": "query",
+                        "description": "",
+                        "required": false,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "integer",
+                                "format": "int64"
+                            }
+                        }
+                    },
+                    {
+                        "name": "limit",
+                        "in": "query",
+                        "description": "",
+                        "required": false,
+                        "schema": {
+                            "type": "integer",
+                            "items": {},
+                            "format": "int32",
+                            "nullable": true
+                        }
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "Success"
                     },
+                    "400": {
+                        "description": "Bad Request",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
                     "401": {
-                        "description": "Unauthorized"
+                        "description": "Unauthorized",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
                     },
                     "403": {
-                        "description": "Forbidden"
+                        "description": "Forbidden",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/Token/Trace": {
+            "post": {
+                "tags": [
+                    "Token"
+                ],
+                "summary": "",
+                "parameters": [
+                    {
+                        "name": "traceId",
+                        "in": "header",
+                        "description": "trace id",
+                        "required": true,
+                        "schema": {
+                            "type": "string",
+                            "items": {}
+                        }
+                    },
+                    {
+                        "name": "sid",
+                        "in": "cookie",
+                        "description": "",
+                        "required": false,
+                        "schema": {
+                            "type": "string",
+                            "items": {}
+                        }
+                    }
+                ],
+                "requestBody": {
+                    "content": {
+                        "application/x-www-form-urlencoded": {
+                            "schema": {
+                                "type": "object",
+                                "properties": {
+                                    "remark": {
+                                        "type": "string",
+                                        "items": {}
+                                    }
+                                }
+                            }
+                        },
+                        "multipart/form-data": {
+                            "schema": {
+                                "type": "object",
+                                "properties": {
+                                    "remark": {
+                                        "type": "string",
+                                        "items": {}
+                                    }
+                                }
+                            }
+                        }
+                    }
+                },
+                "responses": {
+                    "200": {
+                        "description": "Success"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/Token/Upload": {
+            "get": {
+                "tags": [
+                    "Token"
+                ],
+                "summary": "",
+                "requestBody": {
+                    "content": {
+                        "multipart/form-data": {
+                            "schema": {
+                                "type": "object",
+                                "properties": {
+                                    "file": {
+                                        "type": "string",
+                                        "items": {},
+                                        "format": "binary"
+                                    }
+                                }
+                            }
+                        }
+                    }
+                },
+                "responses": {
+                    "200": {
+                        "description": "Success"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/Token/Users": {
+            "get": {
+                "tags": [
+                    "Token"
+                ],
+                "summary": "",
+                "parameters": [
+                    {
+                        "name": "page",
+                        "in": "query",
+                        "description": "",
+                        "required": false,
+                        "schema": {
+                            "type": "integer",
+                            "items": {},
+                            "format": "int32"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "content": {
+                            "application/json": {
+                                "schema": {
+                                    "type": "array",
+                                    "items": {
+                                        "$ref": "#/components/schemas/UserCredentials"
+                                    }
+                                }
+                            },
+                            "text/csv": {
+                                "schema": {
+                                    "type": "array",
+                                    "items": {
+                                        "$ref": "#/components/schemas/UserCredentials"
+                                    }
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/Token/Wait": {
+            "get": {
+                "tags": [
+                    "Token"
+                ],
+                "summary": "",
+                "parameters": [
+                    {
+                        "name": "seconds",
+                        "in": "query",
+                        "description": "",
+                        "required": false,
+                        "schema": {
+                            "type": "integer",
+                            "items": {},
+                            "format": "int32"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "content": {
+                            "application/problem+json": {
+                                "schema": {
+                                    "$ref": "#/components/schemas/ProblemDetails"
+                                }
+                            }
+                        }
                     }
                 }
             }
@@ -264,6 +1442,41 @@ var doc = `{
     },
     "components": {
         "schemas": {
+            "ProblemDetails": {
+                "type": "object",
+                "properties": {
+                    "code": {
+                        "type": "string",
+                        "items": {}
+                    },
+                    "detail": {
+                        "type": "string",
+                        "items": {}
+                    },
+                    "details": {
+                        "type": "object",
+                        "items": {}
+                    },
+                    "instance": {
+                        "type": "string",
+                        "items": {}
+                    },
+                    "status": {
+                        "type": "integer",
+                        "items": {},
+                        "format": "int32"
+                    },
+                    "title": {
+                        "type": "string",
+                        "items": {}
+                    },
+                    "type": {
+                        "type": "string",
+                        "items": {}
+                    }
+                },
+                "additionalProperties": true
+            },
             "UserCredentials": {
                 "type": "object",
                 "properties": {
@@ -273,10 +1486,6 @@ var doc = `{
                             "type": "string"
                         }
                     },
-                    "test": {
-                        "type": "number",
-                        "format": "float"
-                    },
                     "username": {
                         "type": "string",
                         "items": {}
@@ -284,6 +1493,26 @@ var doc = `{
                 },
                 "additionalProperties": false
             }
+        },
+        "securitySchemes": {
+            "apikey": {
+                "type": "apiKey",
+                "description": "API key",
+                "name": "X-API-Key",
+                "in": "header"
+            },
+            "bearer": {
+                "type": "http",
+                "description": "JWT授权",
+                "scheme": "bearer",
+                "bearerFormat": "JWT"
+            },
+            "oauth2": {
+                "type": "apiKey",
+                "description": "JWT授权(数据将在请求头中进行传输) 直接在下框中输入Bearer {token}（注意两者之间是一个空格）\"",
+                "name": "Authorization",
+                "in": "header"
+            }
         }
     }
 }
@@ -319,18 +1548,228 @@ func (s *s) ReadDoc() string {
 }
 
 func init() {
-	hiweb.SwaggerRegister(&s{})
+	RegisterRoutes(hiweb.Default())
+}
+
+// RegisterRoutes registers the swagger document and the controller routes on app
+func RegisterRoutes(app *hiweb.App) {
+	app.SwaggerRegister(&s{})
+	app.HandleFunc("/swag/", app.SwaggerHandler(
+		hiweb.URL("./swagger.json", "hiweb"), //The url pointing to API definition"
+	))
 
 	token := Token{}
 
-	hiweb.Route("/Token/Get/", &token, "key", "get:Get", hiweb.RouteOption{IsAuth: false})
+	app.Route("/Auth/Login", &token, "userIn", "*:Same", hiweb.RouteOption{IsAuth: false, New: hiwebNewToken, Invoke: hiwebInvokeTokenSame})
+
+	app.Route("/Service/Auth/Login", &token, "userIn", "post:GenToken", hiweb.RouteOption{IsAuth: false, Consumes: []string{"application/json", "application/yaml"}, New: hiwebNewToken, Invoke: hiwebInvokeTokenGenToken})
+
+	app.Route("/Token/Cancel", &token, "orderId", "post:Cancel", hiweb.RouteOption{IsAuth: true, Roles: []string{"admin", "ops"}, Scopes: []string{"orders:write"}, New: hiwebNewToken, Invoke: hiwebInvokeTokenCancel})
+
+	app.Route("/Token/Get/{key}", &token, "key", "get:Get", hiweb.RouteOption{IsAuth: false, Middlewares: []string{"ratelimit", "audit"}, New: hiwebNewToken, Invoke: hiwebInvokeTokenGet})
+
+	app.Route("/Token/Import", &token, "name", "post:Import", hiweb.RouteOption{IsAuth: false, MaxBody: 10485760, IPAccess: "internal", New: hiwebNewToken, Invoke: hiwebInvokeTokenImport})
+
+	app.Route("/Token/Login", &token, "userIn", "post:Login", hiweb.RouteOption{IsAuth: false, New: hiwebNewToken, Invoke: hiwebInvokeTokenLogin})
+
+	app.Route("/Token/Orders", &token, "page", "get:Orders", hiweb.RouteOption{IsAuth: true, AuthSchemes: []string{"apikey", "bearer"}, Scopes: []string{"orders:read"}, RateLimit: "100/m", New: hiwebNewToken, Invoke: hiwebInvokeTokenOrders})
 
-	hiweb.Route("/Service/Auth/Login", &token, "", "post:GenToken", hiweb.RouteOption{IsAuth: false})
+	app.Route("/Token/Orders/{orderId}/Items/{itemId:int}", &token, "orderId;itemId", "get:Item", hiweb.RouteOption{IsAuth: false, New: hiwebNewToken, Invoke: hiwebInvokeTokenItem})
 
-	hiweb.Route("/Auth/Login", &token, "", "*:Same", hiweb.RouteOption{IsAuth: false})
+	app.Route("/Token/Profile", &token, "name", "get:Profile", hiweb.RouteOption{IsAuth: false, New: hiwebNewToken, Invoke: hiwebInvokeTokenProfile})
 
-	hiweb.Route("/Token/Upload", &token, "", "get:Upload", hiweb.RouteOption{IsAuth: false})
+	app.Route("/Token/Search", &token, "active;page;score;since;ids;limit", "get:Search", hiweb.RouteOption{IsAuth: false, New: hiwebNewToken, Invoke: hiwebInvokeTokenSearch})
 
-	hiweb.Route("/Token/Login", &token, "", "post:Login", hiweb.RouteOption{IsAuth: false})
+	app.Route("/Token/Trace", &token, "traceId;sid;remark", "post:Trace", hiweb.RouteOption{IsAuth: false, Params: map[string]hiweb.ParamSpec{"remark": {In: "form", Required: false}, "sid": {In: "cookie", Required: false}, "traceId": {In: "header", Required: true}}, New: hiwebNewToken, Invoke: hiwebInvokeTokenTrace})
+
+	app.Route("/Token/Upload", &token, "", "get:Upload", hiweb.RouteOption{IsAuth: false, New: hiwebNewToken, Invoke: hiwebInvokeTokenUpload})
+
+	app.Route("/Token/Users", &token, "page", "get:Users", hiweb.RouteOption{IsAuth: false, Produces: []string{"application/json", "text/csv"}, New: hiwebNewToken, Invoke: hiwebInvokeTokenUsers})
+
+	app.Route("/Token/Wait", &token, "seconds", "get:Wait", hiweb.RouteOption{IsAuth: false, Timeout: 5000000000, New: hiwebNewToken, Invoke: hiwebInvokeTokenWait})
+
+}
+
+func hiwebNewToken() hiweb.ControllerInterface {
+	return &Token{}
+}
+
+func hiwebInvokeTokenSame(ctx *hiweb.WebContext) error {
+	c := ctx.Controller().(*Token)
+	args := hiweb.NewArgs(ctx)
+	var arg0 UserCredentials
+	args.Body(&arg0)
+	if err := args.Err(); err != nil {
+		return err
+	}
+	c.Same(arg0)
+	return nil
+}
+
+func hiwebInvokeTokenGenToken(ctx *hiweb.WebContext) error {
+	c := ctx.Controller().(*Token)
+	args := hiweb.NewArgs(ctx)
+	var arg0 UserCredentials
+	args.Body(&arg0)
+	if err := args.Err(); err != nil {
+		return err
+	}
+	c.GenToken(arg0)
+	return nil
+}
+
+func hiwebInvokeTokenCancel(ctx *hiweb.WebContext) error {
+	c := ctx.Controller().(*Token)
+	args := hiweb.NewArgs(ctx)
+	var arg0 string
+	args.Bind("orderId", &arg0)
+	if err := args.Err(); err != nil {
+		return err
+	}
+	c.Cancel(arg0)
+	return nil
+}
 
+func hiwebInvokeTokenGet(ctx *hiweb.WebContext) error {
+	c := ctx.Controller().(*Token)
+	args := hiweb.NewArgs(ctx)
+	var arg0 string
+	args.Bind("key", &arg0)
+	if err := args.Err(); err != nil {
+		return err
+	}
+	c.Get(arg0)
+	return nil
+}
+
+func hiwebInvokeTokenImport(ctx *hiweb.WebContext) error {
+	c := ctx.Controller().(*Token)
+	args := hiweb.NewArgs(ctx)
+	var arg0 string
+	args.Bind("name", &arg0)
+	if err := args.Err(); err != nil {
+		return err
+	}
+	return hiweb.RenderResult(ctx, c.Import(arg0), nil)
+}
+
+func hiwebInvokeTokenLogin(ctx *hiweb.WebContext) error {
+	c := ctx.Controller().(*Token)
+	args := hiweb.NewArgs(ctx)
+	var arg0 UserCredentials
+	args.Body(&arg0)
+	if err := args.Err(); err != nil {
+		return err
+	}
+	c.Login(arg0)
+	return nil
+}
+
+func hiwebInvokeTokenOrders(ctx *hiweb.WebContext) error {
+	c := ctx.Controller().(*Token)
+	args := hiweb.NewArgs(ctx)
+	var arg0 int
+	args.Bind("page", &arg0)
+	if err := args.Err(); err != nil {
+		return err
+	}
+	c.Orders(arg0)
+	return nil
+}
+
+func hiwebInvokeTokenItem(ctx *hiweb.WebContext) error {
+	c := ctx.Controller().(*Token)
+	args := hiweb.NewArgs(ctx)
+	var arg0 string
+	args.Bind("orderId", &arg0)
+	var arg1 int
+	args.Bind("itemId", &arg1)
+	if err := args.Err(); err != nil {
+		return err
+	}
+	c.Item(arg0, arg1)
+	return nil
+}
+
+func hiwebInvokeTokenProfile(ctx *hiweb.WebContext) error {
+	c := ctx.Controller().(*Token)
+	args := hiweb.NewArgs(ctx)
+	var arg0 string
+	args.Bind("name", &arg0)
+	if err := args.Err(); err != nil {
+		return err
+	}
+	v, err := c.Profile(arg0)
+	return hiweb.RenderResult(ctx, v, err)
+}
+
+func hiwebInvokeTokenSearch(ctx *hiweb.WebContext) error {
+	c := ctx.Controller().(*Token)
+	args := hiweb.NewArgs(ctx)
+	var arg0 bool
+	args.Bind("active", &arg0)
+	var arg1 uint
+	args.Bind("page", &arg1)
+	var arg2 float64
+	args.Bind("score", &arg2)
+	var arg3 time.Time
+	args.Bind("since", &arg3)
+	var arg4 []int64
+	args.Bind("ids", &arg4)
+	var arg5 *int
+	if args.Has("limit") {
+		arg5 = new(int)
+		args.Bind("limit", arg5)
+	}
+	if err := args.Err(); err != nil {
+		return err
+	}
+	c.Search(arg0, arg1, arg2, arg3, arg4, arg5)
+	return nil
+}
+
+func hiwebInvokeTokenTrace(ctx *hiweb.WebContext) error {
+	c := ctx.Controller().(*Token)
+	args := hiweb.NewArgs(ctx)
+	var arg0 string
+	args.Bind("traceId", &arg0)
+	var arg1 string
+	args.Bind("sid", &arg1)
+	var arg2 string
+	args.Bind("remark", &arg2)
+	if err := args.Err(); err != nil {
+		return err
+	}
+	c.Trace(arg0, arg1, arg2)
+	return nil
+}
+
+func hiwebInvokeTokenUpload(ctx *hiweb.WebContext) error {
+	c := ctx.Controller().(*Token)
+	c.Upload()
+	return nil
+}
+
+func hiwebInvokeTokenUsers(ctx *hiweb.WebContext) error {
+	c := ctx.Controller().(*Token)
+	args := hiweb.NewArgs(ctx)
+	var arg0 int
+	args.Bind("page", &arg0)
+	if err := args.Err(); err != nil {
+		return err
+	}
+	v, err := c.Users(arg0)
+	return hiweb.RenderResult(ctx, v, err)
+}
+
+func hiwebInvokeTokenWait(ctx *hiweb.WebContext) error {
+	c := ctx.Controller().(*Token)
+	args := hiweb.NewArgs(ctx)
+	arg0 := ctx.Context()
+	var arg1 int
+	args.Bind("seconds", &arg1)
+	if err := args.Err(); err != nil {
+		return err
+	}
+	return c.Wait(arg0, arg1)
 }
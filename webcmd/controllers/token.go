@@ -1,6 +1,9 @@
 package controllers
 
 import (
+	"context"
+	"time"
+
 	"github.com/autumnzw/hiweb"
 )
 
@@ -18,11 +21,13 @@ func (t *Token) Login(userIn UserCredentials) {
 }
 
 //@httpGet
+//@Middleware ratelimit,audit
 func (t *Token) Get(key string) {
 
 }
 
 //@httpPost /Service/Auth/Login
+//@Accept json,yaml
 func (t *Token) GenToken(userIn UserCredentials) {
 
 }
@@ -36,3 +41,63 @@ func (t *Token) Same(userIn UserCredentials) {
 func (t *Token) Upload() {
 
 }
+
+//@httpGet /Token/Orders/{orderId}/Items/{itemId:int}
+func (t *Token) Item(orderId string, itemId int) {
+
+}
+
+//@httpGet
+func (t *Token) Profile(name string) (*UserCredentials, error) {
+	return &UserCredentials{Username: name}, nil
+}
+
+//@httpGet
+//@Produce json,csv
+func (t *Token) Users(page int) ([]UserCredentials, error) {
+	return []UserCredentials{}, nil
+}
+
+//@httpGet
+func (t *Token) Search(active bool, page uint, score float64, since time.Time, ids []int64, limit *int) {
+
+}
+
+//@httpPost
+//@Param traceId header string true "trace id"
+//@Param sid cookie string false
+//@Param remark form string false "remark of the order"
+func (t *Token) Trace(traceId string, sid string, remark string) {
+
+}
+
+//@httpPost
+//@Auth roles=admin,ops scopes=orders:write
+func (t *Token) Cancel(orderId string) {
+
+}
+
+//@httpGet
+//@Auth apikey|bearer scopes=orders:read
+//@RateLimit 100/m
+func (t *Token) Orders(page int) {
+
+}
+
+//@httpGet
+//@Timeout 5s
+func (t *Token) Wait(ctx context.Context, seconds int) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Duration(seconds) * time.Second):
+		return nil
+	}
+}
+
+//@httpPost
+//@MaxBody 10MB
+//@IPAccess internal
+func (t *Token) Import(name string) string {
+	return name
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"text/template"
 	"time"
@@ -67,6 +68,10 @@ type Config struct {
 
 	// GeneratedTime whether swag should generate the timestamp at the top of docs.go
 	GeneratedTime bool
+
+	// SessionCookieName is the cookie of the session auth scheme, the same as Config.SessionCookie.Name of the app,
+	// hiweb_session when blank
+	SessionCookieName string
 }
 
 type SwaggerSpec struct {
@@ -87,10 +92,12 @@ type SwaggerComponentStruct struct {
 }
 
 type SwaggerComponentSecuritySchemes struct {
-	Type        string `json:"type"`
-	Description string `json:"description"`
-	Name        string `json:"name"`
-	In          string `json:"in"`
+	Type         string `json:"type"`
+	Description  string `json:"description"`
+	Name         string `json:"name,omitempty"`
+	In           string `json:"in,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
 }
 
 type SwaggerInfo struct {
@@ -101,12 +108,27 @@ type SwaggerInfo struct {
 type SwaggerMethod struct {
 	Tags []string `json:"tags,omitempty"`
 
-	ProMethodName string                                   `json:"-"`
-	Summary       string                                   `json:"summary"`
-	Params        []SwaggerParameter                       `json:"parameters,omitempty"`
-	RequestBody   map[string]map[string]SwaggerRequestBody `json:"requestBody,omitempty"`
-	Responses     map[string]SwaggerResponsesDescription   `json:"responses"`
-	Security      []map[string][]string                    `json:"security,omitempty"`
+	ProMethodName string   `json:"-"`
+	ProRoute      string   `json:"-"`
+	ProParams     []string `json:"-"`
+	ProInvoker    *Invoker `json:"-"`
+	// ProParamSources are the sources of the arguments annotated by @Param
+	ProParamSources map[string]ParamSource                   `json:"-"`
+	ProMiddlewares  []string                                 `json:"-"`
+	ProRoles        []string                                 `json:"-"`
+	ProAuthSchemes  []string                                 `json:"-"`
+	ProScopes       []string                                 `json:"-"`
+	ProTimeout      time.Duration                            `json:"-"`
+	ProMaxBody      int64                                    `json:"-"`
+	ProRateLimit    string                                   `json:"-"`
+	ProIPAccess     string                                   `json:"-"`
+	ProConsumes     []string                                 `json:"-"`
+	ProProduces     []string                                 `json:"-"`
+	Summary         string                                   `json:"summary"`
+	Params          []SwaggerParameter                       `json:"parameters,omitempty"`
+	RequestBody     map[string]map[string]SwaggerRequestBody `json:"requestBody,omitempty"`
+	Responses       map[string]SwaggerResponsesDescription   `json:"responses"`
+	Security        []map[string][]string                    `json:"security,omitempty"`
 }
 
 type SwaggerRequestBody struct {
@@ -115,7 +137,10 @@ type SwaggerRequestBody struct {
 type SwaggerSchemaRef struct {
 	Ref        string                   `json:"$ref,omitempty"`
 	Type       string                   `json:"type,omitempty"`
+	Format     string                   `json:"format,omitempty"`
+	Items      *SwaggerSchemaRef        `json:"items,omitempty"`
 	Properties map[string]SwaggerSchema `json:"properties,omitempty"`
+	Required   []string                 `json:"required,omitempty"`
 }
 
 func (s *SwaggerRequestBody) GetClassName() string {
@@ -123,7 +148,15 @@ func (s *SwaggerRequestBody) GetClassName() string {
 	return cName
 }
 
+// ParamSource is the source of a method argument, it is generated as hiweb.ParamSpec
+type ParamSource struct {
+	In       string
+	Required bool
+}
+
 type SwaggerParameter struct {
+	// ProIn is the source of the @Param annotation, empty when it is not given
+	ProIn       string        `json:"-"`
 	Name        string        `json:"name"`
 	In          string        `json:"in"`
 	Description string        `json:"description"`
@@ -143,7 +176,8 @@ type SwaggerSchema struct {
 }
 
 type SwaggerResponsesDescription struct {
-	Description string `json:"description"`
+	Description string                        `json:"description"`
+	Content     map[string]SwaggerRequestBody `json:"content,omitempty"`
 }
 
 type OutClass struct {
@@ -152,10 +186,22 @@ type OutClass struct {
 	OutMethods []OutMethod
 }
 type OutMethod struct {
-	Route     string
-	Method    string
-	ParamName string
-	IsAuth    bool
+	Route       string
+	Method      string
+	ParamName   string
+	IsAuth      bool
+	Middlewares []string
+	AuthSchemes []string
+	Roles       []string
+	Scopes      []string
+	Timeout     time.Duration
+	MaxBody     int64
+	RateLimit   string
+	IPAccess    string
+	Consumes    []string
+	Produces    []string
+	Params      map[string]ParamSource
+	Invoker     *Invoker
 }
 
 // Build builds swagger json file  for given searchDir and mainAPIFile. Returns json
@@ -169,6 +215,7 @@ func (g *Gen) Build(config *Config) error {
 	p.PropNamingStrategy = config.PropNamingStrategy
 	p.ParseVendor = config.ParseVendor
 	p.ParseDependency = config.ParseDependency
+	p.SessionCookieName = config.SessionCookieName
 	p.swagger.Info.Title = config.ProjectName
 	p.swagger.Info.Version = "v1"
 	if err := p.ParseAPI(config.SearchDir); err != nil {
@@ -252,6 +299,7 @@ func (g *Gen) writeGoDoc(output io.Writer, swaggerSpec *SwaggerSpec, config *Con
 	}
 
 	outMethodMap := make(map[string]OutClass)
+	imports := make(map[string]string)
 	for k, vs := range swaggerSpec.Paths {
 		// tactions := strings.Split(k, "/")
 		// actions := make([]string, 0)
@@ -278,10 +326,7 @@ func (g *Gen) writeGoDoc(output io.Writer, swaggerSpec *SwaggerSpec, config *Con
 				break
 			}
 		}
-		paramNames := make([]string, 0)
-		for _, p := range sm.Params {
-			paramNames = append(paramNames, p.Name)
-		}
+		paramNames := sm.ProParams
 		isAuth := false
 		if len(sm.Security) > 0 {
 			isAuth = true
@@ -289,8 +334,8 @@ func (g *Gen) writeGoDoc(output io.Writer, swaggerSpec *SwaggerSpec, config *Con
 		cName := sm.Tags[0]
 		lcName := firstLower(cName)
 		route := k
-		if i := strings.Index(route, "{"); i > 0 {
-			route = route[:i]
+		if sm.ProRoute != "" {
+			route = sm.ProRoute
 		}
 		// if len(actions) > 2 {
 		// 	route = fmt.Sprintf("/%s/%s/", actions[0], actions[1])
@@ -303,12 +348,34 @@ func (g *Gen) writeGoDoc(output io.Writer, swaggerSpec *SwaggerSpec, config *Con
 			outs = OutClass{Class: cName, LowerClass: lcName, OutMethods: make([]OutMethod, 0)}
 		}
 		outs.OutMethods = append(outs.OutMethods, OutMethod{
-			Route:     route,
-			Method:    httpMethod + ":" + sm.ProMethodName,
-			ParamName: strings.Join(paramNames, ";"),
-			IsAuth:    isAuth,
+			Route:       route,
+			Method:      httpMethod + ":" + sm.ProMethodName,
+			ParamName:   strings.Join(paramNames, ";"),
+			IsAuth:      isAuth,
+			Middlewares: sm.ProMiddlewares,
+			AuthSchemes: sm.ProAuthSchemes,
+			Roles:       sm.ProRoles,
+			Scopes:      sm.ProScopes,
+			Timeout:     sm.ProTimeout,
+			MaxBody:     sm.ProMaxBody,
+			RateLimit:   sm.ProRateLimit,
+			IPAccess:    sm.ProIPAccess,
+			Consumes:    sm.ProConsumes,
+			Produces:    sm.ProProduces,
+			Params:      sm.ProParamSources,
+			Invoker:     sm.ProInvoker,
 		})
 		outMethodMap[cName] = outs
+		if sm.ProInvoker != nil {
+			for importPath, name := range sm.ProInvoker.Imports {
+				imports[importPath] = name
+			}
+		}
+	}
+	for _, outs := range outMethodMap {
+		sort.Slice(outs.OutMethods, func(i, j int) bool {
+			return outs.OutMethods[i].Route < outs.OutMethods[j].Route
+		})
 	}
 	buffer := &bytes.Buffer{}
 	err = generator.Execute(buffer, struct {
@@ -318,6 +385,7 @@ func (g *Gen) writeGoDoc(output io.Writer, swaggerSpec *SwaggerSpec, config *Con
 		GeneratedTime bool
 		Doc           string
 		Methods       map[string]OutClass
+		Imports       map[string]string
 	}{
 		PackageName:   packageName,
 		ProjectName:   config.ProjectName,
@@ -325,6 +393,7 @@ func (g *Gen) writeGoDoc(output io.Writer, swaggerSpec *SwaggerSpec, config *Con
 		GeneratedTime: config.GeneratedTime,
 		Doc:           string(buf),
 		Methods:       outMethodMap,
+		Imports:       imports,
 	})
 	if err != nil {
 		return err
@@ -346,18 +415,13 @@ package {{.PackageName}}
 import (
 	"bytes"
 	"encoding/json"
-	"net/http"
 	"github.com/autumnzw/hiweb"
+{{range $path, $name := .Imports}}
+	{{if $name}}{{$name}} {{end}}"{{$path}}"{{end}}
 
 	"github.com/alecthomas/template"
 )
 
-func init(){
-	http.HandleFunc("/swag/", hiweb.Handler(
-		hiweb.URL("./swagger.json","{{.ProjectName}}"), //The url pointing to API definition"
-	))
-}
-
 var doc = ` + "`{{ printDoc .Doc}}`" + `
 
 type swaggerInfo struct {
@@ -391,12 +455,55 @@ func (s *s) ReadDoc() string {
 }
 
 func init() {
-	hiweb.SwaggerRegister(&s{})
+	RegisterRoutes(hiweb.Default())
+}
+
+// RegisterRoutes registers the swagger document and the controller routes on app
+func RegisterRoutes(app *hiweb.App) {
+	app.SwaggerRegister(&s{})
+	app.HandleFunc("/swag/", app.SwaggerHandler(
+		hiweb.URL("./swagger.json","{{.ProjectName}}"), //The url pointing to API definition"
+	))
 {{range $si,$vs := .Methods}}
 	{{$vs.LowerClass}} := {{$vs.Class}}{}
 {{range $i,$v := $vs.OutMethods}}
-	hiweb.Route("{{$v.Route}}",&{{$vs.LowerClass}},"{{$v.ParamName}}","{{$v.Method}}",hiweb.RouteOption{IsAuth:{{$v.IsAuth}}})	
+	app.Route("{{$v.Route}}",&{{$vs.LowerClass}},"{{$v.ParamName}}","{{$v.Method}}",hiweb.RouteOption{IsAuth:{{$v.IsAuth}}{{if $v.AuthSchemes}},AuthSchemes:{{printf "%#v" $v.AuthSchemes}}{{end}}{{if $v.Roles}},Roles:{{printf "%#v" $v.Roles}}{{end}}{{if $v.Scopes}},Scopes:{{printf "%#v" $v.Scopes}}{{end}}{{if $v.Timeout}},Timeout:{{printf "%d" $v.Timeout}}{{end}}{{if $v.MaxBody}},MaxBody:{{$v.MaxBody}}{{end}}{{if $v.RateLimit}},RateLimit:"{{$v.RateLimit}}"{{end}}{{if $v.IPAccess}},IPAccess:"{{$v.IPAccess}}"{{end}}{{if $v.Consumes}},Consumes:{{printf "%#v" $v.Consumes}}{{end}}{{if $v.Produces}},Produces:{{printf "%#v" $v.Produces}}{{end}}{{if $v.Middlewares}},Middlewares:{{printf "%#v" $v.Middlewares}}{{end}}{{if $v.Params}},Params:map[string]hiweb.ParamSpec{ {{range $name,$p := $v.Params}}"{{$name}}":{In:"{{$p.In}}",Required:{{$p.Required}}},{{end}} }{{end}}{{if $v.Invoker}},New:hiwebNew{{$vs.Class}},Invoke:{{$v.Invoker.Name}}{{end}}})	
 {{end}}	
 {{end}}
 }
-`
+{{range $si,$vs := .Methods}}
+func hiwebNew{{$vs.Class}}() hiweb.ControllerInterface {
+	return &{{$vs.Class}}{}
+}
+{{range $i,$v := $vs.OutMethods}}{{with $v.Invoker}}
+func {{.Name}}(ctx *hiweb.WebContext) error {
+	c := ctx.Controller().(*{{.Class}})
+{{- if .Args}}
+	args := hiweb.NewArgs(ctx)
+{{- range .Args}}
+{{- if eq .Kind "context"}}
+	{{.Var}} := ctx.Context()
+{{- else if eq .Kind "optional"}}
+	var {{.Var}} {{.Type}}
+	if args.Has("{{.Name}}") {
+		{{.Var}} = new({{.Elem}})
+		args.Bind("{{.Name}}", {{.Var}})
+	}
+{{- else if eq .Kind "bodyPtr"}}
+	{{.Var}} := new({{.Elem}})
+	args.Body({{.Var}})
+{{- else if eq .Kind "body"}}
+	var {{.Var}} {{.Type}}
+	args.Body(&{{.Var}})
+{{- else}}
+	var {{.Var}} {{.Type}}
+	args.Bind("{{.Name}}", &{{.Var}})
+{{- end}}
+{{- end}}
+	if err := args.Err(); err != nil {
+		return err
+	}
+{{- end}}
+	{{.Call}}
+}
+{{end}}{{end}}{{end}}`
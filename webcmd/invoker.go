@@ -0,0 +1,182 @@
+package webcmd
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Invoker is the typed adapter generated for a controller method,
+// it binds the arguments by hiweb.Args and calls the method without reflection.
+type Invoker struct {
+	Name  string
+	Class string
+	Args  []InvokerArg
+	Call  string
+	// Imports are the packages of the argument types, the alias or "" by import path
+	Imports map[string]string
+}
+
+// InvokerArg is a method argument of an Invoker.
+// Kind is value, optional (pointer to a value), body, bodyPtr (pointer to a body) or context (the request context.Context).
+type InvokerArg struct {
+	Name string
+	Var  string
+	Type string
+	Elem string
+	Kind string
+}
+
+// generatedImports are imported by the generated file already
+var generatedImports = map[string]bool{
+	"bytes":                          true,
+	"encoding/json":                  true,
+	"github.com/autumnzw/hiweb":      true,
+	"github.com/alecthomas/template": true,
+}
+
+// newInvoker returns the invoker of the method decl of class, nil when an argument type cannot be declared
+func newInvoker(class string, decl *ast.FuncDecl, astFile *ast.File) *Invoker {
+	inv := &Invoker{
+		Name:    "hiwebInvoke" + class + decl.Name.Name,
+		Class:   class,
+		Imports: make(map[string]string),
+	}
+	vars := make([]string, 0)
+	for _, param := range decl.Type.Params.List {
+		if _, ok := param.Type.(*ast.Ellipsis); ok {
+			return nil
+		}
+		if isContextType(param.Type, astFile) {
+			for range param.Names {
+				arg := InvokerArg{Var: fmt.Sprintf("arg%d", len(vars)), Kind: "context"}
+				inv.Args = append(inv.Args, arg)
+				vars = append(vars, arg.Var)
+			}
+			continue
+		}
+		if !inv.addImports(param.Type, astFile) {
+			return nil
+		}
+		for _, paramName := range param.Names {
+			arg := InvokerArg{
+				Name: paramName.Name,
+				Var:  fmt.Sprintf("arg%d", len(vars)),
+				Type: types.ExprString(param.Type),
+				Kind: "value",
+			}
+			star, isPtr := param.Type.(*ast.StarExpr)
+			if isPtr {
+				arg.Elem = types.ExprString(star.X)
+			}
+			if _, isBody := paramSchema(param.Type); isBody {
+				arg.Kind = "body"
+				if isPtr {
+					arg.Kind = "bodyPtr"
+				}
+			} else if isPtr {
+				arg.Kind = "optional"
+			}
+			inv.Args = append(inv.Args, arg)
+			vars = append(vars, arg.Var)
+		}
+	}
+	inv.Call = invokeCall(decl, vars)
+	return inv
+}
+
+// isContextType reports whether expr is context.Context, the argument is filled with the request context
+func isContextType(expr ast.Expr, astFile *ast.File) bool {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Context" {
+		return false
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return false
+	}
+	for _, spec := range astFile.Imports {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		name := path.Base(importPath)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if importPath == "context" && name == pkg.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// addImports adds the packages used by the type expr, false when a package is not imported by astFile
+func (inv *Invoker) addImports(expr ast.Expr, astFile *ast.File) bool {
+	ok := true
+	ast.Inspect(expr, func(n ast.Node) bool {
+		sel, isSel := n.(*ast.SelectorExpr)
+		if !isSel {
+			return true
+		}
+		pkg, isIdent := sel.X.(*ast.Ident)
+		if !isIdent {
+			return true
+		}
+		found := false
+		for _, spec := range astFile.Imports {
+			importPath, _ := strconv.Unquote(spec.Path.Value)
+			name, alias := path.Base(importPath), ""
+			if spec.Name != nil {
+				name, alias = spec.Name.Name, spec.Name.Name
+			}
+			if name == pkg.Name {
+				found = true
+				if !generatedImports[importPath] {
+					inv.Imports[importPath] = alias
+				}
+			}
+		}
+		ok = ok && found
+		return false
+	})
+	return ok
+}
+
+// invokeCall returns the statements calling the method with vars and rendering its results
+func invokeCall(decl *ast.FuncDecl, vars []string) string {
+	call := fmt.Sprintf("c.%s(%s)", decl.Name.Name, strings.Join(vars, ", "))
+	if decl.Type.Results == nil || len(decl.Type.Results.List) == 0 {
+		return call + "\n\treturn nil"
+	}
+	results := make([]string, 0)
+	hasValue, hasErr := false, false
+	for _, result := range decl.Type.Results.List {
+		n := len(result.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			if ident, ok := result.Type.(*ast.Ident); ok && ident.Name == "error" && !hasErr {
+				hasErr = true
+				results = append(results, "err")
+			} else if !hasValue {
+				hasValue = true
+				results = append(results, "v")
+			} else {
+				results = append(results, "_")
+			}
+		}
+	}
+	switch {
+	case hasValue && hasErr:
+		return fmt.Sprintf("%s := %s\n\treturn hiweb.RenderResult(ctx, v, err)", strings.Join(results, ", "), call)
+	case hasValue && len(results) == 1:
+		return fmt.Sprintf("return hiweb.RenderResult(ctx, %s, nil)", call)
+	case hasValue:
+		return fmt.Sprintf("%s := %s\n\treturn hiweb.RenderResult(ctx, v, nil)", strings.Join(results, ", "), call)
+	case len(results) == 1:
+		return "return " + call
+	}
+	return fmt.Sprintf("%s := %s\n\treturn err", strings.Join(results, ", "), call)
+}
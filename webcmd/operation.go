@@ -10,6 +10,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-openapi/spec"
 	"golang.org/x/tools/go/loader"
@@ -27,7 +28,10 @@ type Operation struct {
 
 var mimeTypeAliases = map[string]string{
 	"json":                  "application/json",
-	"xml":                   "text/xml",
+	"xml":                   "application/xml",
+	"yaml":                  "application/yaml",
+	"msgpack":               "application/msgpack",
+	"csv":                   "text/csv",
 	"plain":                 "text/plain",
 	"html":                  "text/html",
 	"mpfd":                  "multipart/form-data",
@@ -87,6 +91,20 @@ func (operation *Operation) ParseComment(comment string, astFile *ast.File) erro
 		err = operation.ParseHttpPutComment(lineRemainder)
 	case "@upload":
 		err = operation.ParseParamComment(lineRemainder, "formData", astFile)
+	case "@middleware":
+		err = operation.ParseMiddlewareComment(lineRemainder)
+	case "@timeout":
+		err = operation.ParseTimeoutComment(lineRemainder)
+	case "@maxbody":
+		err = operation.ParseMaxBodyComment(lineRemainder)
+	case "@ratelimit":
+		err = operation.ParseRateLimitComment(lineRemainder)
+	case "@ipaccess":
+		err = operation.ParseIPAccessComment(lineRemainder)
+	case "@accept":
+		err = operation.ParseAcceptComment(lineRemainder)
+	case "@produce":
+		err = operation.ParseProduceComment(lineRemainder)
 	default:
 		err = operation.ParseMetadata(attribute, lowerAttribute, lineRemainder)
 	}
@@ -122,19 +140,58 @@ func (operation *Operation) ParseMetadata(attribute, lowerAttribute, lineRemaind
 
 var paramPattern = regexp.MustCompile(`(\S+)[\s]+([\w]+)[\s]+([\S.]+)[\s]+([\w]+)[\s]+"([^"]+)"`)
 
+// paramSources are the sources of the @Param annotation, formData is the swag name of form
+var paramSources = map[string]string{
+	"path":     "path",
+	"query":    "query",
+	"header":   "header",
+	"cookie":   "cookie",
+	"body":     "body",
+	"form":     "form",
+	"formdata": "form",
+}
+
+// paramShapePattern is the full shape of a @Param with a source: name source type required ["comment"]
+var paramShapePattern = regexp.MustCompile(`^(\S+)\s+(\S+)\s+(\S+)\s+(\S+)\s*(.*)$`)
+
 // ParseParamComment parses params return []string of param properties
-// E.g. @Param	queryText		formData	      string	  true		        "The email for login"
-//              [param name]    [paramType] [data type]  [is mandatory?]   [Comment]
-// E.g. @Param   some_id     path    int     true        "Some ID"
+// E.g. @Param	traceId		header	      string	  true		        "The trace id"
+//              [param name]    [source] [data type]  [is mandatory?]   [Comment]
+// E.g. @Param   name    The name of the user
+// The source is read only from the full shape, so a legacy comment may start with a word like body or path.
 func (operation *Operation) ParseParamComment(commentLine string, inType string, astFile *ast.File) error {
-	matches := strings.SplitN(commentLine, " ", 2)
-	if len(matches) < 2 {
+	fields := strings.Fields(commentLine)
+	if len(fields) < 2 {
 		return fmt.Errorf("param len is min 2:%s", commentLine)
 	}
+	name := fields[0]
+	rest := strings.TrimSpace(commentLine[len(name):])
+	m := paramShapePattern.FindStringSubmatch(strings.TrimSpace(commentLine))
+	var source string
+	var required bool
+	if inType == "query" && m != nil {
+		var hasSource bool
+		source, hasSource = paramSources[strings.ToLower(m[2])]
+		var err error
+		if required, err = strconv.ParseBool(m[4]); err != nil || !hasSource {
+			source = ""
+		}
+	}
+	if source == "" {
+		operation.Params = append(operation.Params, SwaggerParameter{
+			Name:        name,
+			Description: rest,
+			In:          inType,
+		})
+		return nil
+	}
 	operation.Params = append(operation.Params, SwaggerParameter{
-		Name:        matches[0],
-		Description: matches[1],
-		In:          inType,
+		Name:        name,
+		In:          source,
+		ProIn:       source,
+		Required:    required,
+		Schema:      getSwaggerSchema(m[3]),
+		Description: strings.Trim(strings.TrimSpace(m[5]), `"`),
 	})
 	return nil
 }
@@ -335,15 +392,15 @@ func (operation *Operation) ParseTagsComment(commentLine string) {
 	//}
 }
 
-// ParseAcceptComment parses comment for given `accept` comment string.
-//func (operation *Operation) ParseAcceptComment(commentLine string) error {
-//	return parseMimeTypeList(commentLine, &operation.Consumes, "%v accept type can't be accepted")
-//}
+// ParseAcceptComment parses comment for given `accept` comment string, e.g. @Accept json,xml
+func (operation *Operation) ParseAcceptComment(commentLine string) error {
+	return parseMimeTypeList(commentLine, &operation.ProConsumes, "%v accept type can't be accepted")
+}
 
-// ParseProduceComment parses comment for given `produce` comment string.
-//func (operation *Operation) ParseProduceComment(commentLine string) error {
-//	return parseMimeTypeList(commentLine, &operation.Produces, "%v produce type can't be accepted")
-//}
+// ParseProduceComment parses comment for given `produce` comment string, e.g. @Produce json,csv
+func (operation *Operation) ParseProduceComment(commentLine string) error {
+	return parseMimeTypeList(commentLine, &operation.ProProduces, "%v produce type can't be accepted")
+}
 
 // parseMimeTypeList parses a list of MIME Types for a comment like
 // `produce` (`Content-Type:` response header) or
@@ -351,6 +408,7 @@ func (operation *Operation) ParseTagsComment(commentLine string) {
 func parseMimeTypeList(mimeTypeList string, typeList *[]string, format string) error {
 	mimeTypes := strings.Split(mimeTypeList, ",")
 	for _, typeName := range mimeTypes {
+		typeName = strings.TrimSpace(typeName)
 		if mimeTypePattern.MatchString(typeName) {
 			*typeList = append(*typeList, typeName)
 			continue
@@ -382,11 +440,137 @@ func (operation *Operation) ParseRouterComment(commentLine string) error {
 	return nil
 }
 
-// ParseAuthComment parses comment for gived `security` comment string.
+// ParseAuthComment parses comment for gived `security` comment string,
+// e.g. @Auth apikey|bearer roles=admin,ops scopes=orders:write
 func (operation *Operation) ParseAuthComment(commentLine string) error {
-	m := map[string][]string{}
-	m["oauth2"] = []string{}
-	operation.Security = append(operation.Security, m)
+	for i, field := range strings.Fields(commentLine) {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			if i != 0 {
+				return fmt.Errorf("auth argument %s is not key=value", field)
+			}
+			for _, name := range strings.Split(field, "|") {
+				if name = strings.TrimSpace(name); name != "" {
+					operation.ProAuthSchemes = append(operation.ProAuthSchemes, name)
+				}
+			}
+			continue
+		}
+		var values []string
+		for _, v := range strings.Split(kv[1], ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		switch strings.ToLower(kv[0]) {
+		case "roles":
+			operation.ProRoles = append(operation.ProRoles, values...)
+		case "scopes":
+			operation.ProScopes = append(operation.ProScopes, values...)
+		default:
+			return fmt.Errorf("unknown auth argument %s", kv[0])
+		}
+	}
+	scopes := operation.ProScopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	if len(operation.ProAuthSchemes) == 0 {
+		m := map[string][]string{}
+		m["oauth2"] = scopes
+		operation.Security = append(operation.Security, m)
+		return nil
+	}
+	// any of the schemes is accepted
+	for _, name := range operation.ProAuthSchemes {
+		operation.Security = append(operation.Security, map[string][]string{name: scopes})
+	}
+	return nil
+}
+
+// ParseMiddlewareComment parses comment for gived `middleware` comment string, e.g. @Middleware ratelimit,audit
+func (operation *Operation) ParseMiddlewareComment(commentLine string) error {
+	for _, name := range strings.Split(commentLine, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		operation.ProMiddlewares = append(operation.ProMiddlewares, name)
+	}
+	if len(operation.ProMiddlewares) == 0 {
+		return fmt.Errorf("middleware name is blank")
+	}
+	return nil
+}
+
+// ParseIPAccessComment parses comment for gived `ipaccess` comment string, e.g. @IPAccess internal,
+// the name of an IPAccess registered by App.RegisterIPAccess
+func (operation *Operation) ParseIPAccessComment(commentLine string) error {
+	name := strings.TrimSpace(commentLine)
+	if name == "" || strings.ContainsAny(name, " \t,") {
+		return fmt.Errorf("ip access %q is not a name", commentLine)
+	}
+	operation.ProIPAccess = name
+	return nil
+}
+
+// ParseTimeoutComment parses comment for gived `timeout` comment string, e.g. @Timeout 5s, -1s disables the global timeout
+func (operation *Operation) ParseTimeoutComment(commentLine string) error {
+	timeout, err := time.ParseDuration(strings.TrimSpace(commentLine))
+	if err != nil {
+		return fmt.Errorf("timeout %q: %w", commentLine, err)
+	}
+	if timeout == 0 {
+		return fmt.Errorf("timeout %q is zero", commentLine)
+	}
+	operation.ProTimeout = timeout
+	return nil
+}
+
+// byteUnits are the units of @MaxBody, powers of 1024
+var byteUnits = map[string]int64{"": 1, "B": 1, "KB": 1 << 10, "MB": 1 << 20, "GB": 1 << 30}
+
+var byteSizePattern = regexp.MustCompile(`^(-?\d+)\s*([a-zA-Z]*)$`)
+
+// ParseMaxBodyComment parses comment for gived `maxbody` comment string, e.g. @MaxBody 10MB, -1 disables the global limit
+func (operation *Operation) ParseMaxBodyComment(commentLine string) error {
+	matches := byteSizePattern.FindStringSubmatch(strings.TrimSpace(commentLine))
+	if matches == nil {
+		return fmt.Errorf("max body %q is not a size like 10MB", commentLine)
+	}
+	unit, has := byteUnits[strings.ToUpper(matches[2])]
+	if !has {
+		return fmt.Errorf("max body %q has an unknown unit", commentLine)
+	}
+	size, err := strconv.ParseInt(matches[1], 10, 64)
+	if err != nil || size == 0 {
+		return fmt.Errorf("max body %q is not valid", commentLine)
+	}
+	operation.ProMaxBody = size * unit
+	return nil
+}
+
+// rateUnits are the periods of @RateLimit besides the durations like 10m, as hiweb.ParseRate accepts them
+var rateUnits = map[string]bool{"s": true, "sec": true, "second": true, "m": true, "min": true, "minute": true,
+	"h": true, "hour": true, "d": true, "day": true}
+
+// ParseRateLimitComment parses comment for gived `ratelimit` comment string, e.g. @RateLimit 100/m
+func (operation *Operation) ParseRateLimitComment(commentLine string) error {
+	parts := strings.SplitN(strings.TrimSpace(commentLine), "/", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("rate limit %q is not requests/period like 100/m", commentLine)
+	}
+	requests, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || requests <= 0 {
+		return fmt.Errorf("rate limit %q needs a positive number of requests", commentLine)
+	}
+	period := strings.ToLower(strings.TrimSpace(parts[1]))
+	if !rateUnits[period] {
+		if d, err := time.ParseDuration(period); err != nil || d <= 0 {
+			return fmt.Errorf("rate limit %q has an unknown period", commentLine)
+		}
+	}
+	operation.ProRateLimit = fmt.Sprintf("%d/%s", requests, period)
 	return nil
 }
 
@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
 )
 
@@ -38,6 +39,9 @@ type Parser struct {
 	// ParseDependencies whether swag should be parse outside dependency folder
 	ParseDependency bool
 
+	// SessionCookieName is the cookie of the session auth scheme, hiweb_session when blank
+	SessionCookieName string
+
 	// structStack stores full names of the structures that were already parsed or are being parsed now
 	structStack []string
 
@@ -134,18 +138,80 @@ func getSwaggerSchema(typeName string) SwaggerSchema {
 	switch typeName {
 	case "string":
 		ss.Type = typeName
-	case "int":
+	case "int", "int8", "int16", "int32", "uint8", "uint16", "byte", "rune":
 		ss.Type = "integer"
 		ss.Format = "int32"
-	case "float64", "float32":
+	case "int64", "uint", "uint32", "uint64":
+		ss.Type = "integer"
+		ss.Format = "int64"
+	case "float32":
 		ss.Type = "number"
 		ss.Format = "float"
+	case "float64":
+		ss.Type = "number"
+		ss.Format = "double"
+	case "bool":
+		ss.Type = "boolean"
+	case "time.Time":
+		ss.Type = "string"
+		ss.Format = "date-time"
+	case "time.Duration":
+		ss.Type = "string"
+		ss.Format = "duration"
 	case "file":
 		ss.Type = "file"
 	}
 	return ss
 }
 
+// paramSchema returns the schema of a method argument bound from the path or query,
+// isBody reports that the argument is a struct or map decoded from the request body instead.
+func paramSchema(expr ast.Expr) (ss SwaggerSchema, isBody bool) {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		ss, isBody = paramSchema(t.X)
+		ss.Nullable = true
+		return ss, isBody
+	case *ast.ArrayType:
+		if ident, ok := t.Elt.(*ast.Ident); ok && ident.Name == "byte" {
+			return getSwaggerSchema("string"), false
+		}
+		items, _ := paramSchema(t.Elt)
+		ss.Type = "array"
+		ss.Items.Type = items.Type
+		ss.Items.Format = items.Format
+		return ss, false
+	case *ast.MapType:
+		return ss, true
+	case *ast.SelectorExpr:
+		// types of other packages, e.g. time.Time or net.IP, are bound from their text
+		if pkg, ok := t.X.(*ast.Ident); ok {
+			ss = getSwaggerSchema(pkg.Name + "." + t.Sel.Name)
+		}
+		if ss.Type == "" {
+			ss.Type = "string"
+		}
+		return ss, false
+	case *ast.Ident:
+		if t.Obj == nil {
+			ss = getSwaggerSchema(t.Name)
+			if ss.Type == "" {
+				ss.Type = TransToValidSchemeType(t.Name)
+			}
+			return ss, false
+		}
+		if spec, ok := t.Obj.Decl.(*ast.TypeSpec); ok {
+			switch spec.Type.(type) {
+			case *ast.StructType, *ast.MapType:
+				return ss, true
+			}
+			return paramSchema(spec.Type)
+		}
+	}
+	ss.Type = "string"
+	return ss, false
+}
+
 // ParseRouterAPIInfo parses router api info for given astFile
 func (parser *Parser) ParseRouterAPIInfo(fileName string, astFile *ast.File) error {
 	for _, astDescription := range astFile.Decls {
@@ -161,9 +227,11 @@ func (parser *Parser) ParseRouterAPIInfo(fileName string, astFile *ast.File) err
 				sm := SwaggerMethod{
 					Tags: []string{recvName},
 					Responses: map[string]SwaggerResponsesDescription{
-						"200": {"Success"},
-						"401": {"Unauthorized"},
-						"403": {"Forbidden"},
+						"200": {Description: "Success"},
+						"400": problemResponse("Bad Request"),
+						"401": problemResponse("Unauthorized"),
+						"403": problemResponse("Forbidden"),
+						"500": problemResponse("Internal Server Error"),
 					},
 					ProMethodName: methodName,
 					Security:      []map[string][]string{},
@@ -173,7 +241,9 @@ func (parser *Parser) ParseRouterAPIInfo(fileName string, astFile *ast.File) err
 					cm = parser.swagger.Components
 				} else {
 					cm = &SwaggerComponent{
-						Schema: map[string]SwaggerComponentStruct{},
+						Schema: map[string]SwaggerComponentStruct{
+							problemSchemaName: problemSchema(),
+						},
 					}
 				}
 				paramMap := make(map[string]SwaggerParameter)
@@ -186,6 +256,26 @@ func (parser *Parser) ParseRouterAPIInfo(fileName string, astFile *ast.File) err
 						}
 					}
 					sm.Summary = operation.Summary
+					sm.ProMiddlewares = operation.ProMiddlewares
+					sm.ProRoles = operation.ProRoles
+					sm.ProAuthSchemes = operation.ProAuthSchemes
+					sm.ProScopes = operation.ProScopes
+					sm.ProTimeout = operation.ProTimeout
+					sm.ProMaxBody = operation.ProMaxBody
+					sm.ProRateLimit = operation.ProRateLimit
+					sm.ProIPAccess = operation.ProIPAccess
+					sm.ProConsumes = operation.ProConsumes
+					sm.ProProduces = operation.ProProduces
+					if sm.ProTimeout > 0 {
+						sm.Responses["503"] = problemResponse("Service Unavailable")
+					}
+					// the body limit of Config.MaxBodySize applies to every route but the ones of @MaxBody -1
+					if sm.ProMaxBody >= 0 {
+						sm.Responses["413"] = problemResponse("Request Entity Too Large")
+					}
+					if sm.ProRateLimit != "" {
+						sm.Responses["429"] = problemResponse("Too Many Requests")
+					}
 					sm.Security = operation.Security
 					if len(sm.Security) > 0 {
 						hasAuth = true
@@ -197,6 +287,7 @@ func (parser *Parser) ParseRouterAPIInfo(fileName string, astFile *ast.File) err
 					route = operation.Path
 				}
 				urlParam := ""
+				pathParams := parsePathParams(route)
 				sm.Params = make([]SwaggerParameter, 0)
 				sm.RequestBody = make(map[string]map[string]SwaggerRequestBody)
 				paramLen := 0
@@ -212,62 +303,86 @@ func (parser *Parser) ParseRouterAPIInfo(fileName string, astFile *ast.File) err
 				}
 				//参数中添加
 				for _, param := range astDeclaration.Type.Params.List {
-					paramLen += len(param.Names)
+					if !isContextType(param.Type, astFile) {
+						paramLen += len(param.Names)
+					}
 				}
 				for _, param := range astDeclaration.Type.Params.List {
+					if isContextType(param.Type, astFile) {
+						// filled with the request context, not a request parameter
+						continue
+					}
 					for _, paramName := range param.Names {
 						name := paramName.Name
-						var typeObj *ast.Ident
-						switch tTypeObj := param.Type.(type) {
-						case *ast.Ident:
-							typeObj = tTypeObj
-						case *ast.StarExpr:
-							typeObj = tTypeObj.X.(*ast.Ident)
-						default:
-							panic("type not support ast type")
-						}
-						paramTypeName := typeObj.Name
-						if typeObj.Obj == nil {
-							ss := getSwaggerSchema(paramTypeName)
+						sm.ProParams = append(sm.ProParams, name)
+						if ss, isBody := paramSchema(param.Type); !isBody {
 							sp := paramMap[name]
 							in := "query"
-							if paramLen == 1 && httpMethod == "get" && name == "key" {
+							if pathParams[name] {
+								in = "path"
+							} else if route == "" && paramLen == 1 && httpMethod == "get" && name == "key" && sp.ProIn == "" {
 								urlParam = "{" + name + "}"
 								in = "path"
-							} else {
-								in = "query"
+							} else if sp.ProIn == "path" {
+								return fmt.Errorf("param %s of %s.%s is not a placeholder of the route file:%s", name, recvName, methodName, fileName)
+							} else if sp.ProIn != "" {
+								in = sp.ProIn
+							}
+							required := in == "path" || sp.Required
+							if sp.ProIn != "" {
+								if sm.ProParamSources == nil {
+									sm.ProParamSources = make(map[string]ParamSource)
+								}
+								sm.ProParamSources[name] = ParamSource{In: in, Required: required}
 							}
 
-							sm.Params = append(sm.Params, SwaggerParameter{
-								Name:        name,
-								Schema:      ss,
-								In:          in,
-								Description: sp.Description,
-							})
+							switch in {
+							case "body":
+								mediaTypes := sm.ProConsumes
+								if len(mediaTypes) == 0 {
+									// the body fields are read from a map, which xml and csv do not decode
+									mediaTypes = []string{"application/json", "application/yaml", "application/msgpack"}
+								}
+								addBodyProperty(&sm, mediaTypes, name, ss, required)
+							case "form":
+								addBodyProperty(&sm, []string{"application/x-www-form-urlencoded", "multipart/form-data"}, name, ss, required)
+							default:
+								sm.Params = append(sm.Params, SwaggerParameter{
+									Name:        name,
+									Schema:      ss,
+									In:          in,
+									Description: sp.Description,
+									Required:    required,
+								})
+							}
 						} else {
-							refObjName := fmt.Sprintf("#/components/schemas/%s", paramTypeName)
+							schema := *typeSchema(cm, param.Type)
 							sm.RequestBody["content"] = make(map[string]SwaggerRequestBody)
-							sm.RequestBody["content"]["application/json-patch+json"] = SwaggerRequestBody{Schema: SwaggerSchemaRef{Ref: refObjName}}
-							sm.RequestBody["content"]["application/json"] = SwaggerRequestBody{Schema: SwaggerSchemaRef{Ref: refObjName}}
-							sm.RequestBody["content"]["text/json"] = SwaggerRequestBody{Schema: SwaggerSchemaRef{Ref: refObjName}}
-							sm.RequestBody["content"]["application/*+json"] = SwaggerRequestBody{Schema: SwaggerSchemaRef{Ref: refObjName}}
-
-							prop := map[string]SwaggerSchema{}
-							for _, f := range typeObj.Obj.Decl.(*ast.TypeSpec).Type.(*ast.StructType).Fields.List {
-								propName := getTagName(f.Tag.Value)
-								if propName == "" {
-									propName = f.Names[0].Name
-								}
-								prop[propName] = getSwaggerSchemaField(f)
+							mediaTypes := sm.ProConsumes
+							if len(mediaTypes) == 0 {
+								mediaTypes = append([]string{"application/json-patch+json", "text/json", "application/*+json"}, codecMediaTypes(&schema)...)
 							}
-							cm.Schema[paramTypeName] = SwaggerComponentStruct{
-								Type:       "object",
-								Properties: prop,
+							for _, mediaType := range mediaTypes {
+								sm.RequestBody["content"][mediaType] = SwaggerRequestBody{Schema: schema}
 							}
 						}
 					}
 
 				}
+				if schema := resultSchema(cm, astDeclaration.Type.Results); schema != nil {
+					mediaTypes := sm.ProProduces
+					if len(mediaTypes) == 0 {
+						mediaTypes = codecMediaTypes(schema)
+					}
+					content := make(map[string]SwaggerRequestBody)
+					for _, mediaType := range mediaTypes {
+						content[mediaType] = SwaggerRequestBody{Schema: *schema}
+					}
+					sm.Responses["200"] = SwaggerResponsesDescription{
+						Description: "Success",
+						Content:     content,
+					}
+				}
 				if route == "" {
 					if len(urlParam) > 0 {
 						route = fmt.Sprintf("/%s/%s/%s", recvName, methodName, urlParam)
@@ -275,6 +390,14 @@ func (parser *Parser) ParseRouterAPIInfo(fileName string, astFile *ast.File) err
 						route = fmt.Sprintf("/%s/%s", recvName, methodName)
 					}
 				}
+				for name := range pathParams {
+					if !hasParam(sm.Params, name) {
+						return fmt.Errorf("route %s path param %s is not a param of %s.%s file:%s", route, name, recvName, methodName, fileName)
+					}
+				}
+				sm.ProRoute = route
+				sm.ProInvoker = newInvoker(recvName, astDeclaration, astFile)
+				route = swaggerPath(route)
 
 				if parser.swagger.Paths[route] == nil {
 					parser.swagger.Paths[route] = map[string]SwaggerMethod{}
@@ -282,13 +405,13 @@ func (parser *Parser) ParseRouterAPIInfo(fileName string, astFile *ast.File) err
 					return fmt.Errorf("err same route file:%s", fileName)
 				}
 				if hasAuth {
-					cm.SecuritySchemes = map[string]SwaggerComponentSecuritySchemes{
-						"oauth2": {
-							Type:        "apiKey",
-							Description: "JWT授权(数据将在请求头中进行传输) 直接在下框中输入Bearer {token}（注意两者之间是一个空格）\"",
-							Name:        "Authorization",
-							In:          "header",
-						},
+					if cm.SecuritySchemes == nil {
+						cm.SecuritySchemes = map[string]SwaggerComponentSecuritySchemes{}
+					}
+					for _, requirement := range sm.Security {
+						for name := range requirement {
+							cm.SecuritySchemes[name] = securityScheme(name, parser.SessionCookieName)
+						}
 					}
 				}
 				if httpMethod == "getpost" {
@@ -307,6 +430,191 @@ func (parser *Parser) ParseRouterAPIInfo(fileName string, astFile *ast.File) err
 	return nil
 }
 
+// addBodyProperty adds the property name to the object schemas of the request body media types
+func addBodyProperty(sm *SwaggerMethod, mediaTypes []string, name string, ss SwaggerSchema, required bool) {
+	if sm.RequestBody["content"] == nil {
+		sm.RequestBody["content"] = make(map[string]SwaggerRequestBody)
+	}
+	for _, mediaType := range mediaTypes {
+		body := sm.RequestBody["content"][mediaType]
+		if body.Schema.Properties == nil {
+			body.Schema = SwaggerSchemaRef{Type: "object", Properties: map[string]SwaggerSchema{}}
+		}
+		body.Schema.Properties[name] = ss
+		if required {
+			body.Schema.Required = append(body.Schema.Required, name)
+		}
+		sm.RequestBody["content"][mediaType] = body
+	}
+}
+
+// codecMediaTypes are the media types of the codecs of hiweb.DefaultCodecs for a schema,
+// advertised by the operations without @Accept or @Produce; text/csv encodes only arrays
+func codecMediaTypes(schema *SwaggerSchemaRef) []string {
+	mediaTypes := []string{"application/json", "application/xml", "application/yaml", "application/msgpack"}
+	if schema.Type == "array" {
+		mediaTypes = append(mediaTypes, "text/csv")
+	}
+	return mediaTypes
+}
+
+var pathParamPattern = regexp.MustCompile(`{\*?([^{}:]+)(:[^/]*)?}`)
+
+// parsePathParams returns the placeholder names of a route, e.g. /orders/{orderId}/items/{itemId:int}
+func parsePathParams(route string) map[string]bool {
+	names := make(map[string]bool)
+	for _, m := range pathParamPattern.FindAllStringSubmatch(route, -1) {
+		names[m[1]] = true
+	}
+	return names
+}
+
+// swaggerPath removes the placeholder types of a route, e.g. /items/{itemId:int} => /items/{itemId}
+func swaggerPath(route string) string {
+	return pathParamPattern.ReplaceAllString(route, "{$1}")
+}
+
+func hasParam(params []SwaggerParameter, name string) bool {
+	for _, p := range params {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// problemSchemaName is the component schema of the RFC 7807 error responses written by hiweb
+const problemSchemaName = "ProblemDetails"
+
+func problemSchema() SwaggerComponentStruct {
+	return SwaggerComponentStruct{
+		Type: "object",
+		Properties: map[string]SwaggerSchema{
+			"type":     {Type: "string"},
+			"title":    {Type: "string"},
+			"status":   {Type: "integer", Format: "int32"},
+			"detail":   {Type: "string"},
+			"instance": {Type: "string"},
+			"code":     {Type: "string"},
+			"details":  {Type: "object"},
+		},
+		AdditionalProperties: true,
+	}
+}
+
+func problemResponse(description string) SwaggerResponsesDescription {
+	return SwaggerResponsesDescription{
+		Description: description,
+		Content: map[string]SwaggerRequestBody{
+			"application/problem+json": {Schema: SwaggerSchemaRef{Ref: "#/components/schemas/" + problemSchemaName}},
+		},
+	}
+}
+
+// securityScheme describes the auth scheme of name, the names of the built-in hiweb schemes are known,
+// other names are documented as an api key in the Authorization header.
+// sessionCookie is the cookie name of the session scheme, the default of hiweb when blank
+func securityScheme(name, sessionCookie string) SwaggerComponentSecuritySchemes {
+	switch name {
+	case "oauth2":
+		return SwaggerComponentSecuritySchemes{
+			Type:        "apiKey",
+			Description: "JWT授权(数据将在请求头中进行传输) 直接在下框中输入Bearer {token}（注意两者之间是一个空格）\"",
+			Name:        "Authorization",
+			In:          "header",
+		}
+	case "bearer":
+		return SwaggerComponentSecuritySchemes{Type: "http", Description: "JWT授权", Scheme: "bearer", BearerFormat: "JWT"}
+	case "basic":
+		return SwaggerComponentSecuritySchemes{Type: "http", Description: "用户名和密码", Scheme: "basic"}
+	case "apikey":
+		return SwaggerComponentSecuritySchemes{Type: "apiKey", Description: "API key", Name: "X-API-Key", In: "header"}
+	case "session":
+		if sessionCookie == "" {
+			sessionCookie = "hiweb_session"
+		}
+		return SwaggerComponentSecuritySchemes{Type: "apiKey", Description: "登录后的session cookie", Name: sessionCookie, In: "cookie"}
+	case "mtls":
+		return SwaggerComponentSecuritySchemes{Type: "mutualTLS", Description: "客户端证书"}
+	}
+	return SwaggerComponentSecuritySchemes{Type: "apiKey", Description: name, Name: "Authorization", In: "header"}
+}
+
+// registerComponent adds the struct type of typeObj to the component schemas
+func registerComponent(cm *SwaggerComponent, typeObj *ast.Ident) {
+	typeSpec, ok := typeObj.Obj.Decl.(*ast.TypeSpec)
+	if !ok {
+		return
+	}
+	structType, ok := typeSpec.Type.(*ast.StructType)
+	if !ok {
+		return
+	}
+	prop := map[string]SwaggerSchema{}
+	for _, f := range structType.Fields.List {
+		if len(f.Names) == 0 {
+			continue
+		}
+		propName := ""
+		if f.Tag != nil {
+			propName = getTagName(f.Tag.Value)
+		}
+		if propName == "" {
+			propName = f.Names[0].Name
+		}
+		prop[propName] = getSwaggerSchemaField(f)
+	}
+	cm.Schema[typeObj.Name] = SwaggerComponentStruct{
+		Type:       "object",
+		Properties: prop,
+	}
+}
+
+// resultSchema returns the response schema of the first non error result of a method, nil when there is none
+func resultSchema(cm *SwaggerComponent, results *ast.FieldList) *SwaggerSchemaRef {
+	if results == nil {
+		return nil
+	}
+	for _, result := range results.List {
+		if ident, ok := result.Type.(*ast.Ident); ok && ident.Name == "error" {
+			continue
+		}
+		return typeSchema(cm, result.Type)
+	}
+	return nil
+}
+
+func typeSchema(cm *SwaggerComponent, expr ast.Expr) *SwaggerSchemaRef {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return typeSchema(cm, t.X)
+	case *ast.ArrayType:
+		if ident, ok := t.Elt.(*ast.Ident); ok && ident.Name == "byte" {
+			return &SwaggerSchemaRef{Type: "string", Format: "binary"}
+		}
+		return &SwaggerSchemaRef{Type: "array", Items: typeSchema(cm, t.Elt)}
+	case *ast.MapType:
+		return &SwaggerSchemaRef{Type: "object"}
+	case *ast.Ident:
+		if t.Obj != nil {
+			if typeSpec, ok := t.Obj.Decl.(*ast.TypeSpec); ok {
+				if _, ok := typeSpec.Type.(*ast.StructType); !ok {
+					// only structs are registered as components, other named types use the schema of their underlying type
+					return typeSchema(cm, typeSpec.Type)
+				}
+				registerComponent(cm, t)
+				return &SwaggerSchemaRef{Ref: fmt.Sprintf("#/components/schemas/%s", t.Name)}
+			}
+		}
+		ss := getSwaggerSchema(t.Name)
+		if ss.Type == "" {
+			ss.Type = TransToValidSchemeType(t.Name)
+		}
+		return &SwaggerSchemaRef{Type: ss.Type, Format: ss.Format}
+	}
+	return &SwaggerSchemaRef{Type: "object"}
+}
+
 // GetAllGoFileInfo gets all Go source files information for given searchDir.
 func (parser *Parser) getAllGoFileInfo(searchDir string) error {
 	return filepath.Walk(searchDir, parser.visit)
@@ -11,7 +11,7 @@ import (
 func firstLower(s string) string {
 	ret := ""
 	if s == "" {
-		fmt.Printf("error " + s)
+		fmt.Fprintln(os.Stderr, "error firstLower of a blank string")
 		return ""
 	}
 	if len(s) >= 1 {
@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
+	"unicode"
 )
 
 var vueTemplate = `
@@ -15,7 +18,7 @@ import BAPI from './bapi'
 {{range $i,$v := .Methods}}
 function {{$v.MethodName}}({{$v.ParamNames}}){
 
-	let tmpUrl = "{{$v.MethodPath}}";
+	let tmpUrl = {{$v.MethodPath}};
 
 	{{if eq  $v.MethodType "post"}}
 		let inparam={
@@ -32,6 +35,13 @@ function {{$v.MethodName}}({{$v.ParamNames}}){
 	return BAPI.Xhr({
 		url: tmpUrl,
 		method: '{{$v.MethodType}}',
+	{{if $v.HeaderList}}
+		headers: {
+		{{range $is,$vs := $v.HeaderList}}
+			"{{$vs}}":{{$vs}},
+		{{end}}
+		},
+	{{end}}
 	{{if eq  $v.MethodType "post"}}
 		body:inparam,
 	{{end}}
@@ -52,6 +62,7 @@ type VueFunction struct {
 	MethodPath string
 	ParamNames string
 	ParamList  []string
+	HeaderList []string
 	MethodType string
 
 	IsAuth bool
@@ -78,16 +89,21 @@ func genVue(apiDocFileName string, vueBaseUrl string, swaggerSpec *SwaggerSpec)
 	}
 
 	outMethodList := make([]VueFunction, 0)
-	for k, vs := range swaggerSpec.Paths {
-		tactions := strings.Split(k, "/")
-		actions := make([]string, 0)
-		for _, ta := range tactions {
-			if ta == "" {
-				continue
-			}
-			actions = append(actions, ta)
+	paths := make([]string, 0, len(swaggerSpec.Paths))
+	for k := range swaggerSpec.Paths {
+		paths = append(paths, k)
+	}
+	sort.Strings(paths)
+	usedNames := make(map[string]bool)
+	for _, k := range paths {
+		vs := swaggerSpec.Paths[k]
+		methods := make([]string, 0, len(vs))
+		for tk := range vs {
+			methods = append(methods, tk)
 		}
-		for tk, tv := range vs {
+		sort.Strings(methods)
+		for _, tk := range methods {
+			tv := vs[tk]
 			inClassName := ""
 			for _, rbv := range tv.RequestBody {
 				for _, srbv := range rbv {
@@ -96,27 +112,57 @@ func genVue(apiDocFileName string, vueBaseUrl string, swaggerSpec *SwaggerSpec)
 				}
 			}
 			paramNames := make([]string, 0)
+			queryNames := make([]string, 0)
+			headerNames := make([]string, 0)
 			for _, p := range tv.Params {
+				switch p.In {
+				case "cookie":
+					// cookies are sent by the browser
+					continue
+				case "header":
+					headerNames = append(headerNames, p.Name)
+				case "query":
+					queryNames = append(queryNames, p.Name)
+				}
 				paramNames = append(paramNames, p.Name)
 			}
 			if inClassName != "" {
 				sClass := swaggerSpec.Components.Schema[inClassName]
+				classNames := make([]string, 0, len(sClass.Properties))
 				for pk := range sClass.Properties {
-					paramNames = append(paramNames, pk)
+					classNames = append(classNames, pk)
 				}
+				sort.Strings(classNames)
+				paramNames = append(paramNames, classNames...)
+				queryNames = append(queryNames, classNames...)
 			}
+			bodyNames := make([]string, 0)
+			for _, rbv := range tv.RequestBody {
+				for mediaType, srbv := range rbv {
+					if srbv.Schema.Ref == "" && mediaType != "multipart/form-data" {
+						for pk := range srbv.Schema.Properties {
+							if !hasName(bodyNames, pk) {
+								bodyNames = append(bodyNames, pk)
+							}
+						}
+					}
+				}
+			}
+			sort.Strings(bodyNames)
+			paramNames = append(paramNames, bodyNames...)
+			queryNames = append(queryNames, bodyNames...)
 			isAuth := false
 			if len(tv.Security) > 0 {
 				isAuth = true
 			}
-			methodName := fmt.Sprintf("%s%s", actions[0], actions[1])
-			methodPath := fmt.Sprintf("/%s/%s", actions[0], actions[1])
+			methodName := vueMethodName(k, tk, usedNames)
 			outMethodList = append(outMethodList, VueFunction{
 				MethodName: methodName,
-				MethodPath: methodPath,
+				MethodPath: jsPath(k),
 				MethodType: tk,
 				ParamNames: strings.Join(paramNames, ","),
-				ParamList:  paramNames,
+				ParamList:  queryNames,
+				HeaderList: headerNames,
 				IsAuth:     isAuth,
 			})
 
@@ -143,3 +189,63 @@ func genVue(apiDocFileName string, vueBaseUrl string, swaggerSpec *SwaggerSpec)
 	_, err = apiDoc.Write(code)
 	return err
 }
+
+// vueMethodName returns the function name of a swagger path from all its static segments,
+// e.g. /orders/{orderId}/items => OrdersItems, a name already used gets the http method and then a number appended
+func vueMethodName(path, method string, used map[string]bool) string {
+	var name strings.Builder
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") {
+			continue
+		}
+		upper := true
+		for _, r := range segment {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+				upper = true
+				continue
+			}
+			if upper {
+				r = unicode.ToUpper(r)
+				upper = false
+			}
+			name.WriteRune(r)
+		}
+	}
+	base := name.String()
+	if base == "" || unicode.IsDigit([]rune(base)[0]) {
+		base = "Api" + base
+	}
+	methodName := base
+	if used[methodName] && method != "" {
+		base += strings.ToUpper(method[:1]) + strings.ToLower(method[1:])
+		methodName = base
+	}
+	for i := 2; used[methodName]; i++ {
+		methodName = fmt.Sprintf("%s%d", base, i)
+	}
+	used[methodName] = true
+	return methodName
+}
+
+// jsPath returns the javascript expression of a swagger path, e.g. /orders/{orderId} => "/orders/" + encodeURIComponent(orderId)
+func jsPath(path string) string {
+	parts := make([]string, 0)
+	last := 0
+	for _, loc := range pathParamPattern.FindAllStringSubmatchIndex(path, -1) {
+		parts = append(parts, strconv.Quote(path[last:loc[0]]), "encodeURIComponent("+path[loc[2]:loc[3]]+")")
+		last = loc[1]
+	}
+	if last < len(path) {
+		parts = append(parts, strconv.Quote(path[last:]))
+	}
+	return strings.Join(parts, " + ")
+}
+
+func hasName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}